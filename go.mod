@@ -2,4 +2,11 @@ module github.com/Aytrw/otaku-chart-maker
 
 go 1.26
 
-require golang.org/x/sync v0.19.0 // indirect
+require (
+	github.com/coder/websocket v1.8.14
+	github.com/jung-kurt/gofpdf v1.16.2
+	golang.org/x/image v0.25.0
+	golang.org/x/sync v0.19.0
+)
+
+require golang.org/x/text v0.23.0 // indirect
@@ -0,0 +1,605 @@
+package api
+
+import (
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AniDB HTTP API 相关配置常量。AniDB 对请求频率要求严格（每个客户端至少间隔 2 秒，
+// 标题列表每天最多下载一次），违反会被封禁，因此间隔与缓存都取保守值。
+const (
+	anidbAPIURL           = "http://api.anidb.net:9001/httpapi"
+	anidbTitlesURL        = "https://anidb.net/api/anime-titles.xml.gz"
+	anidbImageURL         = "https://cdn-eu.anidb.net/images/main/"
+	anidbMinInterval      = 4 * time.Second  // 两次 API 请求的最小间隔
+	anidbTitlesTTL        = 24 * time.Hour   // 标题列表的刷新周期
+	anidbTitlesRetry      = 10 * time.Minute // 标题列表下载失败后的重试间隔
+	anidbAnimeTTL         = 24 * time.Hour   // 条目详情的缓存时长
+	anidbBanBackoff       = 30 * time.Minute // 收到 Banned 后暂停请求的时长
+	anidbCacheMaxEntries  = 500
+	anidbDefaultResults   = 20
+	anidbMaxResults       = 100
+	anidbProtocolVersion  = 1
+	anidbMaxResponseBytes = 64 << 20 // 标题列表解压后约 10MB，留足余量
+)
+
+// AniDBClient 是 AniDB HTTP API 客户端。AniDB 没有按关键词搜索的接口，
+// 搜索在定期下载的标题列表（anime-titles.xml.gz）上本地进行，封面等详情按 ID 另行获取。
+type AniDBClient struct {
+	http      *http.Client
+	opts      clientOptions
+	client    string // 在 AniDB 注册的客户端名
+	clientVer int    // 注册的客户端版本
+	coversDir string
+	titlesURL string // 标题列表地址，固定为 anidbTitlesURL，测试时替换
+
+	mu       sync.Mutex
+	cache    map[int]anidbCacheEntry
+	titles   []anidbTitles
+	titlesAt time.Time
+
+	titlesMu    sync.Mutex // 串行化标题列表下载，避免并发搜索重复下载
+	titlesErr   error      // 最近一次下载失败的错误，anidbTitlesRetry 内直接返回
+	titlesErrAt time.Time
+
+	throttleMu  sync.Mutex // 串行化 API 请求，保证请求间隔
+	lastRequest time.Time
+	bannedUntil time.Time
+
+	downloads downloadCounter // 本次运行的封面下载统计
+}
+
+// anidbCacheEntry 是条目详情的缓存条目。
+type anidbCacheEntry struct {
+	anime  *AniDBAnime
+	expire time.Time
+}
+
+// anidbTitle 是标题列表或条目详情中的一个标题。
+type anidbTitle struct {
+	Lang  string `xml:"lang,attr"`
+	Type  string `xml:"type,attr"` // main、official、syn、short
+	Value string `xml:",chardata"`
+}
+
+// anidbTitles 是标题列表中一个条目的全部标题。
+type anidbTitles struct {
+	AID    int          `xml:"aid,attr"`
+	Titles []anidbTitle `xml:"title"`
+	norm   []string     // 各标题规范化后的形式，与 Titles 一一对应，加载时预先计算
+}
+
+// AniDBAnime 是 AniDB 条目详情的常用字段。
+type AniDBAnime struct {
+	Card
+	Episodes  int    `json:"episodes,omitempty"`
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+}
+
+// NewAniDBClient 创建 AniDB 客户端。client 与 clientVer 是在 AniDB 注册的客户端名与版本，
+// 未设置时客户端不可用（见 Configured）。
+func NewAniDBClient(coversDir, client string, clientVer int, opts ...Option) *AniDBClient {
+	c := &AniDBClient{
+		opts:      buildOptions(opts),
+		client:    strings.TrimSpace(client),
+		clientVer: clientVer,
+		coversDir: coversDir,
+		titlesURL: anidbTitlesURL,
+		cache:     make(map[int]anidbCacheEntry),
+	}
+	c.http = c.opts.newHTTPClient()
+	return c
+}
+
+// Configured 报告是否设置了 AniDB 要求的客户端注册参数。
+func (c *AniDBClient) Configured() bool {
+	return c.client != "" && c.clientVer > 0
+}
+
+// errAniDBUnconfigured 是未设置客户端注册参数时的错误。
+var errAniDBUnconfigured = badRequestError("未配置 AniDB 客户端（-anidb-client / -anidb-clientver）")
+
+// SearchAnime 在标题列表中按关键词搜索动画，任一语言的任一标题包含关键词即命中，
+// 按标题相似度降序返回。标题列表不含封面，卡片的 cover 为空，需要时用 GetAnime 获取。
+func (c *AniDBClient) SearchAnime(keyword string, limit int) ([]Card, error) {
+	if !c.Configured() {
+		return nil, errAniDBUnconfigured
+	}
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return nil, badRequestError("关键词不能为空")
+	}
+	if limit <= 0 || limit > anidbMaxResults {
+		limit = anidbDefaultResults
+	}
+	all, err := c.loadTitles()
+	if err != nil {
+		return nil, err
+	}
+
+	needle := string(normalizeTitle(keyword))
+	if needle == "" {
+		return []Card{}, nil
+	}
+	type hit struct {
+		entry *anidbTitles
+		score float64
+	}
+	var hits []hit
+	for i := range all {
+		e := &all[i]
+		best, matched := 0.0, false
+		for j, t := range e.Titles {
+			if strings.Contains(e.norm[j], needle) {
+				matched = true
+				best = max(best, TitleSimilarity(keyword, t.Value))
+			}
+		}
+		if matched {
+			hits = append(hits, hit{entry: e, score: best})
+		}
+	}
+	slices.SortFunc(hits, func(a, b hit) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.entry.AID, b.entry.AID))
+	})
+
+	cards := make([]Card, 0, min(len(hits), limit))
+	for _, h := range hits[:min(len(hits), limit)] {
+		name, nameCN := anidbNames(h.entry.Titles)
+		cards = append(cards, Card{
+			ID:     strconv.Itoa(h.entry.AID),
+			Name:   name,
+			NameCN: nameCN,
+			Source: SourceAniDB,
+		})
+	}
+	return cards, nil
+}
+
+// anidbNames 从标题中选出显示名：优先日文官方标题，其次主标题（罗马字）；
+// 中文名取简体、繁体或未标明字形的中文官方标题。
+func anidbNames(titles []anidbTitle) (name, nameCN string) {
+	pick := func(match func(anidbTitle) bool) string {
+		for _, t := range titles {
+			if match(t) {
+				return strings.TrimSpace(t.Value)
+			}
+		}
+		return ""
+	}
+	name = pick(func(t anidbTitle) bool { return t.Lang == "ja" && t.Type == "official" })
+	if name == "" {
+		name = pick(func(t anidbTitle) bool { return t.Type == "main" })
+	}
+	for _, lang := range []string{"zh-Hans", "zh-Hant", "zh"} {
+		if nameCN = pick(func(t anidbTitle) bool { return t.Lang == lang && t.Type == "official" }); nameCN != "" {
+			break
+		}
+	}
+	return name, nameCN
+}
+
+// WithAniDBTitlesFile 把下载的 AniDB 标题列表保存到 path（原样的 gzip 数据，修改时间即下载时间）。
+// 重启后先读取该文件，未超过 anidbTitlesTTL 时不重新下载，保证跨重启也遵守每天最多一次的限制。
+// 未设置时标题列表只保存在内存中。
+func WithAniDBTitlesFile(path string) Option {
+	return func(o *clientOptions) {
+		o.anidbTitlesFile = path
+	}
+}
+
+// loadTitles 返回标题列表，超过 anidbTitlesTTL 时重新下载；下载失败但已有旧列表时继续使用旧列表。
+// 失败后 anidbTitlesRetry 内不再重试，避免反复请求被 AniDB 封禁。内存中没有列表时先读取本地副本。
+func (c *AniDBClient) loadTitles() ([]anidbTitles, error) {
+	c.titlesMu.Lock()
+	defer c.titlesMu.Unlock()
+
+	c.mu.Lock()
+	titles, at := c.titles, c.titlesAt
+	c.mu.Unlock()
+	if titles == nil {
+		if titles, at = c.readTitlesFile(); titles != nil {
+			c.mu.Lock()
+			c.titles, c.titlesAt = titles, at
+			c.mu.Unlock()
+		}
+	}
+	if titles != nil && time.Since(at) < anidbTitlesTTL {
+		return titles, nil
+	}
+	if c.titlesErr != nil && time.Since(c.titlesErrAt) < anidbTitlesRetry {
+		if titles != nil {
+			return titles, nil
+		}
+		return nil, c.titlesErr
+	}
+
+	fresh, raw, err := c.fetchTitles()
+	if err != nil {
+		c.titlesErr, c.titlesErrAt = err, time.Now()
+		if titles != nil {
+			return titles, nil
+		}
+		return nil, err
+	}
+	c.titlesErr = nil
+	c.mu.Lock()
+	c.titles, c.titlesAt = fresh, time.Now()
+	c.mu.Unlock()
+	c.saveTitlesFile(raw)
+	return fresh, nil
+}
+
+// readTitlesFile 读取标题列表的本地副本，返回列表与下载时间；未配置、不存在或无法解析时返回 nil。
+func (c *AniDBClient) readTitlesFile() ([]anidbTitles, time.Time) {
+	path := c.opts.anidbTitlesFile
+	if path == "" {
+		return nil, time.Time{}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("读取 AniDB 标题列表副本失败", "path", path, "error", err)
+		}
+		return nil, time.Time{}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, time.Time{}
+	}
+	titles, err := parseAniDBTitlesGzip(f)
+	if err != nil {
+		slog.Warn("AniDB 标题列表副本已损坏，将重新下载", "path", path, "error", err)
+		return nil, time.Time{}
+	}
+	return titles, info.ModTime()
+}
+
+// saveTitlesFile 以临时文件加改名的方式保存下载的标题列表；失败只记录警告。
+func (c *AniDBClient) saveTitlesFile(raw []byte) {
+	path := c.opts.anidbTitlesFile
+	if path == "" {
+		return
+	}
+	err := func() error {
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".anidb-titles-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name()) // 成功时已改名，删除失败无影响
+		if _, err := tmp.Write(raw); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp.Name(), path)
+	}()
+	if err != nil {
+		slog.Warn("保存 AniDB 标题列表副本失败", "path", path, "error", err)
+	}
+}
+
+// fetchTitles 下载并解析 gzip 压缩的标题列表，同时返回原始的 gzip 数据用于保存副本。
+func (c *AniDBClient) fetchTitles() ([]anidbTitles, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.downloadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.titlesURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.userAgent)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("AniDB 标题列表下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, classifyStatus(resp, fmt.Sprintf("AniDB 标题列表下载失败: HTTP %d", resp.StatusCode))
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, anidbMaxResponseBytes))
+	if err != nil {
+		return nil, nil, fmt.Errorf("AniDB 标题列表下载失败: %w", err)
+	}
+	titles, err := parseAniDBTitlesGzip(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, err
+	}
+	return titles, raw, nil
+}
+
+// parseAniDBTitlesGzip 解压并解析标题列表。
+func parseAniDBTitlesGzip(r io.Reader) ([]anidbTitles, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("AniDB 标题列表解压失败: %w", err)
+	}
+	defer zr.Close()
+	return parseAniDBTitles(io.LimitReader(zr, anidbMaxResponseBytes))
+}
+
+// parseAniDBTitles 流式解析标题列表 XML，逐个读取 <anime> 元素。
+func parseAniDBTitles(r io.Reader) ([]anidbTitles, error) {
+	dec := xml.NewDecoder(r)
+	var out []anidbTitles
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 AniDB 标题列表失败: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "anime" {
+			continue
+		}
+		var e anidbTitles
+		if err := dec.DecodeElement(&e, &start); err != nil {
+			return nil, fmt.Errorf("解析 AniDB 标题列表失败: %w", err)
+		}
+		if e.AID > 0 && len(e.Titles) > 0 {
+			e.norm = make([]string, len(e.Titles))
+			for i, t := range e.Titles {
+				e.norm[i] = string(normalizeTitle(t.Value))
+			}
+			out = append(out, e)
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("AniDB 标题列表为空")
+	}
+	return out, nil
+}
+
+// GetAnime 按 AniDB ID 获取条目详情（含封面地址），结果缓存 anidbAnimeTTL。
+func (c *AniDBClient) GetAnime(aid int) (*AniDBAnime, error) {
+	if !c.Configured() {
+		return nil, errAniDBUnconfigured
+	}
+	if aid <= 0 {
+		return nil, badRequestError("无效的 AniDB ID")
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.cache[aid]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expire) {
+		return entry.anime, nil
+	}
+
+	apiURL := fmt.Sprintf("%s?request=anime&client=%s&clientver=%d&protover=%d&aid=%d",
+		anidbAPIURL, url.QueryEscape(c.client), c.clientVer, anidbProtocolVersion, aid)
+	body, err := c.get(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	anime, err := c.parseAnime(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.cache) >= anidbCacheMaxEntries {
+		clear(c.cache)
+	}
+	c.cache[aid] = anidbCacheEntry{anime: anime, expire: time.Now().Add(anidbAnimeTTL)}
+	c.mu.Unlock()
+	return anime, nil
+}
+
+// parseAnime 解析 request=anime 的 XML 响应。AniDB 以 HTTP 200 返回 <error> 元素表示失败，
+// 其中 Banned 表示客户端被临时封禁，此后 anidbBanBackoff 内不再发出请求。
+func (c *AniDBClient) parseAnime(body []byte) (*AniDBAnime, error) {
+	var raw struct {
+		XMLName   xml.Name
+		ID        int          `xml:"id,attr"`
+		Error     string       `xml:",chardata"`
+		Type      string       `xml:"type"`
+		Episodes  int          `xml:"episodecount"`
+		StartDate string       `xml:"startdate"`
+		EndDate   string       `xml:"enddate"`
+		Titles    []anidbTitle `xml:"titles>title"`
+		Picture   string       `xml:"picture"`
+		Rating    float64      `xml:"ratings>permanent"`
+	}
+	if err := xml.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("解析 AniDB 响应失败: %w", err)
+	}
+	if raw.XMLName.Local == "error" {
+		msg := strings.TrimSpace(raw.Error)
+		switch {
+		case strings.Contains(strings.ToLower(msg), "banned"):
+			c.throttleMu.Lock()
+			c.bannedUntil = time.Now().Add(anidbBanBackoff)
+			c.throttleMu.Unlock()
+			return nil, upstreamError{msg: "AniDB 暂时封禁了本客户端: " + msg, kind: ErrRateLimited, retryAfter: anidbBanBackoff}
+		case strings.Contains(strings.ToLower(msg), "no such anime"):
+			return nil, notFoundError{msg: "AniDB 条目不存在"}
+		}
+		return nil, fmt.Errorf("AniDB 拒绝请求: %s", msg)
+	}
+	if raw.XMLName.Local != "anime" || raw.ID <= 0 {
+		return nil, fmt.Errorf("AniDB 响应格式无法识别: <%s>", raw.XMLName.Local)
+	}
+
+	name, nameCN := anidbNames(raw.Titles)
+	anime := &AniDBAnime{
+		Card: Card{
+			ID:        strconv.Itoa(raw.ID),
+			Name:      name,
+			NameCN:    nameCN,
+			Score:     raw.Rating,
+			TypeLabel: strings.TrimSpace(raw.Type),
+			Source:    SourceAniDB,
+		},
+		Episodes:  raw.Episodes,
+		StartDate: strings.TrimSpace(raw.StartDate),
+		EndDate:   strings.TrimSpace(raw.EndDate),
+	}
+	if pic := strings.TrimSpace(raw.Picture); pic != "" {
+		anime.Cover = anidbImageURL + url.PathEscape(pic)
+	}
+	return anime, nil
+}
+
+// get 在限速后发送 API 请求并返回响应字节（传输层自动解开 gzip）。
+func (c *AniDBClient) get(apiURL string) ([]byte, error) {
+	if err := c.throttle(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.apiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.userAgent)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("AniDB API 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, anidbMaxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, classifyStatus(resp, fmt.Sprintf("AniDB API 错误 %d", resp.StatusCode))
+	}
+	return body, nil
+}
+
+// throttle 保证两次 API 请求至少间隔 anidbMinInterval：持锁预约下一个发送时刻，释放锁后再等待，
+// 并发请求依次排到各自的时刻发出，等待期间不阻塞封禁检查。处于封禁退避期时直接返回限流错误。
+func (c *AniDBClient) throttle() error {
+	c.throttleMu.Lock()
+	now := time.Now()
+	if now.Before(c.bannedUntil) {
+		wait := c.bannedUntil.Sub(now)
+		c.throttleMu.Unlock()
+		return upstreamError{msg: "AniDB 封禁退避中，请稍后再试", kind: ErrRateLimited, retryAfter: wait}
+	}
+	at := now
+	if next := c.lastRequest.Add(anidbMinInterval); now.Before(next) {
+		at = next
+	}
+	c.lastRequest = at
+	c.throttleMu.Unlock()
+
+	time.Sleep(at.Sub(now))
+	return nil
+}
+
+// DownloadCover 下载 AniDB 封面到本地 covers 目录。info 供文件名模板使用，可为空。
+func (c *AniDBClient) DownloadCover(imgURL, filename string, info CoverInfo) (*DownloadResult, error) {
+	imgURL = strings.TrimSpace(imgURL)
+	if imgURL == "" {
+		return nil, badRequestError("缺少图片 URL")
+	}
+
+	filename = c.opts.coverFilename(imgURL, filename, SourceAniDB, info)
+	dir, prefix := c.opts.coverDir(c.coversDir, SourceAniDB)
+
+	// 同名封面已存在时发条件请求，未更新则直接复用
+	if existing := findExistingCover(dir, prefix, filename); existing != nil {
+		result := refreshExistingCover(c.coversDir, SourceAniDB, imgURL, existing, c.opts.checkImageData, c.fetchCover)
+		if result.Refreshed {
+			c.downloads.record(result)
+		}
+		return result, nil
+	}
+	if err := c.opts.checkCoverURL(imgURL); err != nil {
+		return nil, err
+	}
+
+	if err := EnsureCoversDir(c.coversDir); err != nil {
+		return nil, err
+	}
+	if err := MkdirCovers(dir); err != nil {
+		return nil, fmt.Errorf("创建封面目录失败: %w", err)
+	}
+	partPath := coverPartPath(dir, imgURL)
+	ct, err := c.opts.downloadResumable(c.opts.coverClient(c.http), partPath, func(ctx context.Context) (*http.Request, error) {
+		return c.coverRequest(ctx, imgURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filename = fixExtByContentType(filename, ct)
+	filename = UniqueFilename(dir, filename)
+	result, err := finishCoverDownload(partPath, dir, prefix, filename, SourceAniDB, strings.TrimSpace(info.ID), imgURL)
+	if err != nil {
+		return nil, err
+	}
+	c.downloads.record(result)
+	return result, nil
+}
+
+// RestoreCover 重新下载封面并按原相对路径保存，用于恢复被误删的文件。
+func (c *AniDBClient) RestoreCover(rel, imgURL string) (*DownloadResult, error) {
+	data, _, err := c.fetchCover(imgURL, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if err := c.opts.checkImageData(data); err != nil {
+		return nil, err
+	}
+	result, err := restoreCoverFile(c.coversDir, rel, SourceAniDB, imgURL, data)
+	if err != nil {
+		return nil, err
+	}
+	c.downloads.record(result)
+	return result, nil
+}
+
+// fetchCover 下载封面图片，返回内容与 Content-Type。图片走 CDN，不受 API 请求间隔限制。
+// since 非零时发送 If-Modified-Since，未更新时返回 errCoverFresh。
+func (c *AniDBClient) fetchCover(imgURL string, since time.Time) ([]byte, string, error) {
+	if err := c.opts.checkCoverURL(imgURL); err != nil {
+		return nil, "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.downloadTimeout)
+	defer cancel()
+	req, err := c.coverRequest(ctx, imgURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("构建请求失败: %w", err)
+	}
+	if !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	return readCoverResponse(c.opts.coverClient(c.http).Do(req))
+}
+
+// coverRequest 构建封面图片请求。
+func (c *AniDBClient) coverRequest(ctx context.Context, imgURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.userAgent)
+	return req, nil
+}
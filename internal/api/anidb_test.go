@@ -0,0 +1,121 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAniDBTitles = `<?xml version="1.0" encoding="UTF-8"?>
+<animetitles>
+<anime aid="1"><title xml:lang="x-jat" type="main">Seikai no Monshou</title></anime>
+<anime aid="2"><title xml:lang="en" type="main">Cowboy Bebop</title></anime>
+</animetitles>`
+
+func gzipBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newTestAniDBClient 创建把标题列表地址指向本地服务器的客户端，返回服务器被请求的次数。
+func newTestAniDBClient(t *testing.T, titlesFile string) (*AniDBClient, *atomic.Int32) {
+	t.Helper()
+	body := gzipBytes(t, testAniDBTitles)
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write(body)
+	}))
+	t.Cleanup(srv.Close)
+	c := NewAniDBClient(t.TempDir(), "test", 1, WithAniDBTitlesFile(titlesFile))
+	c.titlesURL = srv.URL
+	return c, &hits
+}
+
+func TestAniDBTitlesPersistAcrossRestart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "anidb-titles.xml.gz")
+
+	c1, hits1 := newTestAniDBClient(t, file)
+	cards, err := c1.SearchAnime("bebop", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cards) != 1 || cards[0].ID != "2" {
+		t.Fatalf("SearchAnime = %+v, want aid 2", cards)
+	}
+	if hits1.Load() != 1 {
+		t.Fatalf("downloads = %d, want 1", hits1.Load())
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatalf("titles file not saved: %v", err)
+	}
+
+	// 新客户端（模拟重启）在副本未过期时不下载
+	c2, hits2 := newTestAniDBClient(t, file)
+	if _, err := c2.SearchAnime("monshou", 10); err != nil {
+		t.Fatal(err)
+	}
+	if hits2.Load() != 0 {
+		t.Fatalf("downloads after restart = %d, want 0", hits2.Load())
+	}
+
+	// 副本超过 anidbTitlesTTL 后重新下载
+	old := time.Now().Add(-anidbTitlesTTL - time.Minute)
+	if err := os.Chtimes(file, old, old); err != nil {
+		t.Fatal(err)
+	}
+	c3, hits3 := newTestAniDBClient(t, file)
+	if _, err := c3.SearchAnime("bebop", 10); err != nil {
+		t.Fatal(err)
+	}
+	if hits3.Load() != 1 {
+		t.Fatalf("downloads with stale file = %d, want 1", hits3.Load())
+	}
+	info, err := os.Stat(file)
+	if err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Fatalf("titles file not refreshed: %v", err)
+	}
+}
+
+func TestAniDBThrottleSleepsWithoutLock(t *testing.T) {
+	c := NewAniDBClient(t.TempDir(), "test", 1)
+	c.lastRequest = time.Now().Add(-anidbMinInterval + 100*time.Millisecond)
+
+	start := time.Now()
+	done := make(chan error)
+	go func() { done <- c.throttle() }()
+
+	time.Sleep(20 * time.Millisecond)
+	if !c.throttleMu.TryLock() {
+		t.Fatal("throttleMu held while waiting")
+	}
+	c.throttleMu.Unlock()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 80*time.Millisecond {
+		t.Fatalf("throttle returned after %s, want about 100ms", d)
+	}
+
+	c.throttleMu.Lock()
+	c.bannedUntil = time.Now().Add(time.Minute)
+	c.throttleMu.Unlock()
+	if err := c.throttle(); err == nil {
+		t.Fatal("throttle during ban succeeded")
+	}
+}
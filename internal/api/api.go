@@ -1,3 +1,10 @@
 package api
 
-// api 包负责外部数据源客户端（Bangumi/AniList/VNDB）。
+// api 包负责外部数据源客户端（Bangumi/AniList/VNDB/AniDB）。
+
+// 数据源标识，与前端下载请求的 source 字段一致。
+const (
+	SourceBangumi = "bgm"
+	SourceVNDB    = "vndb"
+	SourceAniDB   = "anidb"
+)
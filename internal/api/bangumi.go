@@ -2,16 +2,19 @@ package api
 
 import (
 	"bytes"
-	"crypto/md5"
+	"cmp"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -21,21 +24,27 @@ import (
 
 // Bangumi API 地址和请求参数。
 const (
-	bgmUserAgent     = "OtakuChartMaker/1.0 (https://github.com/Aytrw/otaku-chart-maker)"
 	bgmV0SearchURL   = "https://api.bgm.tv/v0/search/subjects"
 	bgmV0SubjectURL  = "https://api.bgm.tv/v0/subjects/"
 	bgmLegacyURL     = "https://api.bgm.tv/search/subject/"
+	bgmCalendarURL   = "https://api.bgm.tv/calendar"
 	cacheTTL         = 5 * time.Minute
-	cacheCleanTick   = 1 * time.Minute
-	cacheMaxEntries  = 800
+	calendarCacheTTL = 6 * time.Hour // 放送表每天才变化，缓存更久
 	defaultLimit     = 20
-	maxBrowseLimit   = 100
-	summaryMaxWorker = 6 // 并发拉取简介的最大协程数
+	summaryMaxWorker = 6  // 并发拉取简介的最大协程数
+	searchMaxWorker  = 4  // 多关键词搜索的最大并发查询数
+	maxSearchWords   = 10 // 多关键词搜索单次最多的关键词数
 )
 
+// MaxBrowseLimit 是 Bangumi v0 接口允许的单页上限，也是 -max-browse-limit 的默认值与最大值。
+const MaxBrowseLimit = 100
+
 // ErrBadRequest 表示调用参数无效，应返回 4xx。
 var ErrBadRequest = errors.New("bad request")
 
+// ErrNotFound 表示上游资源不存在，应返回 404。
+var ErrNotFound = errors.New("not found")
+
 // requestError 用于保留原始错误信息并附带错误分类。
 type requestError struct {
 	msg string
@@ -49,6 +58,19 @@ func (e requestError) Unwrap() error {
 	return ErrBadRequest
 }
 
+// notFoundError 表示上游返回 404，保留原始错误信息。
+type notFoundError struct {
+	msg string
+}
+
+func (e notFoundError) Error() string {
+	return e.msg
+}
+
+func (e notFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
 // SubjectType 描述 Bangumi 条目的筛选参数。
 type SubjectType struct {
 	TypeID  int    // Bangumi 类型 ID: 1=书籍 2=动画 4=游戏
@@ -94,30 +116,35 @@ var validSorts = map[string]bool{
 	"rank": true, "score": true, "heat": true, "match": true,
 }
 
+// IsValidSort 报告 sort 是否为浏览接口支持的排序方式。
+func IsValidSort(sort string) bool {
+	return validSorts[sort]
+}
+
+// rawSortPattern 是 AllowRawSort 时透传排序值允许的字符集：小写字母、数字与下划线。
+var rawSortPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,31}$`)
+
 // Client 是 Bangumi API 客户端，内含 HTTP 客户端和浏览结果缓存。
 type Client struct {
 	http      *http.Client
+	opts      clientOptions
 	coversDir string
-	mu        sync.Mutex
-	cache     map[string]cacheEntry
+	mu        sync.Mutex // 保护 token
+	token     string     // Bangumi Access Token，空为匿名访问
+	cache     Cache
 	flight    singleflight.Group // 合并相同 key 的并发请求
-}
-
-// cacheEntry 是缓存中的一条记录（原始 JSON + 过期时间）。
-type cacheEntry struct {
-	data   []byte
-	expire time.Time
-	added  time.Time
+	downloads downloadCounter    // 本次运行的封面下载统计
 }
 
 // NewClient 创建 Bangumi 客户端。coversDir 是封面图片保存目录。
-func NewClient(coversDir string) *Client {
+func NewClient(coversDir string, opts ...Option) *Client {
 	c := &Client{
-		http:      &http.Client{Timeout: 15 * time.Second},
+		opts:      buildOptions(opts),
 		coversDir: coversDir,
-		cache:     make(map[string]cacheEntry),
 	}
-	go c.startCacheCleaner()
+	c.cache = c.opts.newCache()
+	c.http = c.opts.newHTTPClient()
+	c.token = c.opts.bgmToken
 	return c
 }
 
@@ -126,6 +153,11 @@ func IsBadRequest(err error) bool {
 	return errors.Is(err, ErrBadRequest)
 }
 
+// IsNotFound 判断错误是否表示资源不存在。
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
 // badRequestError 构建带分类的参数错误。
 func badRequestError(msg string) error {
 	return requestError{msg: msg}
@@ -184,6 +216,57 @@ func (c *Client) Search(keyword string, bgmType int) ([]SearchResult, error) {
 	return results, nil
 }
 
+// SearchMulti 依次搜索多个关键词并合并结果（OR 语义），按条目 ID 去重。
+// 结果按关键词分组：先是第一个关键词的全部结果（保持其原有顺序），再是第二个关键词中尚未出现的结果，依此类推。
+// 查询并发执行，同时进行的不超过 searchMaxWorker 个；某个关键词没有结果时跳过，其余错误使整体失败。
+func (c *Client) SearchMulti(keywords []string, bgmType int) ([]SearchResult, error) {
+	var words []string
+	seenWord := make(map[string]bool)
+	for _, k := range keywords {
+		if k = strings.TrimSpace(k); k != "" && !seenWord[k] {
+			seenWord[k] = true
+			words = append(words, k)
+		}
+	}
+	if len(words) == 0 {
+		return nil, badRequestError("关键词不能为空")
+	}
+	if len(words) > maxSearchWords {
+		return nil, badRequestError(fmt.Sprintf("关键词最多 %d 个", maxSearchWords))
+	}
+
+	lists := make([][]SearchResult, len(words))
+	errs := make([]error, len(words))
+	sem := make(chan struct{}, searchMaxWorker)
+	var wg sync.WaitGroup
+	for i, word := range words {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			lists[i], errs[i] = c.Search(word, bgmType)
+		})
+	}
+	wg.Wait()
+
+	results := []SearchResult{}
+	seenID := make(map[int]bool)
+	for i, list := range lists {
+		if err := errs[i]; err != nil {
+			if IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("搜索「%s」失败: %w", words[i], err)
+		}
+		for _, r := range list {
+			if !seenID[r.ID] {
+				seenID[r.ID] = true
+				results = append(results, r)
+			}
+		}
+	}
+	return results, nil
+}
+
 // ---- 标签浏览 ----
 
 // BrowseRequest 是浏览接口的请求参数。
@@ -194,7 +277,16 @@ type BrowseRequest struct {
 	Limit       int      `json:"limit"`
 	Sort        string   `json:"sort"`
 	SubjectType string   `json:"subjectType"`
-	MinRating   float64  `json:"minRating,omitempty"` // 最低评分筛选，0 表示不筛选
+	MinRating   float64  `json:"minRating,omitempty"`   // 最低评分筛选，0 表示不筛选
+	ExcludeTags []string `json:"excludeTags,omitempty"` // 排除标签，在本地按结果标签过滤
+	YearFrom    int      `json:"yearFrom,omitempty"`    // 放送/发售起始年份，0 表示不限
+	YearTo      int      `json:"yearTo,omitempty"`      // 放送/发售截止年份（含），0 表示不限
+	RawOrder    bool     `json:"rawOrder,omitempty"`    // 保留 API 原始顺序，不做同分按 ID 的稳定排序
+	// AllowRawSort 为 true 时，不在 validSorts 中但符合 rawSortPattern 的 sort 原样传给 API，
+	// 不符合的返回 400；为 false 时未知排序回退为 rank。
+	AllowRawSort bool `json:"allowRawSort,omitempty"`
+	// GroupBySeason 为 true 时另按放送日期把本页结果分入春夏秋冬季度（见 groupBySeason），results 仍保持平铺。
+	GroupBySeason bool `json:"groupBySeason,omitempty"`
 }
 
 // BrowseResult 表示一条浏览结果。
@@ -207,6 +299,7 @@ type BrowseResult struct {
 	Score     float64 `json:"score"`
 	Rank      int     `json:"rank,omitempty"`
 	Summary   string  `json:"summary,omitempty"`
+	Date      string  `json:"date,omitempty"` // 放送/发售日期（YYYY-MM-DD）
 }
 
 // BrowseResponse 是浏览接口的响应。
@@ -215,17 +308,56 @@ type BrowseResponse struct {
 	Total   int            `json:"total"`
 	Offset  int            `json:"offset"`
 	Limit   int            `json:"limit"`
+	Seasons []SeasonGroup  `json:"seasons,omitempty"` // 请求 groupBySeason 时按季度分组的同一批结果
+
+	// Excluded 是本页在本地剔除的条数，Total 已扣除。本地过滤会让一页少于 Limit 条，翻页仍按 Offset+Limit 前进。
+	Excluded int `json:"excluded,omitempty"`
+}
+
+// normalizeTags 去除首尾空白、丢弃空标签，按不区分大小写去重（保留首次出现的写法）并排序。
+// 标签之间是"且"的关系，顺序无关。发给上游的标签保持用户的大小写，只在去重与排除匹配中忽略大小写。
+func normalizeTags(tags []string) []string {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if key := strings.ToLower(t); t != "" && !seen[key] {
+			seen[key] = true
+			out = append(out, t)
+		}
+	}
+	slices.SortFunc(out, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	return out
+}
+
+// tagsKey 把规范化后的标签拼成分组键。保留大小写：Bangumi 按原样匹配标签，写法不同的查询结果可能不同。
+func tagsKey(tags []string) string {
+	return strings.Join(tags, "\x00")
+}
+
+// containsTagFold 判断标签列表中是否有与 tag 仅大小写不同的项。
+func containsTagFold(tags []string, tag string) bool {
+	return slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) })
 }
 
 // Browse 通过 Bangumi v0 API 按标签/关键词浏览条目。
 func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 	// 规范化参数
 	req.Keyword = strings.TrimSpace(req.Keyword)
-	if req.Limit <= 0 || req.Limit > maxBrowseLimit {
-		req.Limit = defaultLimit
+	req.Tags = normalizeTags(req.Tags)
+	req.ExcludeTags = normalizeTags(req.ExcludeTags)
+	if req.Limit <= 0 || req.Limit > c.opts.browseLimit {
+		req.Limit = min(defaultLimit, c.opts.browseLimit)
 	}
 	if !validSorts[req.Sort] {
-		req.Sort = "rank"
+		switch {
+		case !req.AllowRawSort || req.Sort == "":
+			req.Sort = "rank"
+		case !rawSortPattern.MatchString(req.Sort):
+			return nil, badRequestError("sort 只能包含小写字母、数字和下划线: " + req.Sort)
+		}
 	}
 
 	// 校验：至少要有标签、关键词或类型之一
@@ -257,6 +389,16 @@ func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 	if req.MinRating > 0 {
 		filter["rating"] = []string{fmt.Sprintf(">=%g", req.MinRating)}
 	}
+	var airDate []string
+	if req.YearFrom > 0 {
+		airDate = append(airDate, fmt.Sprintf(">=%04d-01-01", req.YearFrom))
+	}
+	if req.YearTo > 0 {
+		airDate = append(airDate, fmt.Sprintf("<%04d-01-01", req.YearTo+1))
+	}
+	if len(airDate) > 0 {
+		filter["air_date"] = airDate
+	}
 	if req.Sort == "rank" {
 		filter["rank"] = []string{">=1"} // 排除无排名条目，避免 rank=0 排在最前
 	}
@@ -268,7 +410,7 @@ func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 	apiLimit := req.Limit
 	apiOffset := req.Offset
 	if needsSubFilter {
-		apiLimit = maxBrowseLimit
+		apiLimit = c.opts.browseLimit
 		if req.Limit > 0 {
 			apiOffset = req.Offset * apiLimit / req.Limit
 		}
@@ -294,8 +436,10 @@ func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 				Score float64 `json:"score"`
 				Rank  int     `json:"rank"`
 			} `json:"rating"`
-			Platform string `json:"platform"`
-			Summary  string `json:"summary"`
+			Platform string   `json:"platform"`
+			Summary  string   `json:"summary"`
+			Date     string   `json:"date"`
+			Tags     []bgmTag `json:"tags"`
 		} `json:"data"`
 	}
 	if err := json.Unmarshal(rawJSON, &raw); err != nil {
@@ -303,6 +447,7 @@ func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 	}
 
 	results := make([]BrowseResult, 0, len(raw.Data))
+	excluded := 0
 	for _, it := range raw.Data {
 		label := TypeLabels[it.Type]
 		if it.Type == 1 {
@@ -312,6 +457,13 @@ func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 		if needsSubFilter && it.Platform != st.MetaTag {
 			continue
 		}
+		// v0 搜索不支持排除标签，命中任一排除标签的条目在本地剔除
+		if len(req.ExcludeTags) > 0 && slices.ContainsFunc(it.Tags, func(t bgmTag) bool {
+			return containsTagFold(req.ExcludeTags, t.Name)
+		}) {
+			excluded++
+			continue
+		}
 		results = append(results, BrowseResult{
 			ID:        it.ID,
 			Name:      it.Name,
@@ -321,9 +473,14 @@ func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 			Score:     it.Rating.Score,
 			Rank:      it.Rating.Rank,
 			Summary:   truncateRunes(it.Summary, 300),
+			Date:      it.Date,
 		})
 	}
 
+	if !req.RawOrder {
+		stableSortResults(results, req.Sort)
+	}
+
 	// 截断到请求的 limit，避免返回过多条目
 	if len(results) > req.Limit {
 		results = results[:req.Limit]
@@ -332,12 +489,37 @@ func (c *Client) Browse(req BrowseRequest) (*BrowseResponse, error) {
 	// 并发填充简介（v0 搜索接口不返回 summary，需单独请求条目详情）
 	c.enrichSummaries(results)
 
-	return &BrowseResponse{
-		Results: results,
-		Total:   raw.Total,
-		Offset:  req.Offset,
-		Limit:   req.Limit,
-	}, nil
+	// 排除标签在本地过滤，本页因此可能不足 limit 条；total 扣除已知被排除的条数，但不小于已翻过的条数加本页条数
+	resp := &BrowseResponse{
+		Results:  results,
+		Total:    max(raw.Total-excluded, req.Offset+len(results)),
+		Offset:   req.Offset,
+		Limit:    req.Limit,
+		Excluded: excluded,
+	}
+	if req.GroupBySeason {
+		resp.Seasons = groupBySeason(results)
+	}
+	return resp, nil
+}
+
+// stableSortResults 按主排序键重排并以 ID 作为次级键，消除同分条目在多次请求间的随机顺序。
+// heat/match 的排序依据不在结果中，只能保留 API 顺序。
+func stableSortResults(results []BrowseResult, sortBy string) {
+	var compare func(a, b BrowseResult) int
+	switch sortBy {
+	case "rank":
+		compare = func(a, b BrowseResult) int {
+			return cmp.Or(cmp.Compare(a.Rank, b.Rank), cmp.Compare(a.ID, b.ID))
+		}
+	case "score":
+		compare = func(a, b BrowseResult) int {
+			return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.ID, b.ID))
+		}
+	default:
+		return
+	}
+	slices.SortStableFunc(results, compare)
 }
 
 // enrichSummaries 并发请求 v0 条目详情接口，为缺少简介的结果补充 summary。
@@ -391,68 +573,105 @@ func (c *Client) enrichSummaries(results []BrowseResult) {
 
 // DownloadResult 是封面下载的返回信息。
 type DownloadResult struct {
-	Filename string `json:"filename"`
-	Path     string `json:"path"`
-	Size     int    `json:"size"`
+	Filename  string `json:"filename"`
+	Path      string `json:"path"`
+	Size      int    `json:"size"`
+	Reused    bool   `json:"reused,omitempty"`    // 同名封面已存在且未更新，直接复用，没有写入文件
+	Refreshed bool   `json:"refreshed,omitempty"` // 同名封面已存在但上游有更新，已原地覆盖
 }
 
-// DownloadCover 下载远程封面图片到 covers 目录。
-func (c *Client) DownloadCover(imgURL, filename string) (*DownloadResult, error) {
+// DownloadCover 下载远程封面图片到 covers 目录。info 供文件名模板使用，可为空。
+func (c *Client) DownloadCover(imgURL, filename string, info CoverInfo) (*DownloadResult, error) {
 	imgURL = strings.TrimSpace(imgURL)
 	if imgURL == "" {
 		return nil, badRequestError("缺少图片 URL")
 	}
-	filename = sanitizeFilename(imgURL, filename)
+	filename = c.opts.coverFilename(imgURL, filename, SourceBangumi, info)
+	dir, prefix := c.opts.coverDir(c.coversDir, SourceBangumi)
 
-	// 同名封面已存在则直接复用，跳过重复下载
-	if existing := findExistingCover(c.coversDir, filename); existing != nil {
-		return existing, nil
+	// 同名封面已存在时发条件请求，未更新则直接复用
+	if existing := findExistingCover(dir, prefix, filename); existing != nil {
+		result := refreshExistingCover(c.coversDir, SourceBangumi, imgURL, existing, c.opts.checkImageData, c.fetchCover)
+		if result.Refreshed {
+			c.downloads.record(result)
+		}
+		return result, nil
 	}
 
-	// 下载图片
-	req, err := http.NewRequest("GET", imgURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("构建请求失败: %w", err)
+	if err := c.opts.checkCoverURL(imgURL); err != nil {
+		return nil, err
 	}
-	req.Header.Set("User-Agent", bgmUserAgent)
-	req.Header.Set("Referer", "https://bgm.tv/")
 
-	resp, err := c.http.Do(req)
+	// 下载到临时文件（目录可能在运行中被删除，写前确保存在），中断时可续传
+	if err := EnsureCoversDir(c.coversDir); err != nil {
+		return nil, err
+	}
+	if err := MkdirCovers(dir); err != nil {
+		return nil, fmt.Errorf("创建封面目录失败: %w", err)
+	}
+	partPath := coverPartPath(dir, imgURL)
+	ct, err := c.opts.downloadResumable(c.opts.coverClient(c.http), partPath, func(ctx context.Context) (*http.Request, error) {
+		return c.coverRequest(ctx, imgURL)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("下载失败: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("下载失败 HTTP %d", resp.StatusCode)
+	// 根据 Content-Type 修正扩展名，避免覆盖同名文件
+	filename = fixExtByContentType(filename, ct)
+	filename = UniqueFilename(dir, filename)
+	result, err := finishCoverDownload(partPath, dir, prefix, filename, SourceBangumi, strings.TrimSpace(info.ID), imgURL)
+	if err != nil {
+		return nil, err
 	}
+	c.downloads.record(result)
+	return result, nil
+}
 
-	ct := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(ct, "image/") {
-		return nil, fmt.Errorf("非图片类型: %s", ct)
+// RestoreCover 重新下载封面并按原相对路径保存，用于恢复被误删的文件。
+func (c *Client) RestoreCover(rel, imgURL string) (*DownloadResult, error) {
+	data, _, err := c.fetchCover(imgURL, time.Time{})
+	if err != nil {
+		return nil, err
 	}
-
-	imgData, err := io.ReadAll(resp.Body)
+	if err := c.opts.checkImageData(data); err != nil {
+		return nil, err
+	}
+	result, err := restoreCoverFile(c.coversDir, rel, SourceBangumi, imgURL, data)
 	if err != nil {
-		return nil, fmt.Errorf("读取图片失败: %w", err)
+		return nil, err
 	}
+	c.downloads.record(result)
+	return result, nil
+}
 
-	// 根据 Content-Type 修正扩展名，避免覆盖同名文件
-	filename = fixExtByContentType(filename, resp.Header.Get("Content-Type"))
-	filename = UniqueFilename(c.coversDir, filename)
-
-	// 写入文件
-	_ = os.MkdirAll(c.coversDir, 0o755)
-	savePath := filepath.Join(c.coversDir, filename)
-	if err := os.WriteFile(savePath, imgData, 0o644); err != nil {
-		return nil, fmt.Errorf("保存封面失败: %w", err)
+// fetchCover 下载封面图片，返回内容与 Content-Type。
+// since 非零时发送 If-Modified-Since，未更新时返回 errCoverFresh。
+func (c *Client) fetchCover(imgURL string, since time.Time) ([]byte, string, error) {
+	if err := c.opts.checkCoverURL(imgURL); err != nil {
+		return nil, "", err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.downloadTimeout)
+	defer cancel()
+	req, err := c.coverRequest(ctx, imgURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("构建请求失败: %w", err)
+	}
+	if !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	return readCoverResponse(c.opts.coverClient(c.http).Do(req))
+}
 
-	return &DownloadResult{
-		Filename: filename,
-		Path:     "covers/" + filename,
-		Size:     len(imgData),
-	}, nil
+// coverRequest 构建封面图片请求。图床要求 Referer。
+func (c *Client) coverRequest(ctx context.Context, imgURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.userAgent)
+	req.Header.Set("Referer", "https://bgm.tv/")
+	return req, nil
 }
 
 // ---- Bangumi HTTP 请求 ----
@@ -479,14 +698,22 @@ func (img bgmImages) bestURL() string {
 	return cover
 }
 
+// bgmTag 是 v0 API 条目的用户标签。
+type bgmTag struct {
+	Name string `json:"name"`
+}
+
 // bgmGet 向 Bangumi API 发送 GET 请求。
 func (c *Client) bgmGet(apiURL string) ([]byte, error) {
-	req, err := http.NewRequest("GET", apiURL, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.apiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", bgmUserAgent)
+	req.Header.Set("User-Agent", c.opts.userAgent)
 	req.Header.Set("Accept", "application/json")
+	c.applyAuth(req)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
@@ -494,21 +721,27 @@ func (c *Client) bgmGet(apiURL string) ([]byte, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, notFoundError{msg: "Bangumi API 错误 404：条目不存在"}
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Bangumi API 错误 %d", resp.StatusCode)
+		return nil, classifyStatus(resp, fmt.Sprintf("Bangumi API 错误 %d", resp.StatusCode))
 	}
 	return io.ReadAll(resp.Body)
 }
 
 // bgmPost 向 Bangumi API 发送 POST 请求（接收已编码的 JSON 字节）。
 func (c *Client) bgmPost(apiURL string, bodyJSON []byte) ([]byte, error) {
-	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(bodyJSON))
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.apiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(bodyJSON))
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", bgmUserAgent)
+	req.Header.Set("User-Agent", c.opts.userAgent)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	c.applyAuth(req)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
@@ -516,34 +749,35 @@ func (c *Client) bgmPost(apiURL string, bodyJSON []byte) ([]byte, error) {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, notFoundError{msg: "Bangumi API 错误 404：条目不存在"}
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("Bangumi API 错误 %d", resp.StatusCode)
+		return nil, classifyStatus(resp, fmt.Sprintf("Bangumi API 错误 %d", resp.StatusCode))
 	}
 	return io.ReadAll(resp.Body)
 }
 
 // ---- 缓存 ----
 
-// cachedPost 带缓存的 POST 请求，使用 singleflight 合并相同请求体的并发调用。
+// cachedPost 带缓存的 POST 请求，使用默认缓存时长。
 func (c *Client) cachedPost(apiURL string, body any) ([]byte, error) {
+	return c.cachedPostTTL(apiURL, body, cacheTTL)
+}
+
+// cachedPostTTL 带缓存的 POST 请求，使用 singleflight 合并相同请求体的并发调用。
+func (c *Client) cachedPostTTL(apiURL string, body any, ttl time.Duration) ([]byte, error) {
 	bodyJSON, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
 
-	key := makeCacheKey(apiURL, bodyJSON)
+	key := c.cacheKey(apiURL, bodyJSON)
 
 	// 查缓存
-	now := time.Now()
-	c.mu.Lock()
-	if entry, ok := c.cache[key]; ok {
-		if now.Before(entry.expire) {
-			c.mu.Unlock()
-			return entry.data, nil
-		}
-		delete(c.cache, key)
+	if data, ok := c.cache.Get(key); ok {
+		return data, nil
 	}
-	c.mu.Unlock()
 
 	// singleflight: 相同 key 的并发请求只执行一次网络调用
 	v, err, _ := c.flight.Do(key, func() (any, error) {
@@ -551,12 +785,7 @@ func (c *Client) cachedPost(apiURL string, body any) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		cachedAt := time.Now()
-		c.mu.Lock()
-		c.cache[key] = cacheEntry{data: result, expire: cachedAt.Add(cacheTTL), added: cachedAt}
-		c.pruneExpiredLocked(cachedAt)
-		c.evictOverflowLocked()
-		c.mu.Unlock()
+		c.cache.Set(key, result, ttl)
 		return result, nil
 	})
 	if err != nil {
@@ -565,21 +794,19 @@ func (c *Client) cachedPost(apiURL string, body any) ([]byte, error) {
 	return v.([]byte), nil
 }
 
-// cachedGet 带缓存的 GET 请求，使用 singleflight 合并相同 URL 的并发调用。
+// cachedGet 带缓存的 GET 请求，使用默认缓存时长。
 func (c *Client) cachedGet(apiURL string) ([]byte, error) {
-	key := makeCacheKey(apiURL, nil)
+	return c.cachedGetTTL(apiURL, cacheTTL)
+}
+
+// cachedGetTTL 带缓存的 GET 请求，使用 singleflight 合并相同 URL 的并发调用。
+func (c *Client) cachedGetTTL(apiURL string, ttl time.Duration) ([]byte, error) {
+	key := c.cacheKey(apiURL, nil)
 
 	// 先查缓存
-	now := time.Now()
-	c.mu.Lock()
-	if entry, ok := c.cache[key]; ok {
-		if now.Before(entry.expire) {
-			c.mu.Unlock()
-			return entry.data, nil
-		}
-		delete(c.cache, key)
+	if data, ok := c.cache.Get(key); ok {
+		return data, nil
 	}
-	c.mu.Unlock()
 
 	// singleflight: 相同 key 的并发请求只执行一次网络调用
 	v, err, _ := c.flight.Do(key, func() (any, error) {
@@ -587,12 +814,7 @@ func (c *Client) cachedGet(apiURL string) ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		cachedAt := time.Now()
-		c.mu.Lock()
-		c.cache[key] = cacheEntry{data: result, expire: cachedAt.Add(cacheTTL), added: cachedAt}
-		c.pruneExpiredLocked(cachedAt)
-		c.evictOverflowLocked()
-		c.mu.Unlock()
+		c.cache.Set(key, result, ttl)
 		return result, nil
 	})
 	if err != nil {
@@ -601,76 +823,11 @@ func (c *Client) cachedGet(apiURL string) ([]byte, error) {
 	return v.([]byte), nil
 }
 
-// startCacheCleaner 周期清理过期缓存，避免长期运行时缓存膨胀。
-func (c *Client) startCacheCleaner() {
-	ticker := time.NewTicker(cacheCleanTick)
-	for now := range ticker.C {
-		c.mu.Lock()
-		c.pruneExpiredLocked(now)
-		c.evictOverflowLocked()
-		c.mu.Unlock()
-	}
-}
-
-// pruneExpiredLocked 清理所有过期缓存条目（调用方需持锁）。
-func (c *Client) pruneExpiredLocked(now time.Time) {
-	for key, entry := range c.cache {
-		if !now.Before(entry.expire) {
-			delete(c.cache, key)
-		}
-	}
-}
-
-// evictOverflowLocked 当缓存超过上限时批量淘汰最早加入的条目（调用方需持锁）。
-func (c *Client) evictOverflowLocked() {
-	excess := len(c.cache) - cacheMaxEntries
-	if excess <= 0 {
-		return
-	}
-	// 一次遍历找出最早的 excess 个 key，避免多轮 O(n) 扫描
-	victims := make([]struct {
-		key   string
-		added time.Time
-	}, 0, excess)
-	for key, entry := range c.cache {
-		if len(victims) < excess {
-			victims = append(victims, struct {
-				key   string
-				added time.Time
-			}{key, entry.added})
-		} else {
-			// 找当前 victims 中最新的那个，看能否替换
-			newest := 0
-			for vi := 1; vi < len(victims); vi++ {
-				if victims[vi].added.After(victims[newest].added) {
-					newest = vi
-				}
-			}
-			if entry.added.Before(victims[newest].added) {
-				victims[newest] = struct {
-					key   string
-					added time.Time
-				}{key, entry.added}
-			}
-		}
-	}
-	for _, v := range victims {
-		delete(c.cache, v.key)
-	}
-}
-
-// makeCacheKey 用 URL + 请求体的 MD5 生成缓存键。
-func makeCacheKey(apiURL string, bodyJSON []byte) string {
-	h := md5.New()
-	h.Write([]byte(apiURL))
-	h.Write(bodyJSON)
-	return fmt.Sprintf("%x", h.Sum(nil))
-}
-
 // ---- 文件名工具 ----
 
-// sanitizeFilename 清理文件名：从 URL 提取、去除不安全字符、确保有图片扩展名。
-func sanitizeFilename(imgURL, filename string) string {
+// sanitizeFilename 清理文件名：从 URL 提取、去除不安全字符、确保有图片扩展名，
+// 并把超过 maxLen 字节的文件名截短（见 truncateFilename）；maxLen 非正表示不限制。
+func sanitizeFilename(imgURL, filename string, maxLen int) string {
 	filename = strings.TrimSpace(filename)
 	if filename == "" {
 		parts := strings.Split(imgURL, "/")
@@ -684,7 +841,7 @@ func sanitizeFilename(imgURL, filename string) string {
 	if !coverExts[ext] {
 		filename += ".jpg"
 	}
-	return filename
+	return truncateFilename(filename, maxLen)
 }
 
 // fixExtByContentType 根据 Content-Type 修正文件扩展名（png/webp）。
@@ -699,8 +856,43 @@ func fixExtByContentType(filename, contentType string) string {
 	return filename
 }
 
-// findExistingCover 检查 covers 目录中是否已存在同名封面（忽略扩展名），有则直接复用。
-func findExistingCover(dir, filename string) *DownloadResult {
+// errCoverFresh 表示条件请求确认本地同名封面无需更新。
+var errCoverFresh = errors.New("封面未更新")
+
+// readCoverResponse 校验封面下载响应并读取图片内容。
+// 条件请求得到 304，或上游不支持条件请求（200 但没有 Last-Modified、无从判断新旧）时返回 errCoverFresh。
+func readCoverResponse(resp *http.Response, err error) ([]byte, string, error) {
+	if err != nil {
+		return nil, "", fmt.Errorf("下载失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Request.Header.Get("If-Modified-Since") != "" {
+		if resp.StatusCode == http.StatusNotModified ||
+			resp.StatusCode == http.StatusOK && resp.Header.Get("Last-Modified") == "" {
+			return nil, "", errCoverFresh
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", classifyStatus(resp, fmt.Sprintf("下载失败 HTTP %d", resp.StatusCode))
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "image/") {
+		return nil, "", fmt.Errorf("非图片类型: %s", ct)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("读取图片失败: %w", err)
+	}
+	return data, ct, nil
+}
+
+// findExistingCover 检查保存目录中是否已存在同名封面（忽略扩展名），有则直接复用。
+// prefix 是保存目录相对 covers 目录的前缀。
+func findExistingCover(dir, prefix, filename string) *DownloadResult {
 	base := strings.TrimSuffix(filename, filepath.Ext(filename))
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -721,15 +913,67 @@ func findExistingCover(dir, filename string) *DownloadResult {
 				continue
 			}
 			return &DownloadResult{
-				Filename: name,
-				Path:     "covers/" + name,
+				Filename: prefix + name,
+				Path:     "covers/" + prefix + name,
 				Size:     int(info.Size()),
+				Reused:   true,
 			}
 		}
 	}
 	return nil
 }
 
+// refreshExistingCover 以本地文件的修改时间发起条件请求：封面在上游有更新时原地覆盖，
+// 否则（包括请求失败，以免离线时无法复用）直接返回已有文件。
+func refreshExistingCover(coversDir, source, imgURL string, existing *DownloadResult, verify func([]byte) error,
+	fetch func(imgURL string, since time.Time) ([]byte, string, error)) *DownloadResult {
+	rel := existing.Filename
+	info, err := os.Stat(filepath.Join(coversDir, filepath.FromSlash(rel)))
+	if err != nil {
+		return existing
+	}
+
+	data, _, err := fetch(imgURL, info.ModTime())
+	if err != nil {
+		if !errors.Is(err, errCoverFresh) {
+			slog.Warn("校验封面更新失败，复用本地文件", "file", rel, "error", err)
+		}
+		return existing
+	}
+	if err := verify(data); err != nil {
+		slog.Warn("上游更新的封面无法解码，复用本地文件", "file", rel, "error", err)
+		return existing
+	}
+	updated, err := restoreCoverFile(coversDir, rel, source, imgURL, data)
+	if err != nil {
+		slog.Warn("更新封面失败，复用本地文件", "file", rel, "error", err)
+		return existing
+	}
+	slog.Info("封面已在上游更新，已覆盖本地文件", "file", rel)
+	updated.Refreshed = true
+	return updated
+}
+
+// EnsureCoversDir 确保封面目录存在；若目录在运行中被删除则重新创建并记录警告。
+func EnsureCoversDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("封面路径不是目录: %s", dir)
+		}
+		return nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("检查封面目录失败: %w", err)
+	}
+
+	if err := MkdirCovers(dir); err != nil {
+		return fmt.Errorf("创建封面目录失败: %w", err)
+	}
+	slog.Warn("封面目录不存在，已重新创建", "dir", dir)
+	return nil
+}
+
 // UniqueFilename 如果同名文件已存在，加数字后缀避免覆盖。
 func UniqueFilename(dir, filename string) string {
 	if _, err := os.Stat(filepath.Join(dir, filename)); os.IsNotExist(err) {
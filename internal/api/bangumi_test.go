@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// roundTripFunc 把函数适配为 http.RoundTripper，用于在测试中替换上游。
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// stubUpstream 让 c 的请求都由 respond 应答，返回记录下来的请求体。
+func stubUpstream(c *http.Client, respond func(body []byte) string) func() [][]byte {
+	var mu sync.Mutex
+	var bodies [][]byte
+	c.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(r.Body)
+		}
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(respond(body))),
+			Request:    r,
+		}, nil
+	})
+	return func() [][]byte {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(bodies)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []string
+		want []string
+	}{
+		{"nil", nil, []string{}},
+		{"empty and blank", []string{"", "  ", "\t"}, []string{}},
+		{"whitespace trimmed", []string{"  百合 ", "\t科幻\n"}, []string{"百合", "科幻"}},
+		{"case kept", []string{"SF", "Fate"}, []string{"Fate", "SF"}},
+		{"case-insensitive duplicates keep first", []string{"Fate", "fate", " FATE "}, []string{"Fate"}},
+		{"sorted ignoring case", []string{"b", "A", "c"}, []string{"A", "b", "c"}},
+		{"exact duplicates", []string{"x", "x", "y", "x"}, []string{"x", "y"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTags(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("normalizeTags(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBrowseTagsKeepCaseUpstream(t *testing.T) {
+	c := NewClient(t.TempDir())
+	requests := stubUpstream(c.http, func([]byte) string {
+		return `{"total":2,"data":[
+			{"id":1,"name":"a","summary":"s","tags":[{"name":"Fate"}]},
+			{"id":2,"name":"b","summary":"s","tags":[{"name":"Fate"},{"name":"ntr"}]}
+		]}`
+	})
+
+	resp, err := c.Browse(BrowseRequest{Tags: []string{" Fate ", "fate", "", "Type-Moon"}, ExcludeTags: []string{"NTR"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 1 || resp.Results[0].ID != 1 {
+		t.Fatalf("results = %+v, want only id 1 (exclude tag matched ignoring case)", resp.Results)
+	}
+
+	bodies := requests()
+	if len(bodies) != 1 {
+		t.Fatalf("upstream requests = %d, want 1", len(bodies))
+	}
+	var sent struct {
+		Filter struct {
+			Tag []string `json:"tag"`
+		} `json:"filter"`
+	}
+	if err := json.Unmarshal(bodies[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Fate", "Type-Moon"}; !slices.Equal(sent.Filter.Tag, want) {
+		t.Errorf("upstream tags = %q, want %q", sent.Filter.Tag, want)
+	}
+
+	// 顺序与空白不同的同一组标签命中缓存；大小写不同是另一个上游查询，不能共用缓存
+	if _, err := c.Browse(BrowseRequest{Tags: []string{"Type-Moon ", "Fate"}, ExcludeTags: []string{"NTR"}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(requests()); n != 1 {
+		t.Errorf("upstream requests after reordering = %d, want 1 (cache hit)", n)
+	}
+	if _, err := c.Browse(BrowseRequest{Tags: []string{"type-moon", "FATE"}, ExcludeTags: []string{"NTR"}}); err != nil {
+		t.Fatal(err)
+	}
+	bodies = requests()
+	if len(bodies) != 2 {
+		t.Fatalf("upstream requests after case-only change = %d, want 2 (cache miss)", len(bodies))
+	}
+	if err := json.Unmarshal(bodies[1], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"FATE", "type-moon"}; !slices.Equal(sent.Filter.Tag, want) {
+		t.Errorf("upstream tags = %q, want %q", sent.Filter.Tag, want)
+	}
+}
+
+func TestStableSortResultsTies(t *testing.T) {
+	results := func() []BrowseResult {
+		return []BrowseResult{
+			{ID: 30, Score: 8.1, Rank: 12},
+			{ID: 10, Score: 8.1, Rank: 12},
+			{ID: 25, Score: 9.0, Rank: 3},
+			{ID: 20, Score: 8.1, Rank: 12},
+			{ID: 5, Score: 7.0, Rank: 40},
+		}
+	}
+	tests := []struct {
+		name    string
+		sort    string
+		rotates []int // 输入先循环左移的位数，模拟 API 在多次请求间返回不同顺序
+		want    []int
+	}{
+		{"score", "score", []int{0, 1, 3}, []int{25, 10, 20, 30, 5}},
+		{"rank", "rank", []int{0, 1, 3}, []int{25, 10, 20, 30, 5}},
+		{"heat keeps API order", "heat", []int{0}, []int{30, 10, 25, 20, 5}},
+		{"default keeps API order", "", []int{0}, []int{30, 10, 25, 20, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, rotate := range tt.rotates {
+				in := results()
+				in = append(in[rotate:], in[:rotate]...)
+				stableSortResults(in, tt.sort)
+				got := make([]int, len(in))
+				for i, r := range in {
+					got[i] = r.ID
+				}
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("rotate %d: order = %v, want %v", rotate, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// bgmMeURL 返回当前 Access Token 对应的用户，用于校验 Token。
+const bgmMeURL = "https://api.bgm.tv/v0/me"
+
+// BangumiUser 是 /v0/me 返回的用户信息。
+type BangumiUser struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Nickname string `json:"nickname"`
+}
+
+// WithBangumiToken 设置 Bangumi Access Token，请求时以 Bearer 方式携带以获得更高的频率限制。空值为匿名访问。
+func WithBangumiToken(token string) Option {
+	return func(o *clientOptions) {
+		o.bgmToken = strings.TrimSpace(token)
+	}
+}
+
+// SetToken 更新 Bangumi Access Token，空值恢复匿名访问。
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = strings.TrimSpace(token)
+	c.mu.Unlock()
+}
+
+// HasToken 报告是否设置了 Access Token。
+func (c *Client) HasToken() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token != ""
+}
+
+// applyAuth 在设置了 Token 时为请求添加 Authorization 头。
+func (c *Client) applyAuth(req *http.Request) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// cacheKey 在通用缓存键后拼接当前 Token 的摘要：带 Token 的响应可能包含只有该账号可见的条目，
+// 不能被匿名请求或换用其他 Token 的请求命中。匿名访问时与 opts.cacheKey 相同。
+func (c *Client) cacheKey(apiURL string, bodyJSON []byte) string {
+	key := c.opts.cacheKey(apiURL, bodyJSON)
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+	if token == "" {
+		return key
+	}
+	sum := sha256.Sum256([]byte(token))
+	return key + "@" + hex.EncodeToString(sum[:8])
+}
+
+// VerifyToken 用给定 Token 请求 /v0/me，返回其对应的用户；Token 无效时返回 badRequestError。
+// 不修改客户端当前使用的 Token。
+func (c *Client) VerifyToken(token string) (*BangumiUser, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, badRequestError("缺少 Bangumi Access Token")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.apiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bgmMeURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.userAgent)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Bangumi API 请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return nil, badRequestError("Bangumi Access Token 无效或已过期")
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("Bangumi API 错误 %d", resp.StatusCode)
+	}
+
+	var user BangumiUser
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, fmt.Errorf("解析用户信息失败: %w", err)
+	}
+	return &user, nil
+}
@@ -0,0 +1,28 @@
+package api
+
+import "testing"
+
+func TestCachedGetSeparatesTokens(t *testing.T) {
+	c := NewClient(t.TempDir())
+	requests := stubUpstream(c.http, func([]byte) string { return `{}` })
+	const url = "https://api.bgm.tv/v0/subjects/1"
+
+	fetch := func() {
+		t.Helper()
+		if _, err := c.cachedGet(url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetch() // 匿名
+	c.SetToken("token-a")
+	fetch()
+	fetch() // 同一 Token 命中缓存
+	c.SetToken("token-b")
+	fetch()
+	c.SetToken("")
+	fetch() // 匿名条目仍在
+
+	if got := len(requests()); got != 3 {
+		t.Fatalf("upstream requests = %d, want 3", got)
+	}
+}
@@ -0,0 +1,341 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 上游响应缓存后端，对应 -cache-backend 的取值。
+const (
+	CacheBackendMemory = "memory" // 进程内存（默认），重启后清空
+	CacheBackendDisk   = "disk"   // 持久化到数据目录下的 cache/，重启后仍可命中
+	CacheBackendNone   = "none"   // 不缓存，每次都请求上游
+)
+
+// CacheDirName 是磁盘缓存在数据目录下的子目录名。与 covers/ 平级，不在 /covers/ 的静态文件范围内：
+// 缓存中可能有带 Token 请求得到的私有结果，不能被直接访问。
+const CacheDirName = "cache"
+
+// 缓存容量与清理参数。
+const (
+	cacheCleanTick        = 1 * time.Minute
+	memoryCacheMaxEntries = 800
+	diskCacheMaxEntries   = 5000
+)
+
+// Cache 保存上游接口的原始响应。实现需支持并发调用。
+type Cache interface {
+	// Get 返回未过期的条目。
+	Get(key string) ([]byte, bool)
+	// Set 写入条目，ttl 后过期；超出容量时由实现决定淘汰哪些条目。
+	Set(key string, data []byte, ttl time.Duration)
+}
+
+// IsValidCacheBackend 判断缓存后端名称是否受支持。
+func IsValidCacheBackend(backend string) bool {
+	switch backend {
+	case CacheBackendMemory, CacheBackendDisk, CacheBackendNone:
+		return true
+	}
+	return false
+}
+
+// NewCache 按后端名称创建缓存；disk 后端把条目存入 dir。
+func NewCache(backend, dir string) (Cache, error) {
+	switch backend {
+	case CacheBackendMemory, "":
+		return NewMemoryCache(memoryCacheMaxEntries), nil
+	case CacheBackendDisk:
+		return NewDiskCache(dir, diskCacheMaxEntries)
+	case CacheBackendNone:
+		return noopCache{}, nil
+	}
+	return nil, fmt.Errorf("未知的缓存后端: %q", backend)
+}
+
+// WithCache 让客户端使用指定的缓存；未设置时各客户端各自创建内存缓存。
+func WithCache(c Cache) Option {
+	return func(o *clientOptions) {
+		o.cache = c
+	}
+}
+
+// newCache 返回选项中注入的缓存，未注入时创建默认的内存缓存。
+func (o clientOptions) newCache() Cache {
+	if o.cache != nil {
+		return o.cache
+	}
+	return NewMemoryCache(memoryCacheMaxEntries)
+}
+
+// ---- 空缓存 ----
+
+// noopCache 不保存任何条目。
+type noopCache struct{}
+
+func (noopCache) Get(string) ([]byte, bool)         { return nil, false }
+func (noopCache) Set(string, []byte, time.Duration) {}
+
+// ---- 内存缓存 ----
+
+// memoryEntry 是内存缓存中的一条记录（原始 JSON + 过期时间）。
+type memoryEntry struct {
+	data   []byte
+	expire time.Time
+	added  time.Time
+}
+
+// memoryCache 是带过期与容量上限的内存缓存，后台定期清理过期条目。
+type memoryCache struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	maxEntries int
+}
+
+// NewMemoryCache 创建最多保存 maxEntries 条的内存缓存。
+func NewMemoryCache(maxEntries int) Cache {
+	c := &memoryCache{entries: make(map[string]memoryEntry), maxEntries: maxEntries}
+	go c.startCleaner()
+	return c
+}
+
+// Get 读取未过期的条目，过期条目顺带删除。
+func (c *memoryCache) Get(key string) ([]byte, bool) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if now.Before(entry.expire) {
+		return entry.data, true
+	}
+	delete(c.entries, key)
+	return nil, false
+}
+
+// Set 写入条目并执行过期清理与容量淘汰。
+func (c *memoryCache) Set(key string, data []byte, ttl time.Duration) {
+	now := time.Now()
+	c.mu.Lock()
+	c.entries[key] = memoryEntry{data: data, expire: now.Add(ttl), added: now}
+	c.pruneExpiredLocked(now)
+	c.evictOverflowLocked()
+	c.mu.Unlock()
+}
+
+// startCleaner 周期清理过期缓存，避免长期运行时缓存膨胀。
+func (c *memoryCache) startCleaner() {
+	ticker := time.NewTicker(cacheCleanTick)
+	for now := range ticker.C {
+		c.mu.Lock()
+		c.pruneExpiredLocked(now)
+		c.evictOverflowLocked()
+		c.mu.Unlock()
+	}
+}
+
+// pruneExpiredLocked 清理所有过期缓存条目（调用方需持锁）。
+func (c *memoryCache) pruneExpiredLocked(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expire) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evictOverflowLocked 当缓存超过上限时批量淘汰最早加入的条目（调用方需持锁）。
+func (c *memoryCache) evictOverflowLocked() {
+	excess := len(c.entries) - c.maxEntries
+	if excess <= 0 {
+		return
+	}
+	// 一次遍历找出最早的 excess 个 key，避免多轮 O(n) 扫描
+	victims := make([]struct {
+		key   string
+		added time.Time
+	}, 0, excess)
+	for key, entry := range c.entries {
+		if len(victims) < excess {
+			victims = append(victims, struct {
+				key   string
+				added time.Time
+			}{key, entry.added})
+		} else {
+			// 找当前 victims 中最新的那个，看能否替换
+			newest := 0
+			for vi := 1; vi < len(victims); vi++ {
+				if victims[vi].added.After(victims[newest].added) {
+					newest = vi
+				}
+			}
+			if entry.added.Before(victims[newest].added) {
+				victims[newest] = struct {
+					key   string
+					added time.Time
+				}{key, entry.added}
+			}
+		}
+	}
+	for _, v := range victims {
+		delete(c.entries, v.key)
+	}
+}
+
+// ---- 磁盘缓存 ----
+
+// diskCacheSuffix 是磁盘缓存条目的扩展名。
+const diskCacheSuffix = ".bin"
+
+// diskCache 把每个条目存为 dir/<sha256(key)>.bin：前 8 字节是过期时间（Unix 纳秒，大端），其后是原始数据。
+// 写入先落临时文件再改名，进程中途退出不会留下半截条目。后台定期删除过期条目，超出容量时删除最早写入的。
+type diskCache struct {
+	dir        string
+	maxEntries int
+	mu         sync.Mutex // 串行化清理与容量淘汰
+}
+
+// NewDiskCache 创建以 dir 为存储目录的磁盘缓存，最多保存 maxEntries 条。
+func NewDiskCache(dir string, maxEntries int) (Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	c := &diskCache{dir: dir, maxEntries: maxEntries}
+	go c.startCleaner()
+	return c, nil
+}
+
+// path 返回 key 对应的条目文件。
+func (c *diskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+diskCacheSuffix)
+}
+
+// Get 读取未过期的条目，过期或损坏的条目顺带删除。
+func (c *diskCache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	data, ok := decodeDiskEntry(raw, time.Now())
+	if !ok {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	return data, true
+}
+
+// Set 写入条目；写入失败只记录警告，不影响本次请求。
+func (c *diskCache) Set(key string, data []byte, ttl time.Duration) {
+	if err := c.write(c.path(key), data, time.Now().Add(ttl)); err != nil {
+		slog.Warn("写入磁盘缓存失败", "dir", c.dir, "error", err)
+	}
+}
+
+// write 以临时文件加改名的方式写入条目。
+func (c *diskCache) write(path string, data []byte, expire time.Time) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 成功时已改名，删除失败无影响
+
+	var header [8]byte
+	binary.BigEndian.PutUint64(header[:], uint64(expire.UnixNano()))
+	if _, err := tmp.Write(header[:]); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// decodeDiskEntry 解析条目文件内容，过期或格式不对时返回 false。
+func decodeDiskEntry(raw []byte, now time.Time) ([]byte, bool) {
+	if len(raw) < 8 {
+		return nil, false
+	}
+	expire := time.Unix(0, int64(binary.BigEndian.Uint64(raw[:8])))
+	if !now.Before(expire) {
+		return nil, false
+	}
+	return raw[8:], true
+}
+
+// startCleaner 周期清理过期条目并执行容量淘汰。
+func (c *diskCache) startCleaner() {
+	c.clean(time.Now())
+	ticker := time.NewTicker(cacheCleanTick)
+	for now := range ticker.C {
+		c.clean(now)
+	}
+}
+
+// clean 删除过期条目；剩余条目超过上限时按写入时间删除最早的。
+func (c *diskCache) clean(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("读取磁盘缓存目录失败", "dir", c.dir, "error", err)
+		}
+		return
+	}
+	type live struct {
+		path    string
+		modTime time.Time
+	}
+	var kept []live
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), diskCacheSuffix) {
+			continue
+		}
+		path := filepath.Join(c.dir, e.Name())
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		var header [8]byte
+		_, err = f.ReadAt(header[:], 0)
+		f.Close()
+		if _, ok := decodeDiskEntry(header[:], now); err != nil || !ok {
+			_ = os.Remove(path)
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			kept = append(kept, live{path: path, modTime: info.ModTime()})
+		}
+	}
+
+	excess := len(kept) - c.maxEntries
+	if excess <= 0 {
+		return
+	}
+	slices.SortFunc(kept, func(a, b live) int { return a.modTime.Compare(b.modTime) })
+	for _, e := range kept[:excess] {
+		_ = os.Remove(e.path)
+	}
+}
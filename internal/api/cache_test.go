@@ -0,0 +1,132 @@
+package api
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// cacheBackend 描述一个参与通用测试的缓存实现。
+type cacheBackend struct {
+	name   string
+	stores bool // 是否真正保存条目（noop 不保存）
+	new    func(t *testing.T, dir string, maxEntries int) Cache
+	evict  func(c Cache) // 触发容量淘汰；nil 表示写入时即淘汰
+}
+
+func cacheBackends() []cacheBackend {
+	return []cacheBackend{
+		{
+			name:   CacheBackendMemory,
+			stores: true,
+			new: func(t *testing.T, _ string, maxEntries int) Cache {
+				return NewMemoryCache(maxEntries)
+			},
+		},
+		{
+			name:   CacheBackendDisk,
+			stores: true,
+			new: func(t *testing.T, dir string, maxEntries int) Cache {
+				c, err := NewDiskCache(dir, maxEntries)
+				if err != nil {
+					t.Fatalf("NewDiskCache: %v", err)
+				}
+				return c
+			},
+			evict: func(c Cache) { c.(*diskCache).clean(time.Now()) },
+		},
+		{
+			name: CacheBackendNone,
+			new: func(*testing.T, string, int) Cache {
+				return noopCache{}
+			},
+		},
+	}
+}
+
+func TestCacheBackends(t *testing.T) {
+	for _, b := range cacheBackends() {
+		t.Run(b.name, func(t *testing.T) {
+			t.Run("GetSet", func(t *testing.T) {
+				c := b.new(t, t.TempDir(), 10)
+				if _, ok := c.Get("missing"); ok {
+					t.Fatal("Get(missing) hit")
+				}
+				c.Set("k", []byte("v1"), time.Minute)
+				c.Set("k", []byte("v2"), time.Minute)
+				got, ok := c.Get("k")
+				if ok != b.stores {
+					t.Fatalf("Get(k) ok = %v, want %v", ok, b.stores)
+				}
+				if ok && !bytes.Equal(got, []byte("v2")) {
+					t.Fatalf("Get(k) = %q, want %q", got, "v2")
+				}
+			})
+
+			t.Run("Expiry", func(t *testing.T) {
+				c := b.new(t, t.TempDir(), 10)
+				c.Set("short", []byte("x"), 20*time.Millisecond)
+				c.Set("expired", []byte("x"), -time.Second)
+				if _, ok := c.Get("expired"); ok {
+					t.Fatal("Get(expired) hit")
+				}
+				time.Sleep(40 * time.Millisecond)
+				if _, ok := c.Get("short"); ok {
+					t.Fatal("Get(short) hit after ttl")
+				}
+			})
+
+			t.Run("Eviction", func(t *testing.T) {
+				c := b.new(t, t.TempDir(), 2)
+				for _, key := range []string{"a", "b", "c"} {
+					c.Set(key, []byte(key), time.Minute)
+					time.Sleep(5 * time.Millisecond) // 保证写入时间可区分
+				}
+				if b.evict != nil {
+					b.evict(c)
+				}
+				if _, ok := c.Get("a"); ok {
+					t.Error("oldest entry a survived eviction")
+				}
+				for _, key := range []string{"b", "c"} {
+					if _, ok := c.Get(key); ok != b.stores {
+						t.Errorf("Get(%s) ok = %v, want %v", key, ok, b.stores)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestDiskCachePersistsAcrossRestart(t *testing.T) {
+	dir := t.TempDir()
+	c1, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1.Set("kept", []byte("payload"), time.Hour)
+	c1.Set("gone", []byte("payload"), -time.Second)
+
+	c2, err := NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := c2.Get("kept")
+	if !ok || string(got) != "payload" {
+		t.Fatalf("Get(kept) after restart = %q, %v", got, ok)
+	}
+	if _, ok := c2.Get("gone"); ok {
+		t.Fatal("expired entry survived restart")
+	}
+}
+
+func TestNewCache(t *testing.T) {
+	for _, backend := range []string{"", CacheBackendMemory, CacheBackendDisk, CacheBackendNone} {
+		if _, err := NewCache(backend, t.TempDir()); err != nil {
+			t.Errorf("NewCache(%q): %v", backend, err)
+		}
+	}
+	if _, err := NewCache("redis", t.TempDir()); err == nil {
+		t.Error("NewCache(redis) succeeded")
+	}
+}
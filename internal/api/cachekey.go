@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WithCacheIgnoreFields 指定生成缓存键时忽略的请求体字段（任意层级的同名键），
+// 只影响缓存命中，不改变实际发给上游的请求。用于客户端生成的随机数等与结果无关的字段。
+func WithCacheIgnoreFields(fields ...string) Option {
+	return func(o *clientOptions) {
+		o.cacheIgnore = nil
+		for _, f := range fields {
+			if f = strings.TrimSpace(f); f != "" {
+				if o.cacheIgnore == nil {
+					o.cacheIgnore = make(map[string]bool)
+				}
+				o.cacheIgnore[f] = true
+			}
+		}
+	}
+}
+
+// cacheKey 用 URL 与规范化后请求体的 MD5 生成缓存键：键按字母排序、忽略字段被剔除，
+// 因此字段顺序不同或仅忽略字段不同的请求共用同一条缓存。非 JSON 的请求体按原样参与计算。
+// 其余内容（包括标签的大小写）与发给上游的请求体一致，上游结果不同的请求不会共用缓存。
+func (o clientOptions) cacheKey(apiURL string, bodyJSON []byte) string {
+	h := md5.New()
+	h.Write([]byte(apiURL))
+	h.Write(o.canonicalBody(bodyJSON))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// canonicalBody 把 JSON 请求体重新序列化为规范形式；解析失败时返回原内容。
+func (o clientOptions) canonicalBody(bodyJSON []byte) []byte {
+	if len(bodyJSON) == 0 {
+		return bodyJSON
+	}
+	dec := json.NewDecoder(bytes.NewReader(bodyJSON))
+	dec.UseNumber() // 保留数字原文，避免大整数经 float64 失真
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return bodyJSON
+	}
+	// encoding/json 序列化 map 时按键排序
+	out, err := json.Marshal(o.stripIgnored(v))
+	if err != nil {
+		return bodyJSON
+	}
+	return out
+}
+
+// stripIgnored 递归剔除忽略字段。
+func (o clientOptions) stripIgnored(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if o.cacheIgnore[k] {
+				delete(t, k)
+				continue
+			}
+			t[k] = o.stripIgnored(child)
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = o.stripIgnored(child)
+		}
+	}
+	return v
+}
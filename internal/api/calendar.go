@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CalendarDay 是放送表中某个星期几的条目列表。Weekday 取 1~7，对应周一到周日。
+type CalendarDay struct {
+	Weekday int            `json:"weekday"`
+	Label   string         `json:"label"`
+	Items   []BrowseResult `json:"items"`
+}
+
+// GetCalendar 获取 Bangumi 每日放送表，按星期分组返回（缓存数小时）。
+// 封面 URL 与浏览结果一致，可直接交给 DownloadCover 下载。
+func (c *Client) GetCalendar() ([]CalendarDay, error) {
+	data, err := c.cachedGetTTL(bgmCalendarURL, calendarCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []struct {
+		Weekday struct {
+			ID int    `json:"id"`
+			CN string `json:"cn"`
+		} `json:"weekday"`
+		Items []struct {
+			ID     int       `json:"id"`
+			Type   int       `json:"type"`
+			Name   string    `json:"name"`
+			NameCN string    `json:"name_cn"`
+			Images bgmImages `json:"images"`
+			Rank   int       `json:"rank"`
+			Rating struct {
+				Score float64 `json:"score"`
+			} `json:"rating"`
+			Summary string `json:"summary"`
+			AirDate string `json:"air_date"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析放送表失败: %w", err)
+	}
+
+	days := make([]CalendarDay, 0, len(raw))
+	for _, d := range raw {
+		day := CalendarDay{
+			Weekday: d.Weekday.ID,
+			Label:   d.Weekday.CN,
+			Items:   make([]BrowseResult, 0, len(d.Items)),
+		}
+		for _, it := range d.Items {
+			day.Items = append(day.Items, BrowseResult{
+				ID:        it.ID,
+				Name:      it.Name,
+				NameCN:    it.NameCN,
+				Cover:     it.Images.bestURL(),
+				TypeLabel: TypeLabels[it.Type],
+				Score:     it.Rating.Score,
+				Rank:      it.Rank,
+				Summary:   truncateRunes(it.Summary, 300),
+				Date:      it.AirDate,
+			})
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CoverMeta 是与封面同目录的旁路元数据（<封面文件名>.json），记录来源以便重新下载。
+// ID 是下载请求给出的条目 ID（Bangumi 为数字、VNDB 如 v17），未提供时为空。
+type CoverMeta struct {
+	Source       string    `json:"source"`
+	ID           string    `json:"id,omitempty"`
+	URL          string    `json:"url"`
+	DownloadedAt time.Time `json:"downloadedAt"`
+}
+
+// CoverMetaPath 返回封面文件对应的元数据路径。
+func CoverMetaPath(imgPath string) string {
+	return imgPath + ".json"
+}
+
+// ReadCoverMeta 读取封面的旁路元数据；不存在时返回的错误满足 errors.Is(err, os.ErrNotExist)。
+func ReadCoverMeta(imgPath string) (*CoverMeta, error) {
+	data, err := os.ReadFile(CoverMetaPath(imgPath))
+	if err != nil {
+		return nil, err
+	}
+	var meta CoverMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("解析封面元数据失败: %w", err)
+	}
+	return &meta, nil
+}
+
+// writeCoverMeta 写入封面旁路元数据；失败只记录警告，不影响下载结果。
+func writeCoverMeta(imgPath, source, id, imgURL string) {
+	data, err := json.MarshalIndent(CoverMeta{
+		Source:       source,
+		ID:           id,
+		URL:          imgURL,
+		DownloadedAt: time.Now(),
+	}, "", "  ")
+	if err == nil {
+		err = WriteCoverFile(CoverMetaPath(imgPath), append(data, '\n'))
+	}
+	if err != nil {
+		slog.Warn("写入封面元数据失败", "path", imgPath, "error", err)
+	}
+}
+
+// restoreCoverFile 把重新下载的图片写回 covers 目录下的 rel 路径，并刷新元数据（保留原有的条目 ID）。
+func restoreCoverFile(coversDir, rel, source, imgURL string, data []byte) (*DownloadResult, error) {
+	savePath := filepath.Join(coversDir, filepath.FromSlash(rel))
+	var id string
+	if old, err := ReadCoverMeta(savePath); err == nil {
+		id = old.ID
+	}
+	if err := EnsureCoversDir(coversDir); err != nil {
+		return nil, err
+	}
+	if err := MkdirCovers(filepath.Dir(savePath)); err != nil {
+		return nil, fmt.Errorf("创建封面目录失败: %w", err)
+	}
+	if err := WriteCoverFile(savePath, data); err != nil {
+		return nil, fmt.Errorf("保存封面失败: %w", err)
+	}
+	writeCoverMeta(savePath, source, id, imgURL)
+
+	rel = filepath.ToSlash(rel)
+	return &DownloadResult{
+		Filename: rel,
+		Path:     "covers/" + rel,
+		Size:     len(data),
+	}, nil
+}
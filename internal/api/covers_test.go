@@ -0,0 +1,31 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnsureCoversDirRecreates(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "covers")
+	if err := EnsureCoversDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureCoversDir(dir); err != nil {
+		t.Fatalf("EnsureCoversDir after removal: %v", err)
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Fatalf("covers dir not recreated: %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureCoversDir(file); err == nil {
+		t.Fatal("EnsureCoversDir on a regular file succeeded")
+	}
+}
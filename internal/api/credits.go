@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// creditsCacheTTL 是条目制作人员与角色列表的缓存时长；这类信息很少变化。
+const creditsCacheTTL = time.Hour
+
+// SubjectPerson 是条目的一位制作人员（或角色的声优）。Image 可直接交给 DownloadCover 保存。
+type SubjectPerson struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Role   string   `json:"role,omitempty"`   // 在条目中的职位，如「导演」「原作」
+	Career []string `json:"career,omitempty"` // 职业分类，如 producer、mangaka
+	Image  string   `json:"image,omitempty"`
+}
+
+// SubjectCharacter 是条目的一个角色及其声优。Image 可直接交给 DownloadCover 保存。
+type SubjectCharacter struct {
+	ID     int             `json:"id"`
+	Name   string          `json:"name"`
+	Role   string          `json:"role,omitempty"` // 主角、配角、客串
+	Image  string          `json:"image,omitempty"`
+	Actors []SubjectPerson `json:"actors"`
+}
+
+// bgmRelatedPerson 是 v0 关联人物接口的条目格式。
+type bgmRelatedPerson struct {
+	ID       int       `json:"id"`
+	Name     string    `json:"name"`
+	Relation string    `json:"relation"`
+	Career   []string  `json:"career"`
+	Images   bgmImages `json:"images"`
+}
+
+// toPerson 转换为对外的人物格式。
+func (p bgmRelatedPerson) toPerson() SubjectPerson {
+	return SubjectPerson{
+		ID:     p.ID,
+		Name:   p.Name,
+		Role:   p.Relation,
+		Career: p.Career,
+		Image:  p.Images.bestURL(),
+	}
+}
+
+// subjectRelations 请求条目的 v0 关联接口（persons/characters），按条目缓存。
+func (c *Client) subjectRelations(id int, kind string) ([]byte, error) {
+	if id <= 0 {
+		return nil, badRequestError("无效的条目 ID")
+	}
+	return c.cachedGetTTL(fmt.Sprintf("%s%d/%s", bgmV0SubjectURL, id, kind), creditsCacheTTL)
+}
+
+// GetSubjectPersons 获取条目的制作人员列表，保持 API 返回的顺序。
+func (c *Client) GetSubjectPersons(id int) ([]SubjectPerson, error) {
+	data, err := c.subjectRelations(id, "persons")
+	if err != nil {
+		return nil, err
+	}
+	var raw []bgmRelatedPerson
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析制作人员失败: %w", err)
+	}
+
+	persons := make([]SubjectPerson, 0, len(raw))
+	for _, p := range raw {
+		persons = append(persons, p.toPerson())
+	}
+	return persons, nil
+}
+
+// GetSubjectCharacters 获取条目的角色列表及各角色的声优，保持 API 返回的顺序。
+func (c *Client) GetSubjectCharacters(id int) ([]SubjectCharacter, error) {
+	data, err := c.subjectRelations(id, "characters")
+	if err != nil {
+		return nil, err
+	}
+	var raw []struct {
+		ID       int                `json:"id"`
+		Name     string             `json:"name"`
+		Relation string             `json:"relation"`
+		Images   bgmImages          `json:"images"`
+		Actors   []bgmRelatedPerson `json:"actors"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析角色列表失败: %w", err)
+	}
+
+	chars := make([]SubjectCharacter, 0, len(raw))
+	for _, ch := range raw {
+		actors := make([]SubjectPerson, 0, len(ch.Actors))
+		for _, a := range ch.Actors {
+			actors = append(actors, a.toPerson())
+		}
+		chars = append(chars, SubjectCharacter{
+			ID:     ch.ID,
+			Name:   ch.Name,
+			Role:   ch.Relation,
+			Image:  ch.Images.bestURL(),
+			Actors: actors,
+		})
+	}
+	return chars, nil
+}
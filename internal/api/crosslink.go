@@ -0,0 +1,155 @@
+package api
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// 跨源匹配参数。
+const (
+	CrossLinkMinConfidence = 0.6 // 低于此置信度的候选不视为匹配
+	crossLinkMaxPerSource  = 3   // 每个数据源最多返回的候选数
+	crossLinkVNDBResults   = 10
+)
+
+// crossLinkBgmTypes 是跨源匹配时搜索的 Bangumi 条目类型：书籍、动画、游戏。
+var crossLinkBgmTypes = []int{1, 2, 4}
+
+// CrossLinkMatch 是某个数据源中与标题可能对应的条目，Confidence 为 0-1 的标题相似度。
+type CrossLinkMatch struct {
+	Card
+	Confidence float64 `json:"confidence"`
+}
+
+// normalizeTitle 统一大小写并去掉空白与标点，使 "Steins;Gate" 与 "STEINS GATE" 视为相同。
+func normalizeTitle(s string) []rune {
+	var out []rune
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// TitleSimilarity 估计两个标题指向同一作品的可能性（0-1）。
+// 以编辑距离占较长标题的比例为基础；一方完整包含另一方（如副标题、季数后缀）时按长度比例给出保底分。
+func TitleSimilarity(a, b string) float64 {
+	ra, rb := normalizeTitle(a), normalizeTitle(b)
+	if len(ra) == 0 || len(rb) == 0 {
+		return 0
+	}
+	if slices.Equal(ra, rb) {
+		return 1
+	}
+	short, long := ra, rb
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+
+	score := 1 - float64(levenshtein(ra, rb))/float64(len(long))
+	if strings.Contains(string(long), string(short)) {
+		score = max(score, 0.5+0.45*float64(len(short))/float64(len(long)))
+	}
+	return max(score, 0)
+}
+
+// levenshtein 计算两个字符序列的编辑距离。
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// bestMatches 按置信度降序保留达到阈值的前若干项，同分按 ID 排序保证结果稳定。
+func bestMatches(matches []CrossLinkMatch) []CrossLinkMatch {
+	matches = slices.DeleteFunc(matches, func(m CrossLinkMatch) bool { return m.Confidence < CrossLinkMinConfidence })
+	slices.SortFunc(matches, func(a, b CrossLinkMatch) int {
+		return cmp.Or(cmp.Compare(b.Confidence, a.Confidence), cmp.Compare(a.ID, b.ID))
+	})
+	if len(matches) > crossLinkMaxPerSource {
+		matches = matches[:crossLinkMaxPerSource]
+	}
+	return matches
+}
+
+// CrossLink 在 Bangumi 的书籍、动画、游戏中并发搜索标题，返回可能对应的条目。
+// 部分类型搜索失败时忽略，全部失败才返回错误。
+func (c *Client) CrossLink(title string) ([]CrossLinkMatch, error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		matches []CrossLinkMatch
+		errs    []error
+	)
+	for _, typ := range crossLinkBgmTypes {
+		wg.Go(func() {
+			results, err := c.Search(title, typ)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for _, it := range results {
+				matches = append(matches, CrossLinkMatch{
+					Card: Card{
+						ID:        strconv.Itoa(it.ID),
+						Name:      it.Name,
+						NameCN:    it.NameCN,
+						Cover:     it.Cover,
+						TypeLabel: TypeLabels[typ],
+						Source:    SourceBangumi,
+					},
+					Confidence: max(TitleSimilarity(title, it.Name), TitleSimilarity(title, it.NameCN)),
+				})
+			}
+		})
+	}
+	wg.Wait()
+	if len(errs) == len(crossLinkBgmTypes) {
+		return nil, errs[0]
+	}
+	return bestMatches(matches), nil
+}
+
+// CrossLink 在 VNDB 中搜索标题，返回可能对应的视觉小说。
+func (c *VNDBClient) CrossLink(title string) ([]CrossLinkMatch, error) {
+	resp, err := c.SearchVN(title, 1, crossLinkVNDBResults)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]CrossLinkMatch, 0, len(resp.Results))
+	for _, vn := range resp.Results {
+		matches = append(matches, CrossLinkMatch{
+			Card: Card{
+				ID:     vn.ID,
+				Name:   vn.Title,
+				NameCN: vn.Alttitle,
+				Cover:  vn.Image.BestURL(),
+				Score:  vn.Rating / 10,
+				Source: SourceVNDB,
+			},
+			Confidence: max(TitleSimilarity(title, vn.Title), TitleSimilarity(title, vn.Alttitle)),
+		})
+	}
+	return bestMatches(matches), nil
+}
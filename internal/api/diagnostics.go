@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Ping 直接请求 Bangumi 放送表（不经缓存），用于检测上游是否可达。
+// 超时由 ctx 控制，只检查状态码，不解析响应体。
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bgmCalendarURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", c.opts.userAgent)
+	req.Header.Set("Accept", "application/json")
+	return ping(c.http, req, "Bangumi")
+}
+
+// Ping 直接请求 VNDB stats 接口（不经缓存），用于检测上游是否可达。
+func (c *VNDBClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, vndbStatsURL, nil)
+	if err != nil {
+		return err
+	}
+	c.applyHeaders(req, false)
+	return ping(c.http, req, "VNDB")
+}
+
+// ping 发送请求并丢弃响应体，非 2xx 状态码视为失败。
+func ping(hc *http.Client, req *http.Request, name string) error {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s API 请求失败: %w", name, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s API 错误 %d", name, resp.StatusCode)
+	}
+	return nil
+}
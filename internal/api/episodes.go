@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// 章节列表接口参数。
+const (
+	bgmV0EpisodesURL    = "https://api.bgm.tv/v0/episodes"
+	episodeDefaultLimit = 100
+	episodeMaxLimit     = 200
+	episodeMaxType      = 6 // 0=本篇 1=SP 2=OP 3=ED 4=预告/宣传 5=MAD 6=其他
+)
+
+// Episode 是条目下的单个章节。
+type Episode struct {
+	ID       int     `json:"id"`
+	Type     int     `json:"type"`
+	Sort     float64 `json:"sort"`         // 条目内的排序编号，可能为小数（如 12.5）
+	Ep       float64 `json:"ep,omitempty"` // 本篇编号，仅 type=0 有意义
+	Name     string  `json:"name"`
+	NameCN   string  `json:"name_cn"`
+	Airdate  string  `json:"airdate,omitempty"`
+	Duration string  `json:"duration,omitempty"`
+}
+
+// EpisodePage 是一页章节列表。
+type EpisodePage struct {
+	Episodes []Episode `json:"episodes"`
+	Total    int       `json:"total"`
+	Offset   int       `json:"offset"`
+	Limit    int       `json:"limit"`
+}
+
+// GetEpisodes 通过 v0 API 分页获取条目的章节列表（按条目缓存）。epType 小于 0 表示不限类型。
+func (c *Client) GetEpisodes(subjectID, epType, offset, limit int) (*EpisodePage, error) {
+	if subjectID <= 0 {
+		return nil, badRequestError("无效的条目 ID")
+	}
+	if epType > episodeMaxType {
+		return nil, badRequestError(fmt.Sprintf("无效的章节类型: %d", epType))
+	}
+	if offset < 0 {
+		return nil, badRequestError("offset 不能为负数")
+	}
+	if limit <= 0 || limit > episodeMaxLimit {
+		limit = episodeDefaultLimit
+	}
+
+	q := url.Values{}
+	q.Set("subject_id", strconv.Itoa(subjectID))
+	if epType >= 0 {
+		q.Set("type", strconv.Itoa(epType))
+	}
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+
+	data, err := c.cachedGet(bgmV0EpisodesURL + "?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Data  []Episode `json:"data"`
+		Total int       `json:"total"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析章节列表失败: %w", err)
+	}
+	if raw.Data == nil {
+		raw.Data = []Episode{}
+	}
+	return &EpisodePage{
+		Episodes: raw.Data,
+		Total:    raw.Total,
+		Offset:   offset,
+		Limit:    limit,
+	}, nil
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateLimited 表示上游限流（HTTP 429），应返回 429 并转发 Retry-After。
+var ErrRateLimited = errors.New("rate limited")
+
+// ErrUpstreamDown 表示上游服务故障（HTTP 5xx），应返回 503。
+var ErrUpstreamDown = errors.New("upstream unavailable")
+
+// upstreamError 保留上游错误信息，附带分类与建议的重试等待时间。
+type upstreamError struct {
+	msg        string
+	kind       error
+	retryAfter time.Duration // 0 表示上游未给出
+}
+
+func (e upstreamError) Error() string {
+	return e.msg
+}
+
+func (e upstreamError) Unwrap() error {
+	return e.kind
+}
+
+// IsRateLimited 判断错误是否表示上游限流。
+func IsRateLimited(err error) bool {
+	return errors.Is(err, ErrRateLimited)
+}
+
+// IsUpstreamDown 判断错误是否表示上游服务故障。
+func IsUpstreamDown(err error) bool {
+	return errors.Is(err, ErrUpstreamDown)
+}
+
+// RetryAfter 返回上游通过 Retry-After 建议的等待时间，未给出时返回 0。
+func RetryAfter(err error) time.Duration {
+	if e, ok := errors.AsType[upstreamError](err); ok {
+		return e.retryAfter
+	}
+	return 0
+}
+
+// classifyStatus 按上游响应的状态码为错误信息 msg 附加分类：404 为 ErrNotFound，429 为 ErrRateLimited，
+// 5xx 为 ErrUpstreamDown；其余状态码返回不带分类的普通错误。
+func classifyStatus(resp *http.Response, msg string) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return notFoundError{msg: msg}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return upstreamError{msg: msg, kind: ErrRateLimited, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	case resp.StatusCode >= 500:
+		return upstreamError{msg: msg, kind: ErrUpstreamDown, retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	return errors.New(msg)
+}
+
+// parseRetryAfter 解析 Retry-After 头（秒数或 HTTP 日期），无效或已过期时返回 0。
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(at.Sub(now), 0)
+	}
+	return 0
+}
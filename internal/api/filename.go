@@ -0,0 +1,124 @@
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CoverInfo 是生成封面文件名时可用的条目信息，字段均可为空。
+type CoverInfo struct {
+	ID    string
+	Title string
+}
+
+// FilenameFields 是 -filename-template 模板可引用的字段。
+type FilenameFields struct {
+	ID     string // 条目 ID，如 12345 或 v17
+	Source string // 数据源：bgm 或 vndb
+	Title  string // 条目标题，缺省时取请求中的文件名
+	Ext    string // 扩展名（含点），下载后会按 Content-Type 修正
+	Date   string // 下载日期，格式 2006-01-02
+}
+
+// ParseFilenameTemplate 解析封面文件名模板，并用示例数据试渲染一次，
+// 使语法错误与不存在的字段在启动时暴露，而不是在首次下载时。
+func ParseFilenameTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("filename").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := FilenameFields{ID: "1", Source: SourceBangumi, Title: "title", Ext: ".jpg", Date: "2006-01-02"}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, sample); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(sb.String()) == "" {
+		return nil, fmt.Errorf("模板渲染结果为空")
+	}
+	return tmpl, nil
+}
+
+// WithFilenameTemplate 设置下载封面的文件名模板；nil 保持默认命名（沿用请求中的文件名）。
+func WithFilenameTemplate(tmpl *template.Template) Option {
+	return func(o *clientOptions) {
+		o.filenameTemplate = tmpl
+	}
+}
+
+// 封面文件名长度限制，按 UTF-8 字节计（多数文件系统限制单个文件名 255 字节）。
+const (
+	DefaultFilenameMaxLen = 150
+	MinFilenameMaxLen     = 32
+	MaxFilenameMaxLen     = 255
+)
+
+// WithFilenameMaxLen 设置封面文件名（含扩展名）的最大字节数；超出 MinFilenameMaxLen~MaxFilenameMaxLen 保持默认。
+func WithFilenameMaxLen(n int) Option {
+	return func(o *clientOptions) {
+		if n >= MinFilenameMaxLen && n <= MaxFilenameMaxLen {
+			o.filenameMaxLen = n
+		}
+	}
+}
+
+// coverFilename 生成封面保存的文件名：先按默认规则清理请求中的文件名，
+// 配置了模板时再用模板渲染，渲染结果同样经过清理；渲染失败或为空时回退默认结果。
+func (o clientOptions) coverFilename(imgURL, filename, source string, info CoverInfo) string {
+	base := sanitizeFilename(imgURL, filename, o.filenameMaxLen)
+	if o.filenameTemplate == nil {
+		return base
+	}
+
+	ext := filepath.Ext(base)
+	title := strings.TrimSpace(info.Title)
+	if title == "" {
+		title = strings.TrimSuffix(base, ext)
+	}
+	fields := FilenameFields{
+		ID:     unsafeChars.ReplaceAllString(strings.TrimSpace(info.ID), "_"),
+		Source: source,
+		Title:  unsafeChars.ReplaceAllString(title, "_"),
+		Ext:    ext,
+		Date:   time.Now().Format(time.DateOnly),
+	}
+	var sb strings.Builder
+	if err := o.filenameTemplate.Execute(&sb, fields); err != nil {
+		slog.Warn("渲染封面文件名模板失败，使用默认文件名", "error", err)
+		return base
+	}
+	name := strings.TrimSpace(sb.String())
+	if name == "" {
+		return base
+	}
+	if !coverExts[strings.ToLower(filepath.Ext(name))] {
+		name += ext
+	}
+	return sanitizeFilename(imgURL, name, o.filenameMaxLen)
+}
+
+// truncateFilename 把超过 maxLen 字节的文件名截短：保留扩展名，主干按字符边界截断，
+// 并追加原主干的短哈希，使前缀相同的不同长标题截断后仍互不相同，同一标题始终得到同一文件名。
+func truncateFilename(name string, maxLen int) string {
+	if maxLen <= 0 || len(name) <= maxLen {
+		return name
+	}
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	sum := fnv.New32a()
+	sum.Write([]byte(base))
+	suffix := fmt.Sprintf("~%08x", sum.Sum32())
+
+	// 向前退到 UTF-8 字符起始字节，避免截断出半个多字节字符
+	cut := min(max(maxLen-len(ext)-len(suffix), 1), len(base))
+	for cut > 0 && cut < len(base) && !utf8.RuneStart(base[cut]) {
+		cut--
+	}
+	return strings.TrimRightFunc(base[:cut], unicode.IsSpace) + suffix + ext
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		maxLen int
+	}{
+		{"ascii", strings.Repeat("a", 300) + ".jpg", MaxFilenameMaxLen},
+		{"ascii min", strings.Repeat("title ", 20) + ".png", MinFilenameMaxLen},
+		{"cjk", strings.Repeat("魔法少女", 40) + ".jpg", 100},
+		{"kana", strings.Repeat("あ", 120) + ".webp", DefaultFilenameMaxLen},
+		{"emoji", strings.Repeat("🎬", 60) + ".jpg", 50},
+		{"no ext", strings.Repeat("x", 80), MinFilenameMaxLen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateFilename(tt.in, tt.maxLen)
+			if len(got) > tt.maxLen {
+				t.Errorf("len = %d, want <= %d: %q", len(got), tt.maxLen, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("result is not valid UTF-8: %q", got)
+			}
+			if filepath.Ext(got) != filepath.Ext(tt.in) {
+				t.Errorf("ext = %q, want %q", filepath.Ext(got), filepath.Ext(tt.in))
+			}
+			if again := truncateFilename(tt.in, tt.maxLen); again != got {
+				t.Errorf("not deterministic: %q then %q", got, again)
+			}
+		})
+	}
+}
+
+func TestTruncateFilenameMultibyteBoundary(t *testing.T) {
+	// 每个限制都让截断点落在 3 字节字符的不同位置
+	in := strings.Repeat("漢", 100) + ".jpg"
+	for maxLen := MinFilenameMaxLen; maxLen < MinFilenameMaxLen+6; maxLen++ {
+		got := truncateFilename(in, maxLen)
+		if len(got) > maxLen || !utf8.ValidString(got) {
+			t.Errorf("maxLen %d: %q (len %d)", maxLen, got, len(got))
+		}
+		stem, _, _ := strings.Cut(got, "~")
+		if strings.Trim(stem, "漢") != "" {
+			t.Errorf("maxLen %d: stem %q contains a partial character", maxLen, stem)
+		}
+	}
+}
+
+func TestTruncateFilenameKeepsShortAndDistinguishesLong(t *testing.T) {
+	short := strings.Repeat("a", 28) + ".jpg" // 恰好 32 字节
+	if got := truncateFilename(short, MinFilenameMaxLen); got != short {
+		t.Errorf("name at the limit changed: %q", got)
+	}
+
+	prefix := strings.Repeat("同じ前置き", 30)
+	a := truncateFilename(prefix+"第一部.jpg", 80)
+	b := truncateFilename(prefix+"第二部.jpg", 80)
+	if a == b {
+		t.Errorf("long names with the same prefix collide: %q", a)
+	}
+}
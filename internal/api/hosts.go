@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DefaultCoverHosts 是默认允许下载封面的图床域名。新增数据源时在此登记其 CDN。
+var DefaultCoverHosts = []string{
+	"bgm.tv",
+	"lain.bgm.tv",
+	"t.vndb.org",
+	"cdn-eu.anidb.net",
+}
+
+// coverMaxRedirects 是封面下载允许跟随的最大重定向次数。
+const coverMaxRedirects = 5
+
+// WithCoverHosts 设置允许下载封面的域名列表，替换默认列表。
+// "*.example.com" 匹配其任意子域名；空列表保持默认。
+func WithCoverHosts(hosts []string) Option {
+	return func(o *clientOptions) {
+		var cleaned []string
+		for _, h := range hosts {
+			if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+				cleaned = append(cleaned, h)
+			}
+		}
+		if len(cleaned) > 0 {
+			o.coverHosts = cleaned
+		}
+	}
+}
+
+// WithAnyCoverHost 关闭封面域名白名单，允许从任意 http(s) 地址下载。
+func WithAnyCoverHost(enabled bool) Option {
+	return func(o *clientOptions) {
+		o.anyCoverHost = enabled
+	}
+}
+
+// checkCoverURL 校验图片 URL 是否允许下载：仅限 http(s)，且域名在白名单内（放开时不限域名）。
+// 下载与代理等所有访问外部图片的路径都应经过此检查。
+func (o clientOptions) checkCoverURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return badRequestError("无效的图片 URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return badRequestError("图片 URL 仅支持 http/https")
+	}
+	if o.anyCoverHost {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	if !slices.ContainsFunc(o.coverHosts, func(pattern string) bool { return matchHost(pattern, host) }) {
+		return badRequestError(fmt.Sprintf("图片域名不在允许列表中: %s", host))
+	}
+	return nil
+}
+
+// matchHost 判断域名是否匹配白名单项；"*.example.com" 匹配其子域名但不含 example.com 本身。
+func matchHost(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+		return strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix)
+	}
+	return pattern == host
+}
+
+// checkCoverRedirect 对封面下载的每次重定向重新校验目标地址，防止经白名单域名跳转到其他主机。
+func (o clientOptions) checkCoverRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= coverMaxRedirects {
+		return errors.New("重定向次数过多")
+	}
+	return o.checkCoverURL(req.URL.String())
+}
+
+// coverClient 返回用于下载封面的 HTTP 客户端：共享底层连接，但重定向同样受白名单约束。
+func (o clientOptions) coverClient(base *http.Client) *http.Client {
+	hc := *base
+	hc.CheckRedirect = o.checkCoverRedirect
+	return &hc
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckCoverURL(t *testing.T) {
+	o := buildOptions([]Option{WithCoverHosts([]string{"bgm.tv", "*.vndb.org", "127.0.0.1", "::1"})})
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://bgm.tv/a.jpg", true},       // 精确匹配
+		{"https://BGM.TV/a.jpg", true},       // 域名不区分大小写
+		{"https://lain.bgm.tv/a.jpg", false}, // 精确项不含子域名
+		{"https://evilbgm.tv/a.jpg", false},  // 后缀相同但不是同一域名
+		{"https://bgm.tv.evil.com/a.jpg", false},
+		{"https://bgm.tv@evil.com/a.jpg", false}, // userinfo 伪装
+		{"https://t.vndb.org/a.jpg", true},       // 通配子域名
+		{"https://a.b.vndb.org/a.jpg", true},
+		{"https://vndb.org/a.jpg", false},   // 通配项不含根域名
+		{"https://bgm.tv:8443/a.jpg", true}, // 端口不影响匹配
+		{"https://t.vndb.org:443/a.jpg", true},
+		{"http://127.0.0.1/a.jpg", true}, // 列出的 IP 字面量
+		{"http://127.0.0.1:8080/a.jpg", true},
+		{"http://[::1]:8080/a.jpg", true},
+		{"http://10.0.0.1/a.jpg", false}, // 未列出的 IP 字面量
+		{"http://[::2]/a.jpg", false},
+		{"ftp://bgm.tv/a.jpg", false},
+		{"file:///etc/passwd", false},
+		{"bgm.tv/a.jpg", false},
+	}
+	for _, tt := range tests {
+		if err := o.checkCoverURL(tt.url); (err == nil) != tt.ok {
+			t.Errorf("checkCoverURL(%q) err = %v, want ok=%v", tt.url, err, tt.ok)
+		}
+	}
+
+	anyHost := buildOptions([]Option{WithAnyCoverHost(true)})
+	if err := anyHost.checkCoverURL("http://10.0.0.1/a.jpg"); err != nil {
+		t.Errorf("any host: %v", err)
+	}
+	if err := anyHost.checkCoverURL("file:///etc/passwd"); err == nil {
+		t.Error("any host accepted file URL")
+	}
+}
+
+func TestCoverClientBlocksRedirect(t *testing.T) {
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("img"))
+	}))
+	defer blocked.Close()
+	// 白名单只有 127.0.0.1，跳转目标用 localhost 表示另一个主机
+	target := strings.Replace(blocked.URL, "127.0.0.1", "localhost", 1) + "/a.jpg"
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/same" {
+			http.Redirect(w, r, "/a.jpg", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/a.jpg" {
+			w.Write([]byte("img"))
+			return
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	}))
+	defer allowed.Close()
+
+	o := buildOptions([]Option{WithCoverHosts([]string{"127.0.0.1"})})
+	hc := o.coverClient(&http.Client{})
+
+	resp, err := hc.Get(allowed.URL + "/same")
+	if err != nil {
+		t.Fatalf("redirect within allowed host: %v", err)
+	}
+	resp.Body.Close()
+
+	resp, err = hc.Get(allowed.URL + "/elsewhere")
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("redirect to a host outside the allowlist succeeded")
+	}
+	if !strings.Contains(err.Error(), "localhost") {
+		t.Errorf("error = %v, want it to name the blocked host", err)
+	}
+}
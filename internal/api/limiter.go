@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrOverloaded 表示发往上游的请求数已达上限，新请求被直接拒绝，应返回 503。
+var ErrOverloaded = errors.New("上游请求队列已满，请稍后重试")
+
+// IsOverloaded 判断错误是否因上游请求队列已满而被拒绝。
+func IsOverloaded(err error) bool {
+	return errors.Is(err, ErrOverloaded)
+}
+
+// UpstreamLimiter 限制两个客户端发往上游的并发请求总数（含封面下载）。
+// 名额在响应体关闭时归还；已满时不排队等待，立即返回 ErrOverloaded，避免请求洪峰时无限堆积。
+type UpstreamLimiter struct {
+	slots    chan struct{}
+	rejected atomic.Int64
+}
+
+// UpstreamQueueStats 是上游请求队列的当前状态。
+type UpstreamQueueStats struct {
+	InFlight int   `json:"inFlight"`
+	Capacity int   `json:"capacity"`
+	Rejected int64 `json:"rejected"` // 启动以来因队列已满被拒绝的请求数
+}
+
+// NewUpstreamLimiter 创建容量为 depth 的限流器；depth 不大于 0 时返回 nil，表示不限制。
+func NewUpstreamLimiter(depth int) *UpstreamLimiter {
+	if depth <= 0 {
+		return nil
+	}
+	return &UpstreamLimiter{slots: make(chan struct{}, depth)}
+}
+
+// Stats 返回当前占用的名额、容量与累计拒绝数。
+func (l *UpstreamLimiter) Stats() UpstreamQueueStats {
+	return UpstreamQueueStats{
+		InFlight: len(l.slots),
+		Capacity: cap(l.slots),
+		Rejected: l.rejected.Load(),
+	}
+}
+
+// WithUpstreamLimiter 让客户端的所有上游请求经 l 限流；两个客户端传入同一个 l 即共享名额。nil 表示不限制。
+func WithUpstreamLimiter(l *UpstreamLimiter) Option {
+	return func(o *clientOptions) {
+		o.limiter = l
+	}
+}
+
+// newHTTPClient 创建客户端使用的 http.Client，连接池按选项配置，配置了限流器时再包裹限流 Transport。
+// 超时按请求类型在每次调用时设置。
+func (o clientOptions) newHTTPClient() *http.Client {
+	var transport http.RoundTripper = o.newTransport()
+	if o.transport != nil {
+		transport = o.transport
+	}
+	if o.limiter == nil {
+		return &http.Client{Transport: transport}
+	}
+	return &http.Client{Transport: limitedTransport{base: transport, limiter: o.limiter}}
+}
+
+// limitedTransport 在每次往返前占用一个名额，直到响应体关闭或请求失败时归还。
+// 重定向的每一跳单独占用名额，上一跳的响应体在发起下一跳前已关闭。
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *UpstreamLimiter
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.limiter.slots <- struct{}{}:
+	default:
+		t.limiter.rejected.Add(1)
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, ErrOverloaded
+	}
+	release := sync.OnceFunc(func() { <-t.limiter.slots })
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody 在关闭时归还限流名额。
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
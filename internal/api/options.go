@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// DefaultUserAgent 是未配置时发送给上游 API 的 User-Agent。
+const DefaultUserAgent = "OtakuChartMaker/1.0 (https://github.com/Aytrw/otaku-chart-maker)"
+
+// 默认超时，与拆分前共用的 15 秒客户端超时保持一致。
+const (
+	DefaultAPITimeout      = 15 * time.Second
+	DefaultDownloadTimeout = 15 * time.Second
+)
+
+// Option 调整客户端构造参数，Bangumi 与 VNDB 客户端共用。
+type Option func(*clientOptions)
+
+// clientOptions 汇总客户端的可配置项。
+type clientOptions struct {
+	userAgent       string
+	sourceSubdirs   bool
+	verifyImages    bool          // 保存前完整解码下载的图片，拒绝无法解码的数据
+	apiTimeout      time.Duration // 元数据请求（搜索/浏览/详情）的单次超时
+	downloadTimeout time.Duration // 封面下载的单次超时
+	coverHosts      []string      // 允许下载封面的域名白名单
+	anyCoverHost    bool          // 不限制封面域名
+
+	filenameTemplate *template.Template // 封面文件名模板，nil 表示沿用请求中的文件名
+	filenameMaxLen   int                // 封面文件名（含扩展名）的最大字节数
+
+	browseLimit    int // Bangumi 浏览单页上限，同时是智能推荐结果池的大小
+	vndbMaxResults int // VNDB 单次查询结果上限
+
+	bgmToken string // Bangumi Access Token 初始值，仅 Bangumi 客户端使用
+
+	anidbTitlesFile string // AniDB 标题列表的本地副本，空表示只保存在内存，仅 AniDB 客户端使用
+
+	cacheIgnore map[string]bool // 生成缓存键时忽略的请求体字段
+	cache       Cache           // 上游响应缓存，nil 表示各客户端使用各自的内存缓存
+
+	limiter *UpstreamLimiter // 上游并发请求限制，nil 表示不限制
+
+	maxIdleConnsPerHost int // 每个上游主机保留的空闲连接数
+	maxConnsPerHost     int // 每个上游主机的最大连接数，0 表示不限制
+
+	transport http.RoundTripper // 替换默认 Transport，nil 表示按连接池参数创建
+}
+
+// WithUserAgent 设置请求使用的 User-Agent；Bangumi 建议在其中附上联系方式。空值保持默认。
+func WithUserAgent(ua string) Option {
+	return func(o *clientOptions) {
+		if ua = strings.TrimSpace(ua); ua != "" {
+			o.userAgent = ua
+		}
+	}
+}
+
+// WithAPITimeout 设置元数据请求的单次超时；非正值保持默认。
+func WithAPITimeout(d time.Duration) Option {
+	return func(o *clientOptions) {
+		if d > 0 {
+			o.apiTimeout = d
+		}
+	}
+}
+
+// WithDownloadTimeout 设置封面下载的单次超时；非正值保持默认。
+func WithDownloadTimeout(d time.Duration) Option {
+	return func(o *clientOptions) {
+		if d > 0 {
+			o.downloadTimeout = d
+		}
+	}
+}
+
+// WithBrowseLimit 设置 Bangumi 浏览的单页上限（1-100）；超出范围保持默认。
+// 智能推荐每组查询按此上限取结果池，调小可减少单次请求的上游负载。
+func WithBrowseLimit(n int) Option {
+	return func(o *clientOptions) {
+		if n >= 1 && n <= MaxBrowseLimit {
+			o.browseLimit = n
+		}
+	}
+}
+
+// WithVNDBMaxResults 设置 VNDB 单次查询的结果上限（1-100）；超出范围保持默认。
+func WithVNDBMaxResults(n int) Option {
+	return func(o *clientOptions) {
+		if n >= 1 && n <= VNDBMaxResults {
+			o.vndbMaxResults = n
+		}
+	}
+}
+
+// WithSourceSubdirs 让下载的封面按数据源存入 covers/<source>/ 子目录，避免跨源同名冲突。
+func WithSourceSubdirs(enabled bool) Option {
+	return func(o *clientOptions) {
+		o.sourceSubdirs = enabled
+	}
+}
+
+// WithVerifyImages 设置是否在保存前解码校验下载的封面（默认开启）。
+// 关闭后只检查 Content-Type，节省大图解码的开销。
+func WithVerifyImages(enabled bool) Option {
+	return func(o *clientOptions) {
+		o.verifyImages = enabled
+	}
+}
+
+// checkImageData 在启用校验时确认 data 能解码为图片。
+func (o clientOptions) checkImageData(data []byte) error {
+	if !o.verifyImages {
+		return nil
+	}
+	if err := imaging.Verify(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("下载的图片无法解码: %w", err)
+	}
+	return nil
+}
+
+// coverDir 返回数据源封面的保存目录，以及相对 covers 目录的路径前缀（以 / 结尾，平铺时为空）。
+func (o clientOptions) coverDir(coversDir, source string) (dir, prefix string) {
+	if !o.sourceSubdirs {
+		return coversDir, ""
+	}
+	return filepath.Join(coversDir, source), source + "/"
+}
+
+// buildOptions 在默认值基础上依次应用选项。
+func buildOptions(opts []Option) clientOptions {
+	o := clientOptions{
+		userAgent:       DefaultUserAgent,
+		apiTimeout:      DefaultAPITimeout,
+		downloadTimeout: DefaultDownloadTimeout,
+		coverHosts:      DefaultCoverHosts,
+		browseLimit:     MaxBrowseLimit,
+		vndbMaxResults:  VNDBMaxResults,
+		filenameMaxLen:  DefaultFilenameMaxLen,
+		verifyImages:    true,
+
+		maxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+		maxConnsPerHost:     DefaultMaxConnsPerHost,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// 封面文件与目录的默认权限。
+const (
+	DefaultCoverFileMode os.FileMode = 0o644
+	DefaultCoverDirMode  os.FileMode = 0o755
+)
+
+// 写入 covers 目录时使用的权限，进程内所有客户端与上传共用，由 SetCoverModes 在启动时设置。
+var (
+	coverFileMode atomic.Uint32
+	coverDirMode  atomic.Uint32
+)
+
+func init() {
+	SetCoverModes(DefaultCoverFileMode, DefaultCoverDirMode)
+}
+
+// SetCoverModes 设置新建封面文件与目录的权限。
+// 写入后会显式 chmod，使组可写等权限不受进程 umask 影响，便于多用户共享的封面库。
+func SetCoverModes(file, dir os.FileMode) {
+	coverFileMode.Store(uint32(file.Perm()))
+	coverDirMode.Store(uint32(dir.Perm()))
+}
+
+// CoverFileMode 返回当前的封面文件权限。
+func CoverFileMode() os.FileMode { return os.FileMode(coverFileMode.Load()) }
+
+// CoverDirMode 返回当前的封面目录权限。
+func CoverDirMode() os.FileMode { return os.FileMode(coverDirMode.Load()) }
+
+// WriteCoverFile 按封面文件权限写入 path。
+func WriteCoverFile(path string, data []byte) error {
+	mode := CoverFileMode()
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	return os.Chmod(path, mode)
+}
+
+// MkdirCovers 按封面目录权限创建 dir 及其缺失的上级目录。
+// 只对本次新建的目录设置权限，已存在的目录保持不变。
+func MkdirCovers(dir string) error {
+	mode := CoverDirMode()
+	var created []string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil {
+			break
+		}
+		created = append(created, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	for _, d := range created {
+		if err := os.Chmod(d, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
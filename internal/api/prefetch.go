@@ -0,0 +1,25 @@
+package api
+
+// prefetchQueries 返回启动预热的常用浏览查询：各类型排行首页，以及智能填充常用的动画热度池
+// （与 Recommend 的查询参数一致，才能命中同一缓存）。
+func (c *Client) prefetchQueries() []BrowseRequest {
+	return []BrowseRequest{
+		{SubjectType: "anime", Sort: "rank"},
+		{SubjectType: "manga", Sort: "rank"},
+		{SubjectType: "novel", Sort: "rank"},
+		{SubjectType: "game", Sort: "rank"},
+		{SubjectType: "anime", Sort: "heat", Limit: c.opts.browseLimit, MinRating: 5},
+	}
+}
+
+// Prefetch 依次执行常用浏览查询以填充缓存，返回成功数与总数。
+// 串行执行以免启动时对 Bangumi 造成突发流量。
+func (c *Client) Prefetch() (ok, total int) {
+	queries := c.prefetchQueries()
+	for _, req := range queries {
+		if _, err := c.Browse(req); err == nil {
+			ok++
+		}
+	}
+	return ok, len(queries)
+}
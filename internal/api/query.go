@@ -0,0 +1,391 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 查询 DSL 的年份取值范围。
+const (
+	queryMinYear = 1900
+	queryMaxYear = 2100
+)
+
+// querySorts 是 DSL 支持的排序字段。
+var querySorts = map[string]bool{
+	"match": true, "heat": true, "rank": true, "score": true, "date": true,
+}
+
+// vndbQuerySorts 将 DSL 排序字段映射到 VNDB 排序字段及其默认是否倒序。
+var vndbQuerySorts = map[string]struct {
+	field   string
+	reverse bool
+}{
+	"match": {"searchrank", false},
+	"heat":  {"votecount", true},
+	"rank":  {"rating", true},
+	"score": {"rating", true},
+	"date":  {"released", true},
+}
+
+// vndbTagIDPattern 校验 VNDB 标签 ID（如 g32）。
+var vndbTagIDPattern = regexp.MustCompile(`^g[0-9]+$`)
+
+// vndbPlatforms 是 VNDB 发行平台代码。
+var vndbPlatforms = map[string]bool{
+	"win": true, "lin": true, "mac": true, "web": true, "tdo": true, "ios": true, "and": true,
+	"mob": true, "bdp": true, "dos": true, "dvd": true, "drc": true, "nes": true, "sfc": true,
+	"fm7": true, "fm8": true, "fmt": true, "gba": true, "gbc": true, "msx": true, "nds": true,
+	"swi": true, "sw2": true, "wii": true, "wiu": true, "n3d": true, "p88": true, "p98": true,
+	"pce": true, "pcf": true, "psp": true, "ps1": true, "ps2": true, "ps3": true, "ps4": true,
+	"ps5": true, "psv": true, "smd": true, "scd": true, "sat": true, "vnd": true, "x1s": true,
+	"x68": true, "xb1": true, "xb3": true, "xbo": true, "xxs": true, "oth": true,
+}
+
+// VNDB 游玩时长分类（length 过滤器）的取值范围：
+// 1 极短（< 2 小时）、2 短（2-10 小时）、3 中等（10-30 小时）、4 长（30-50 小时）、5 极长（> 50 小时）。
+const (
+	vndbLengthMin = 1
+	vndbLengthMax = 5
+)
+
+// Query 是统一的结构化检索请求，按 Source 路由到 Bangumi 或 VNDB。
+type Query struct {
+	Source      string     `json:"source"` // bgm（默认）或 vndb
+	Keyword     string     `json:"keyword"`
+	Type        string     `json:"type"` // 仅 Bangumi：anime/manga/novel/game
+	Tags        []string   `json:"tags"`
+	ExcludeTags []string   `json:"excludeTags"`
+	MinScore    float64    `json:"minScore"` // 0-10，0 表示不筛选
+	YearRange   *YearRange `json:"yearRange"`
+	Sort        string     `json:"sort"`  // match/heat/rank/score/date
+	Order       string     `json:"order"` // asc/desc，仅 VNDB 支持
+	Offset      int        `json:"offset"`
+	Limit       int        `json:"limit"`
+
+	// 以下仅 VNDB 支持。Platforms 为发行平台代码（如 win、ps4、swi），多个平台之间为"或"；
+	// LengthMax 为游玩时长分类上限（1-5，见 vndbLengthMin），0 表示不限。
+	Platforms []string `json:"platforms,omitempty"`
+	LengthMax int      `json:"lengthMax,omitempty"`
+}
+
+// YearRange 是按年份筛选的闭区间，0 表示该端不限。
+type YearRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Card 是不同数据源结果统一后的卡片格式。
+type Card struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	NameCN    string  `json:"name_cn"`
+	Cover     string  `json:"cover"`
+	Score     float64 `json:"score"`
+	TypeLabel string  `json:"type_label,omitempty"`
+	Source    string  `json:"source"`
+}
+
+// QueryResult 是统一检索的响应。Backend 标明实际使用的接口（legacy/v0/vndb）。
+type QueryResult struct {
+	Results []Card `json:"results"`
+	Total   int    `json:"total"`
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+	Source  string `json:"source"`
+	Backend string `json:"backend"`
+
+	// Excluded 是本页按 excludeTags 在本地剔除的条数，Total 已扣除。此时一页可能少于 Limit 条，翻页仍按 Offset+Limit 前进。
+	Excluded int `json:"excluded,omitempty"`
+}
+
+// Normalize 规范化查询参数并校验取值与组合，错误均为 ErrBadRequest。
+func (q *Query) Normalize() error {
+	q.Source = strings.ToLower(strings.TrimSpace(q.Source))
+	if q.Source == "" {
+		q.Source = SourceBangumi
+	}
+	q.Keyword = strings.TrimSpace(q.Keyword)
+	q.Type = strings.TrimSpace(q.Type)
+	q.Sort = strings.ToLower(strings.TrimSpace(q.Sort))
+	q.Order = strings.ToLower(strings.TrimSpace(q.Order))
+	q.Tags = normalizeTags(q.Tags)
+	q.ExcludeTags = normalizeTags(q.ExcludeTags)
+	if q.Limit == 0 {
+		q.Limit = defaultLimit
+	}
+
+	if q.Source != SourceBangumi && q.Source != SourceVNDB {
+		return badRequestError(fmt.Sprintf("source 无效: %q，可选 bgm 或 vndb", q.Source))
+	}
+	if q.Limit < 1 || q.Limit > MaxBrowseLimit {
+		return badRequestError(fmt.Sprintf("limit 须在 1-%d 之间", MaxBrowseLimit))
+	}
+	if q.Offset < 0 {
+		return badRequestError("offset 不能为负数")
+	}
+	if q.MinScore < 0 || q.MinScore > 10 {
+		return badRequestError("minScore 须在 0-10 之间")
+	}
+	if q.Sort != "" && !querySorts[q.Sort] {
+		return badRequestError(fmt.Sprintf("sort 无效: %q，可选 match/heat/rank/score/date", q.Sort))
+	}
+	if q.Sort == "match" && q.Keyword == "" {
+		return badRequestError("sort=match 需要同时提供 keyword")
+	}
+	if q.Order != "" && q.Order != "asc" && q.Order != "desc" {
+		return badRequestError(fmt.Sprintf("order 无效: %q，可选 asc 或 desc", q.Order))
+	}
+	if yr := q.YearRange; yr != nil {
+		for _, y := range []int{yr.From, yr.To} {
+			if y != 0 && (y < queryMinYear || y > queryMaxYear) {
+				return badRequestError(fmt.Sprintf("yearRange 年份须在 %d-%d 之间", queryMinYear, queryMaxYear))
+			}
+		}
+		if yr.From > 0 && yr.To > 0 && yr.From > yr.To {
+			return badRequestError("yearRange.from 不能晚于 yearRange.to")
+		}
+	}
+	for _, t := range q.Tags {
+		if containsTagFold(q.ExcludeTags, t) {
+			return badRequestError("标签同时出现在 tags 与 excludeTags 中: " + t)
+		}
+	}
+
+	if q.Source == SourceVNDB {
+		return q.validateVNDB()
+	}
+	return q.validateBangumi()
+}
+
+// validateBangumi 校验 Bangumi 不支持的参数组合。
+func (q *Query) validateBangumi() error {
+	if q.Type != "" {
+		if _, ok := TypeMap[q.Type]; !ok {
+			return badRequestError(fmt.Sprintf("type 无效: %q，可选 anime/manga/novel/game", q.Type))
+		}
+	}
+	if q.Sort == "date" {
+		return badRequestError("Bangumi 不支持 sort=date")
+	}
+	if q.Order != "" {
+		return badRequestError("Bangumi 不支持自定义 order，排序方向由 sort 决定")
+	}
+	if len(q.Platforms) > 0 || q.LengthMax != 0 {
+		return badRequestError("Bangumi 不支持 platforms 与 lengthMax 筛选")
+	}
+	if q.Keyword == "" && len(q.Tags) == 0 && q.Type == "" {
+		return badRequestError("Bangumi 查询至少需要 keyword、tags 或 type 之一")
+	}
+	return nil
+}
+
+// validateVNDB 校验 VNDB 查询：标签须为 ID，分页须按页对齐。
+func (q *Query) validateVNDB() error {
+	if q.Type != "" {
+		return badRequestError("VNDB 查询不支持 type 参数")
+	}
+	for _, list := range [][]string{q.Tags, q.ExcludeTags} {
+		for i, t := range list {
+			t = strings.ToLower(t)
+			if !vndbTagIDPattern.MatchString(t) {
+				return badRequestError("VNDB 标签须为标签 ID（如 g32）: " + t)
+			}
+			list[i] = t
+		}
+	}
+	platforms := make([]string, 0, len(q.Platforms))
+	for _, p := range q.Platforms {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if !vndbPlatforms[p] {
+			return badRequestError("未知的 VNDB 平台代码: " + p)
+		}
+		if !slices.Contains(platforms, p) {
+			platforms = append(platforms, p)
+		}
+	}
+	q.Platforms = platforms
+	if q.LengthMax != 0 && (q.LengthMax < vndbLengthMin || q.LengthMax > vndbLengthMax) {
+		return badRequestError(fmt.Sprintf("lengthMax 须在 %d-%d 之间", vndbLengthMin, vndbLengthMax))
+	}
+	if q.Offset%q.Limit != 0 {
+		return badRequestError("VNDB 按页查询，offset 须为 limit 的整数倍")
+	}
+	if q.Keyword == "" && len(q.Tags) == 0 && len(q.Platforms) == 0 && q.LengthMax == 0 {
+		return badRequestError("VNDB 查询至少需要 keyword、tags、platforms 或 lengthMax 之一")
+	}
+	return nil
+}
+
+// hasFilters 判断查询是否使用了关键词以外的筛选条件。
+func (q *Query) hasFilters() bool {
+	return len(q.Tags) > 0 || len(q.ExcludeTags) > 0 || q.MinScore > 0 ||
+		q.YearRange != nil && (q.YearRange.From > 0 || q.YearRange.To > 0)
+}
+
+// Query 执行 Bangumi 结构化查询。仅关键词（及不含子类的类型）且首页时走旧版搜索，
+// 其余走 v0 浏览接口。调用方需先执行 Normalize。
+func (c *Client) Query(q Query) (*QueryResult, error) {
+	if q.Limit > c.opts.browseLimit {
+		return nil, badRequestError(fmt.Sprintf("limit 不能超过 %d", c.opts.browseLimit))
+	}
+	st, hasType := TypeMap[q.Type]
+	if q.Keyword != "" && !q.hasFilters() && q.Sort == "" && q.Offset == 0 && st.MetaTag == "" {
+		bgmType := st.TypeID
+		if !hasType {
+			bgmType = 2 // 与 /api/search 一致，默认搜索动画
+		}
+		results, err := c.Search(q.Keyword, bgmType)
+		if err != nil {
+			return nil, err
+		}
+		if len(results) > q.Limit {
+			results = results[:q.Limit]
+		}
+		cards := make([]Card, 0, len(results))
+		for _, it := range results {
+			cards = append(cards, Card{
+				ID:        strconv.Itoa(it.ID),
+				Name:      it.Name,
+				NameCN:    it.NameCN,
+				Cover:     it.Cover,
+				TypeLabel: TypeLabels[bgmType],
+				Source:    SourceBangumi,
+			})
+		}
+		return &QueryResult{
+			Results: cards, Total: len(cards), Limit: q.Limit,
+			Source: SourceBangumi, Backend: "legacy",
+		}, nil
+	}
+
+	req := BrowseRequest{
+		Tags:        q.Tags,
+		ExcludeTags: q.ExcludeTags,
+		Keyword:     q.Keyword,
+		Offset:      q.Offset,
+		Limit:       q.Limit,
+		Sort:        q.Sort,
+		SubjectType: q.Type,
+		MinRating:   q.MinScore,
+	}
+	if req.Sort == "" {
+		req.Sort = "rank"
+		if q.Keyword != "" {
+			req.Sort = "match"
+		}
+	}
+	if q.YearRange != nil {
+		req.YearFrom, req.YearTo = q.YearRange.From, q.YearRange.To
+	}
+
+	resp, err := c.Browse(req)
+	if err != nil {
+		return nil, err
+	}
+	cards := make([]Card, 0, len(resp.Results))
+	for _, it := range resp.Results {
+		cards = append(cards, Card{
+			ID:        strconv.Itoa(it.ID),
+			Name:      it.Name,
+			NameCN:    it.NameCN,
+			Cover:     it.Cover,
+			Score:     it.Score,
+			TypeLabel: it.TypeLabel,
+			Source:    SourceBangumi,
+		})
+	}
+	return &QueryResult{
+		Results: cards, Total: resp.Total, Offset: resp.Offset, Limit: resp.Limit,
+		Source: SourceBangumi, Backend: "v0", Excluded: resp.Excluded,
+	}, nil
+}
+
+// Query 执行 VNDB 结构化查询，将 DSL 条件转换为 Kana v2 过滤器。调用方需先执行 Normalize。
+func (c *VNDBClient) Query(q Query) (*QueryResult, error) {
+	if q.Limit > c.opts.vndbMaxResults {
+		return nil, badRequestError(fmt.Sprintf("limit 不能超过 %d", c.opts.vndbMaxResults))
+	}
+	filters := []any{"and"}
+	if q.Keyword != "" {
+		filters = append(filters, []any{"search", "=", q.Keyword})
+	}
+	for _, t := range q.Tags {
+		filters = append(filters, []any{"tag", "=", t})
+	}
+	for _, t := range q.ExcludeTags {
+		filters = append(filters, []any{"tag", "!=", t})
+	}
+	if q.MinScore > 0 {
+		// VNDB 评分为 10-100
+		filters = append(filters, []any{"rating", ">=", int(q.MinScore * 10)})
+	}
+	if yr := q.YearRange; yr != nil {
+		if yr.From > 0 {
+			filters = append(filters, []any{"released", ">=", fmt.Sprintf("%04d-01-01", yr.From)})
+		}
+		if yr.To > 0 {
+			filters = append(filters, []any{"released", "<", fmt.Sprintf("%04d-01-01", yr.To+1)})
+		}
+	}
+	if len(q.Platforms) == 1 {
+		filters = append(filters, []any{"platform", "=", q.Platforms[0]})
+	} else if len(q.Platforms) > 1 {
+		anyPlatform := []any{"or"}
+		for _, p := range q.Platforms {
+			anyPlatform = append(anyPlatform, []any{"platform", "=", p})
+		}
+		filters = append(filters, anyPlatform)
+	}
+	if q.LengthMax > 0 {
+		filters = append(filters, []any{"length", "<=", q.LengthMax})
+	}
+	if len(filters) == 2 {
+		filters = filters[1].([]any)
+	}
+
+	sortKey := q.Sort
+	if sortKey == "" {
+		sortKey = "score"
+		if q.Keyword != "" {
+			sortKey = "match"
+		}
+	}
+	s := vndbQuerySorts[sortKey]
+	reverse := s.reverse
+	if q.Order != "" {
+		reverse = q.Order == "desc"
+	}
+
+	resp, err := c.QueryVN(VNDBQueryRequest{
+		Filters: filters,
+		Fields:  vndbDefaultFields,
+		Sort:    s.field,
+		Reverse: reverse,
+		Results: q.Limit,
+		Page:    q.Offset/q.Limit + 1,
+		Count:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cards := make([]Card, 0, len(resp.Results))
+	for _, vn := range resp.Results {
+		cards = append(cards, Card{
+			ID:     vn.ID,
+			Name:   vn.Title,
+			NameCN: vn.Alttitle,
+			Cover:  vn.Image.BestURL(),
+			Score:  vn.Rating / 10,
+			Source: SourceVNDB,
+		})
+	}
+	return &QueryResult{
+		Results: cards, Total: resp.Count, Offset: q.Offset, Limit: q.Limit,
+		Source: SourceVNDB, Backend: "vndb",
+	}, nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// RatingDistribution 是条目的评分分布。Counts[i] 是打 i+1 分的人数（1~10 分）。
+type RatingDistribution struct {
+	ID     int     `json:"id"`
+	Score  float64 `json:"score"`
+	Rank   int     `json:"rank,omitempty"`
+	Total  int     `json:"total"` // 总评分人数
+	Counts [10]int `json:"counts"`
+}
+
+// GetSubjectRating 获取条目的评分分布。与 GetSubject 共用 v0 条目详情的缓存，不产生额外请求。
+func (c *Client) GetSubjectRating(id int) (*RatingDistribution, error) {
+	if id <= 0 {
+		return nil, badRequestError("无效的条目 ID")
+	}
+
+	data, err := c.cachedGet(fmt.Sprintf("%s%d", bgmV0SubjectURL, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		ID     int `json:"id"`
+		Rating struct {
+			Score float64        `json:"score"`
+			Rank  int            `json:"rank"`
+			Total int            `json:"total"`
+			Count map[string]int `json:"count"` // 键为 "1"~"10"
+		} `json:"rating"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析条目评分失败: %w", err)
+	}
+
+	out := &RatingDistribution{
+		ID:    raw.ID,
+		Score: raw.Rating.Score,
+		Rank:  raw.Rating.Rank,
+		Total: raw.Rating.Total,
+	}
+	sum := 0
+	for k, n := range raw.Rating.Count {
+		score, err := strconv.Atoi(k)
+		if err != nil || score < 1 || score > 10 || n < 0 {
+			continue
+		}
+		out.Counts[score-1] = n
+		sum += n
+	}
+	if out.Total == 0 {
+		out.Total = sum
+	}
+	return out, nil
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
-	"sort"
+	"cmp"
+	"fmt"
+	"strconv"
 	"strings"
 	"sync"
+	"unicode"
 )
 
 // RecommendCellSpec 描述单个格子的推荐参数。
@@ -20,6 +23,9 @@ type RecommendCellSpec struct {
 type RecommendRequest struct {
 	Cells      []RecommendCellSpec `json:"cells"`
 	ExcludeIDs []int               `json:"excludeIDs"`
+
+	// AutoDownload 为 true 时同时下载每个推荐结果的封面，省去前端逐个下载的往返。
+	AutoDownload bool `json:"autoDownload,omitempty"`
 }
 
 // RecommendCellResult 是单个格子的推荐结果。
@@ -27,59 +33,120 @@ type RecommendCellResult struct {
 	Label string        `json:"label"`
 	Item  *BrowseResult `json:"item,omitempty"`
 	Found bool          `json:"found"`
+	// Error 非空表示该格子的查询失败（如网络错误），可重试；为空且 Found 为 false 表示确实没有结果。
+	Error string `json:"error,omitempty"`
+
+	// 以下字段仅在 AutoDownload 时填充；下载失败不影响推荐结果本身。
+	Filename      string `json:"filename,omitempty"`
+	Path          string `json:"path,omitempty"`
+	Reused        bool   `json:"reused,omitempty"`    // 封面此前已下载，本次没有新增文件
+	Refreshed     bool   `json:"refreshed,omitempty"` // 封面此前已下载但上游有更新，已原地覆盖
+	DownloadError string `json:"downloadError,omitempty"`
 }
 
 // RecommendResponse 是批量推荐响应。
 type RecommendResponse struct {
 	Results []RecommendCellResult `json:"results"`
+	// PartialFailure 表示至少一个查询分组失败，对应格子的 Error 给出原因。
+	PartialFailure bool `json:"partialFailure"`
 }
 
 // recommendConcurrency 控制并发请求 Bangumi API 的最大 goroutine 数量。
 const recommendConcurrency = 8
 
+// recommendDownloadConcurrency 控制自动下载封面的最大并发数，避免对图床造成突发压力。
+const recommendDownloadConcurrency = 4
+
+// recommendNameMaxRunes 是自动下载封面文件名中显示名部分的最大长度。
+const recommendNameMaxRunes = 60
+
 // recommendQueryKey 用于合并相同查询参数的 API 请求。
 type recommendQueryKey struct {
-	tags        string
+	tags        string // 标签的分组键，与发给上游的写法一致
 	sortBy      string
 	subjectType string
 }
 
-// makeRecommendKey 根据推荐参数生成查询分组键。
-// 相同键的格子共享一次 API 请求，节省网络开销。
-func makeRecommendKey(spec RecommendCellSpec) recommendQueryKey {
-	sorted := make([]string, len(spec.Tags))
-	copy(sorted, spec.Tags)
-	sort.Strings(sorted)
+// makeRecommendKey 根据推荐参数生成查询分组键与规范化后的标签。
+// 相同键的格子共享一次 API 请求，节省网络开销；标签写法（含大小写）不同的格子分开请求。
+func makeRecommendKey(spec RecommendCellSpec) (recommendQueryKey, []string) {
+	tags := normalizeTags(spec.Tags)
 	subjectType := spec.SubjectType
-	if len(spec.Tags) == 0 && subjectType == "" {
+	if len(tags) == 0 && subjectType == "" {
 		subjectType = "anime"
 	}
 	return recommendQueryKey{
-		tags:        strings.Join(sorted, "\x00"),
+		tags:        tagsKey(tags),
 		sortBy:      spec.Sort,
 		subjectType: subjectType,
+	}, tags
+}
+
+// RecommendCellProblem 描述单个格子参数的校验问题。
+type RecommendCellProblem struct {
+	Index    int      `json:"index"`
+	Label    string   `json:"label"`
+	Problems []string `json:"problems"`
+}
+
+// Problems 返回格子参数的校验问题，合法时返回 nil。
+// sort 与 subjectType 可留空（分别默认为 rank 与按标签不限类型），填写时必须是已知取值。
+func (spec RecommendCellSpec) Problems() []string {
+	var problems []string
+	if spec.Sort != "" && !validSorts[spec.Sort] {
+		problems = append(problems, "未知的排序方式: "+spec.Sort)
+	}
+	if spec.SubjectType != "" {
+		if _, ok := TypeMap[spec.SubjectType]; !ok {
+			problems = append(problems, "未知的作品类型: "+spec.SubjectType)
+		}
+	}
+	if spec.Offset < 0 {
+		problems = append(problems, "offset 不能为负数: "+strconv.Itoa(spec.Offset))
 	}
+	return problems
+}
+
+// ValidateRecommend 逐个校验推荐请求中的格子，只返回存在问题的格子；不发起任何上游请求。
+// Recommend 执行前做同样的校验。
+func ValidateRecommend(req RecommendRequest) []RecommendCellProblem {
+	var out []RecommendCellProblem
+	for i, spec := range req.Cells {
+		if problems := spec.Problems(); len(problems) > 0 {
+			out = append(out, RecommendCellProblem{Index: i, Label: spec.Label, Problems: problems})
+		}
+	}
+	return out
 }
 
 // Recommend 批量为多个格子推荐作品。
-// 相同查询条件的格子共享一次 API 请求，结果全局去重。
+// 相同查询条件的格子共享一次 API 请求，结果全局去重。任一格子参数不合法时返回 badRequestError（见 ValidateRecommend）。
 func (c *Client) Recommend(req RecommendRequest) (*RecommendResponse, error) {
 	if len(req.Cells) == 0 {
 		return &RecommendResponse{Results: []RecommendCellResult{}}, nil
 	}
+	if problems := ValidateRecommend(req); len(problems) > 0 {
+		p := problems[0]
+		return nil, badRequestError(fmt.Sprintf("第 %d 个格子参数不合法: %s", p.Index+1, strings.Join(p.Problems, "；")))
+	}
 
-	// 1. 按查询条件分组，相同 (tags, sort, type) 的格子共享一次请求
+	// 1. 按查询条件分组，相同 (tags, sort, type) 的格子共享一次请求。
+	// groups 按各分组首次出现的格子顺序排列，请求的发起顺序不受 map 遍历顺序影响，
+	// 相同请求总是得到相同的处理过程与分配结果。
 	type groupInfo struct {
 		key     recommendQueryKey
+		tags    []string
 		indices []int
 	}
 	groupMap := map[recommendQueryKey]*groupInfo{}
+	var groups []*groupInfo
 	for i, spec := range req.Cells {
-		key := makeRecommendKey(spec)
+		key, tags := makeRecommendKey(spec)
 		g, ok := groupMap[key]
 		if !ok {
-			g = &groupInfo{key: key}
+			g = &groupInfo{key: key, tags: tags}
 			groupMap[key] = g
+			groups = append(groups, g)
 		}
 		g.indices = append(g.indices, i)
 	}
@@ -88,45 +155,46 @@ func (c *Client) Recommend(req RecommendRequest) (*RecommendResponse, error) {
 	type fetchResult struct {
 		key     recommendQueryKey
 		results []BrowseResult
+		err     error
 	}
-	ch := make(chan fetchResult, len(groupMap))
+	ch := make(chan fetchResult, len(groups))
 	sem := make(chan struct{}, recommendConcurrency)
 	var wg sync.WaitGroup
 
-	for _, g := range groupMap {
+	for _, g := range groups {
+		// 在启动协程前占用名额，使请求按 groups 的顺序发起
+		sem <- struct{}{}
 		wg.Add(1)
 		go func(info *groupInfo) {
 			defer wg.Done()
-			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			var tags []string
-			if info.key.tags != "" {
-				tags = strings.Split(info.key.tags, "\x00")
-			}
 			browseReq := BrowseRequest{
-				Tags:        tags,
+				Tags:        info.tags,
 				Sort:        info.key.sortBy,
 				SubjectType: info.key.subjectType,
-				Limit:       maxBrowseLimit,
+				Limit:       c.opts.browseLimit,
 				MinRating:   5, // 过滤低评分作品，提升推荐质量
 			}
 			resp, err := c.Browse(browseReq)
-			if err == nil && resp != nil {
-				ch <- fetchResult{info.key, resp.Results}
-			} else {
-				ch <- fetchResult{info.key, nil}
+			if err != nil {
+				ch <- fetchResult{key: info.key, err: err}
+				return
 			}
+			ch <- fetchResult{key: info.key, results: resp.Results}
 		}(g)
 	}
 	go func() { wg.Wait(); close(ch) }()
 
-	// 收集各分组的查询结果
+	// 收集各分组的查询结果与错误
 	pool := map[recommendQueryKey][]BrowseResult{}
+	groupErrs := map[recommendQueryKey]error{}
 	for fr := range ch {
-		if fr.results != nil {
-			pool[fr.key] = fr.results
+		if fr.err != nil {
+			groupErrs[fr.key] = fr.err
+			continue
 		}
+		pool[fr.key] = fr.results
 	}
 
 	// 3. 全局去重分配：按格子顺序依次从对应池中取未使用的结果
@@ -138,7 +206,11 @@ func (c *Client) Recommend(req RecommendRequest) (*RecommendResponse, error) {
 	results := make([]RecommendCellResult, len(req.Cells))
 	for i, spec := range req.Cells {
 		results[i] = RecommendCellResult{Label: spec.Label}
-		key := makeRecommendKey(spec)
+		key, _ := makeRecommendKey(spec)
+		if err := groupErrs[key]; err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
 		items, ok := pool[key]
 		if !ok || len(items) == 0 {
 			continue
@@ -162,5 +234,62 @@ func (c *Client) Recommend(req RecommendRequest) (*RecommendResponse, error) {
 		}
 	}
 
-	return &RecommendResponse{Results: results}, nil
+	if req.AutoDownload {
+		c.downloadRecommended(results)
+	}
+
+	return &RecommendResponse{Results: results, PartialFailure: len(groupErrs) > 0}, nil
+}
+
+// downloadRecommended 并发下载推荐结果的封面，把本地路径或错误写回对应结果。
+func (c *Client) downloadRecommended(results []RecommendCellResult) {
+	sem := make(chan struct{}, recommendDownloadConcurrency)
+	var wg sync.WaitGroup
+	for i := range results {
+		item := results[i].Item
+		if item == nil || item.Cover == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(r *RecommendCellResult) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			dl, err := c.DownloadCover(item.Cover, recommendCoverName(*item), CoverInfo{
+				ID:    strconv.Itoa(item.ID),
+				Title: cmp.Or(item.NameCN, item.Name),
+			})
+			if err != nil {
+				r.DownloadError = err.Error()
+				return
+			}
+			r.Filename = dl.Filename
+			r.Path = dl.Path
+			r.Reused = dl.Reused
+			r.Refreshed = dl.Refreshed
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// recommendCoverName 生成与前端一致的封面文件名：显示名（空白与不安全字符替换为下划线）加条目 ID。
+func recommendCoverName(item BrowseResult) string {
+	return CoverBaseName(cmp.Or(item.NameCN, item.Name), strconv.Itoa(item.ID))
+}
+
+// CoverBaseName 生成与前端一致的封面文件名（不含扩展名）：标题中的空白与不安全字符替换为下划线，
+// 截短后追加 "_" 与条目 ID。标题为空时使用 "cover"。
+func CoverBaseName(title, id string) string {
+	name := title
+	if name == "" {
+		name = "cover"
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	return truncateRunes(name, recommendNameMaxRunes) + "_" + id
 }
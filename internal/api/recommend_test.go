@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recommendPools 是各标签组合在上游的结果（按排名排列的条目 ID），不同组之间有重叠。
+var recommendPools = map[string][]int{
+	"fate":  {1, 2, 3, 4},
+	"mecha": {2, 1, 5, 6},
+	"sf":    {5, 7, 2, 8},
+}
+
+// stubRecommendUpstream 按请求中的标签返回对应结果池，每次应答随机延迟以打乱分组完成的顺序。
+func stubRecommendUpstream(t *testing.T, c *Client) func() [][]byte {
+	t.Helper()
+	return stubUpstream(c.http, func(body []byte) string {
+		time.Sleep(time.Duration(rand.IntN(3000)) * time.Microsecond)
+		var req struct {
+			Filter struct {
+				Tag []string `json:"tag"`
+			} `json:"filter"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("bad upstream body: %v", err)
+		}
+		var items []string
+		for rank, id := range recommendPools[strings.ToLower(strings.Join(req.Filter.Tag, ","))] {
+			items = append(items, fmt.Sprintf(`{"id":%d,"name":"n%d","summary":"s","rating":{"score":8,"rank":%d}}`, id, id, rank+1))
+		}
+		return fmt.Sprintf(`{"total":%d,"data":[%s]}`, len(items), strings.Join(items, ","))
+	})
+}
+
+// recommendIDs 返回各格子推荐到的条目 ID，未找到为 0。
+func recommendIDs(resp *RecommendResponse) []int {
+	ids := make([]int, len(resp.Results))
+	for i, r := range resp.Results {
+		if r.Item != nil {
+			ids[i] = r.Item.ID
+		}
+	}
+	return ids
+}
+
+func TestRecommendDeterministic(t *testing.T) {
+	req := RecommendRequest{
+		Cells: []RecommendCellSpec{
+			{Label: "a", Tags: []string{"fate"}},
+			{Label: "b", Tags: []string{"mecha"}},
+			{Label: "c", Tags: []string{"sf"}},
+			{Label: "d", Tags: []string{"fate "}}, // 与 a 同组
+			{Label: "e", Tags: []string{"mecha"}, Offset: 1},
+			{Label: "f", Tags: []string{"sf"}},
+		},
+		ExcludeIDs: []int{3},
+	}
+	// 按格子顺序依次从池中取未用过的条目：a=1，b=2（1 已用），c=5，d=4（2、3 已用/排除），
+	// e 跳过 1 个可用条目（6 之前无可用）得不到结果，f=7
+	want := []int{1, 2, 5, 4, 0, 7}
+
+	var first *RecommendResponse
+	for run := range 20 {
+		// 每轮使用新客户端，避免缓存掩盖分组完成顺序的差异
+		c := NewClient(t.TempDir())
+		requests := stubRecommendUpstream(t, c)
+		resp, err := c.Recommend(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := recommendIDs(resp); !reflect.DeepEqual(got, want) {
+			t.Fatalf("run %d: ids = %v, want %v", run, got, want)
+		}
+		if n := len(requests()); n != 3 {
+			t.Fatalf("run %d: upstream requests = %d, want 3 (one per group)", run, n)
+		}
+		if first == nil {
+			first = resp
+		} else if !reflect.DeepEqual(resp, first) {
+			t.Fatalf("run %d: response differs from run 0", run)
+		}
+	}
+}
+
+func TestMakeRecommendKeyGroups(t *testing.T) {
+	key := func(spec RecommendCellSpec) recommendQueryKey {
+		k, _ := makeRecommendKey(spec)
+		return k
+	}
+	same := []RecommendCellSpec{
+		{Tags: []string{"Fate", "SF"}},
+		{Tags: []string{" SF", "Fate", "FATE"}},
+		{Tags: []string{"SF", "", "Fate"}, Label: "other label", Offset: 3},
+	}
+	for _, s := range same[1:] {
+		if key(s) != key(same[0]) {
+			t.Errorf("key(%+v) = %+v, want %+v", s, key(s), key(same[0]))
+		}
+	}
+	if key(RecommendCellSpec{Tags: []string{"fate"}, Sort: "score"}) == key(RecommendCellSpec{Tags: []string{"fate"}}) {
+		t.Error("different sort shares a group")
+	}
+	if key(RecommendCellSpec{}) != key(RecommendCellSpec{SubjectType: "anime"}) {
+		t.Error("empty spec does not default to anime")
+	}
+	if key(RecommendCellSpec{Tags: []string{"fate", "sf"}}) == key(same[0]) {
+		t.Error("tags differing only in case share a group")
+	}
+	if _, tags := makeRecommendKey(same[1]); !reflect.DeepEqual(tags, []string{"Fate", "SF"}) {
+		t.Errorf("tags = %q, want first spelling of each tag", tags)
+	}
+}
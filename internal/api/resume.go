@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// coverResumeAttempts 是单次下载内（含首次）最多发起的请求数，传输中断后以 Range 续传。
+const coverResumeAttempts = 3
+
+// coverPartPath 返回下载中的临时文件路径。同一 URL 固定映射到同一文件，
+// 本次重试耗尽后保留下来，下次下载同一封面时接着续传。以点开头，不会出现在封面列表中。
+func coverPartPath(dir, imgURL string) string {
+	return filepath.Join(dir, fmt.Sprintf(".%x.part", md5.Sum([]byte(imgURL))))
+}
+
+// partValidatorPath 返回 .part 对应的校验值文件，保存开始下载时响应的 ETag 或 Last-Modified，
+// 续传时作为 If-Range 发送，远端文件已变化时上游返回 200 整份内容而不是拼接到旧内容之后。
+func partValidatorPath(partPath string) string {
+	return partPath + ".validator"
+}
+
+// rangeValidator 取响应中可用于 If-Range 的校验值：强 ETag 优先，其次 Last-Modified。弱 ETag 不能用于 If-Range。
+func rangeValidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// removePart 删除 .part 及其校验值文件。
+func removePart(partPath string) {
+	_ = os.Remove(partPath)
+	_ = os.Remove(partValidatorPath(partPath))
+}
+
+// partLocks 串行化对同一 .part 文件的并发下载。
+var partLocks = &partLocker{locks: make(map[string]*partLock)}
+
+// partLocker 是按路径加锁的互斥锁表，锁在无人持有时回收。
+type partLocker struct {
+	mu    sync.Mutex
+	locks map[string]*partLock
+}
+
+type partLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock 锁定 path，返回解锁函数。
+func (l *partLocker) lock(path string) func() {
+	l.mu.Lock()
+	pl := l.locks[path]
+	if pl == nil {
+		pl = &partLock{}
+		l.locks[path] = pl
+	}
+	pl.refs++
+	l.mu.Unlock()
+
+	pl.Lock()
+	return func() {
+		pl.Unlock()
+		l.mu.Lock()
+		if pl.refs--; pl.refs == 0 {
+			delete(l.locks, path)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// errPartRetry 标记可以续传重试的下载错误（网络中断、长度不符等）。
+type errPartRetry struct{ err error }
+
+func (e errPartRetry) Error() string { return e.err.Error() }
+func (e errPartRetry) Unwrap() error { return e.err }
+
+// downloadResumable 把图片下载到 partPath，返回 Content-Type。
+// partPath 已有内容且记录了校验值时发送 Range 与 If-Range 续传；没有校验值无法确认旧内容仍然有效，从头下载。
+// 上游不支持范围请求或远端文件已变化（返回 200）时同样从头下载。
+// 完成后校验长度与可解码性；不可恢复的错误会删除 .part，重试耗尽时保留以便下次续传。
+func (o clientOptions) downloadResumable(hc *http.Client, partPath string,
+	newReq func(ctx context.Context) (*http.Request, error)) (string, error) {
+	unlock := partLocks.lock(partPath)
+	defer unlock()
+
+	var (
+		ct  string
+		err error
+	)
+	for range coverResumeAttempts {
+		ct, err = o.fetchPart(hc, partPath, newReq)
+		if _, retry := errors.AsType[errPartRetry](err); !retry || IsOverloaded(err) {
+			break // 队列已满时立即重试也会被拒绝，保留 .part 留待下次续传
+		}
+	}
+	if err != nil {
+		if _, retry := errors.AsType[errPartRetry](err); !retry {
+			removePart(partPath)
+		}
+		return "", err
+	}
+
+	if o.verifyImages {
+		if _, err := imaging.Load(partPath); err != nil {
+			removePart(partPath)
+			return "", fmt.Errorf("下载的图片无法解码: %w", err)
+		}
+	}
+	return ct, nil
+}
+
+// fetchPart 发起一次（续传）请求并把响应追加到 partPath。
+func (o clientOptions) fetchPart(hc *http.Client, partPath string,
+	newReq func(ctx context.Context) (*http.Request, error)) (string, error) {
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, CoverFileMode())
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer f.Close()
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", err
+	}
+	validatorPath := partValidatorPath(partPath)
+	var validator string
+	if offset > 0 {
+		if b, err := os.ReadFile(validatorPath); err == nil {
+			validator = strings.TrimSpace(string(b))
+		}
+		if validator == "" {
+			if offset, err = restartPart(f); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.downloadTimeout)
+	defer cancel()
+	req, err := newReq(ctx)
+	if err != nil {
+		return "", fmt.Errorf("构建请求失败: %w", err)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrBadRequest) {
+			return "", fmt.Errorf("下载失败: %w", err) // 重定向到不允许的域名，不重试
+		}
+		return "", errPartRetry{fmt.Errorf("下载失败: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	total := int64(-1)
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		start, size, ok := parseContentRange(resp.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			_ = f.Truncate(0)
+			return "", errPartRetry{fmt.Errorf("续传位置不匹配: %s", resp.Header.Get("Content-Range"))}
+		}
+		total = size
+	case http.StatusOK:
+		// 上游忽略 Range（不支持范围请求）或 If-Range 不匹配（远端已变化），丢弃已下载部分从头写入
+		if offset > 0 {
+			if offset, err = restartPart(f); err != nil {
+				return "", err
+			}
+		}
+		// 记录本次内容的校验值供之后续传；上游不提供时删除旧值，之后中断只能从头下载
+		if v := rangeValidator(resp.Header); v != "" {
+			if err := os.WriteFile(validatorPath, []byte(v), 0o600); err != nil {
+				return "", fmt.Errorf("写入临时文件失败: %w", err)
+			}
+		} else {
+			_ = os.Remove(validatorPath)
+		}
+		total = resp.ContentLength
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 本地内容与远端不一致（如远端文件已变短），从头下载
+		_ = f.Truncate(0)
+		_ = os.Remove(validatorPath)
+		return "", errPartRetry{errors.New("续传范围无效，重新下载")}
+	default:
+		return "", classifyStatus(resp, fmt.Sprintf("下载失败 HTTP %d", resp.StatusCode))
+	}
+
+	ct := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ct, "image/") {
+		return "", fmt.Errorf("非图片类型: %s", ct)
+	}
+
+	n, err := io.Copy(f, resp.Body)
+	if err != nil {
+		return "", errPartRetry{fmt.Errorf("下载中断（已完成 %d 字节）: %w", offset+n, err)}
+	}
+	if total >= 0 && offset+n != total {
+		return "", errPartRetry{fmt.Errorf("下载不完整: %d/%d 字节", offset+n, total)}
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	return ct, nil
+}
+
+// restartPart 清空 .part 并回到开头，返回新的写入位置 0。
+func restartPart(f *os.File) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	return f.Seek(0, io.SeekStart)
+}
+
+// finishCoverDownload 把校验通过的临时文件移动为正式封面并写入元数据。
+func finishCoverDownload(partPath, dir, prefix, filename, source, id, imgURL string) (*DownloadResult, error) {
+	savePath := filepath.Join(dir, filename)
+	info, err := os.Stat(partPath)
+	if err != nil {
+		return nil, fmt.Errorf("保存封面失败: %w", err)
+	}
+	if err := os.Chmod(partPath, CoverFileMode()); err != nil {
+		return nil, fmt.Errorf("保存封面失败: %w", err)
+	}
+	if err := os.Rename(partPath, savePath); err != nil {
+		return nil, fmt.Errorf("保存封面失败: %w", err)
+	}
+	_ = os.Remove(partValidatorPath(partPath))
+	writeCoverMeta(savePath, source, id, imgURL)
+
+	return &DownloadResult{
+		Filename: prefix + filename,
+		Path:     "covers/" + prefix + filename,
+		Size:     int(info.Size()),
+	}, nil
+}
+
+// parseContentRange 解析 "bytes start-end/size"，size 为 * 时返回 -1。
+func parseContentRange(v string) (start, size int64, ok bool) {
+	v, ok = strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return 0, 0, false
+	}
+	rng, sizeStr, ok := strings.Cut(v, "/")
+	if !ok {
+		return 0, 0, false
+	}
+	startStr, _, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if sizeStr == "*" {
+		return start, -1, true
+	}
+	size, err = strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, size, true
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resumeServer 以 etag 提供 content（支持 Range 与 If-Range），首个请求在写出一半后断开。
+// 返回服务器与已收到请求的 Range/If-Range 头。
+func resumeServer(t *testing.T, content []byte, etag string, cutFirst bool) (*httptest.Server, func() [][2]string) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		seen [][2]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, [2]string{r.Header.Get("Range"), r.Header.Get("If-Range")})
+		first := len(seen) == 1
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("ETag", etag)
+		if first && cutFirst {
+			w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(content[:len(content)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() [][2]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][2]string{}, seen...)
+	}
+}
+
+func downloadPart(t *testing.T, srv *httptest.Server, partPath string) error {
+	t.Helper()
+	o := buildOptions(nil)
+	o.verifyImages = false
+	_, err := o.downloadResumable(srv.Client(), partPath, func(ctx context.Context) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	})
+	return err
+}
+
+func TestDownloadResumableSendsIfRange(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 100)
+	srv, seen := resumeServer(t, content, `"v1"`, true)
+	partPath := filepath.Join(t.TempDir(), ".x.part")
+
+	if err := downloadPart(t, srv, partPath); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(partPath)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("part has %d bytes, want %d", len(got), len(content))
+	}
+	reqs := seen()
+	if len(reqs) != 2 {
+		t.Fatalf("requests = %v, want 2", reqs)
+	}
+	if reqs[1] != [2]string{"bytes=500-", `"v1"`} {
+		t.Fatalf("resume request Range/If-Range = %v", reqs[1])
+	}
+}
+
+func TestDownloadResumableRestartsWhenChanged(t *testing.T) {
+	content := bytes.Repeat([]byte("abcdefghij"), 100)
+	srv, seen := resumeServer(t, content, `"v2"`, false)
+	partPath := filepath.Join(t.TempDir(), ".x.part")
+	if err := os.WriteFile(partPath, []byte("stale bytes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(partValidatorPath(partPath), []byte(`"v1"`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := downloadPart(t, srv, partPath); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(partPath)
+	if !bytes.Equal(got, content) {
+		t.Fatalf("part = %q..., want fresh content", got[:min(len(got), 20)])
+	}
+	if reqs := seen(); len(reqs) != 1 || reqs[0][1] != `"v1"` {
+		t.Fatalf("requests = %v, want one with If-Range \"v1\"", reqs)
+	}
+	if v, _ := os.ReadFile(partValidatorPath(partPath)); string(v) != `"v2"` {
+		t.Fatalf("validator = %q, want \"v2\"", v)
+	}
+}
+
+func TestDownloadResumableWithoutValidator(t *testing.T) {
+	content := bytes.Repeat([]byte("z"), 100)
+	srv, seen := resumeServer(t, content, `"v1"`, false)
+	partPath := filepath.Join(t.TempDir(), ".x.part")
+	if err := os.WriteFile(partPath, []byte("old"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := downloadPart(t, srv, partPath); err != nil {
+		t.Fatal(err)
+	}
+	if reqs := seen(); len(reqs) != 1 || reqs[0] != [2]string{} {
+		t.Fatalf("requests = %v, want a plain GET", reqs)
+	}
+	if got, _ := os.ReadFile(partPath); !bytes.Equal(got, content) {
+		t.Fatalf("part has %d bytes, want %d", len(got), len(content))
+	}
+}
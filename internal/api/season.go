@@ -0,0 +1,78 @@
+package api
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// seasonUnknown 是放送日期缺失或无法解析的条目所在分组。
+const seasonUnknown = "未知"
+
+// seasonNames 按月份区间划分季度：1~3 月冬、4~6 月春、7~9 月夏、10~12 月秋，与新番季度的惯例一致。
+var seasonNames = [4]string{"冬", "春", "夏", "秋"}
+
+// SeasonGroup 是按放送季度分组的浏览结果。日期未知的条目归入 Season 为「未知」、Year 为 0 的分组。
+type SeasonGroup struct {
+	Season string         `json:"season"`
+	Year   int            `json:"year"`
+	Items  []BrowseResult `json:"items"`
+}
+
+// seasonOf 从 YYYY-MM-DD（或 YYYY-MM）形式的日期解析年份与季度序号（0~3）。
+func seasonOf(date string) (year, season int, ok bool) {
+	parts := strings.SplitN(date, "-", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	year, err := strconv.Atoi(parts[0])
+	if err != nil || year <= 0 {
+		return 0, 0, false
+	}
+	month, err := strconv.Atoi(parts[1])
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0, false
+	}
+	return year, (month - 1) / 3, true
+}
+
+// groupBySeason 把结果按放送季度分组，季度从新到旧排列，日期未知的分组排在最后；组内保持原有顺序。
+func groupBySeason(results []BrowseResult) []SeasonGroup {
+	type key struct{ year, season int }
+	index := make(map[key]int)
+	groups := []SeasonGroup{}
+	keys := []key{}
+	for _, r := range results {
+		k := key{year: 0, season: -1}
+		if year, season, ok := seasonOf(r.Date); ok {
+			k = key{year: year, season: season}
+		}
+		i, ok := index[k]
+		if !ok {
+			i = len(groups)
+			index[k] = i
+			name := seasonUnknown
+			if k.season >= 0 {
+				name = seasonNames[k.season]
+			}
+			groups = append(groups, SeasonGroup{Season: name, Year: k.year, Items: []BrowseResult{}})
+			keys = append(keys, k)
+		}
+		groups[i].Items = append(groups[i].Items, r)
+	}
+
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		ka, kb := keys[a], keys[b]
+		return cmp.Or(cmp.Compare(kb.year, ka.year), cmp.Compare(kb.season, ka.season))
+	})
+	sorted := make([]SeasonGroup, 0, len(groups))
+	for _, i := range order {
+		sorted = append(sorted, groups[i])
+	}
+	return sorted
+}
@@ -0,0 +1,44 @@
+package api
+
+import "sync/atomic"
+
+// DownloadStats 是本次运行中成功下载的封面数与字节数，重启后清零。
+type DownloadStats struct {
+	Count int64 `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+// downloadCounter 统计成功的封面下载；批量下载与智能推荐会并发调用，计数使用原子操作。
+type downloadCounter struct {
+	count atomic.Int64
+	bytes atomic.Int64
+}
+
+// record 记录一次成功下载，result 为 nil 时忽略。
+func (d *downloadCounter) record(result *DownloadResult) {
+	if result == nil {
+		return
+	}
+	d.count.Add(1)
+	d.bytes.Add(int64(result.Size))
+}
+
+// snapshot 返回当前计数。两个计数分别读取，并发下载时可能相差一次，仅用于展示。
+func (d *downloadCounter) snapshot() DownloadStats {
+	return DownloadStats{Count: d.count.Load(), Bytes: d.bytes.Load()}
+}
+
+// DownloadStats 返回本次运行中 Bangumi 客户端的封面下载统计。
+func (c *Client) DownloadStats() DownloadStats {
+	return c.downloads.snapshot()
+}
+
+// DownloadStats 返回本次运行中 VNDB 客户端的封面下载统计。
+func (c *VNDBClient) DownloadStats() DownloadStats {
+	return c.downloads.snapshot()
+}
+
+// DownloadStats 返回本次运行中 AniDB 客户端的封面下载统计。
+func (c *AniDBClient) DownloadStats() DownloadStats {
+	return c.downloads.snapshot()
+}
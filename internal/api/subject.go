@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 批量获取条目详情的默认并发、并发上限和单次请求 ID 数上限。
+const (
+	subjectBatchWorkers    = 6
+	subjectBatchMaxWorkers = 16
+	subjectBatchMaxIDs     = 100
+)
+
+// BatchOptions 调整批量获取的并发数与分块大小，零值表示使用默认值。
+// 分块之间串行执行，块内按 Concurrency 并发，避免大图表恢复时压垮 Bangumi。
+type BatchOptions struct {
+	Concurrency int `json:"concurrency"`
+	ChunkSize   int `json:"chunkSize"`
+}
+
+// Subject 是 Bangumi v0 条目详情的常用字段。
+type Subject struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	NameCN    string  `json:"name_cn"`
+	Cover     string  `json:"cover"`
+	Type      int     `json:"type"`
+	TypeLabel string  `json:"type_label"`
+	Score     float64 `json:"score"`
+	Rank      int     `json:"rank,omitempty"`
+	Date      string  `json:"date,omitempty"`
+	Summary   string  `json:"summary,omitempty"`
+	// Infobox 是条目信息框中常用于署名的字段（键见 infoboxKeys），值为纯文本，多个值以"、"连接。
+	Infobox map[string]string `json:"infobox,omitempty"`
+}
+
+// infoboxKeys 是从信息框中提取的字段：动画的制作公司与主创、书籍的作者与出版社、游戏的开发商。
+var infoboxKeys = []string{"原作", "导演", "动画制作", "作者", "作画", "出版社", "开发", "发行"}
+
+// infoboxItem 是 v0 信息框中的一项。value 可能是字符串，
+// 也可能是 {"k": "...", "v": "..."} 或 {"v": "..."} 组成的数组（如多个别名、多家公司）。
+type infoboxItem struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// text 把信息框的值转为纯文本；数组形式取各元素的 v 并以"、"连接，无法识别的形状返回空串。
+func (it infoboxItem) text() string {
+	var s string
+	if err := json.Unmarshal(it.Value, &s); err == nil {
+		return strings.TrimSpace(s)
+	}
+	var list []struct {
+		V string `json:"v"`
+	}
+	if err := json.Unmarshal(it.Value, &list); err != nil {
+		return ""
+	}
+	vals := make([]string, 0, len(list))
+	for _, e := range list {
+		if v := strings.TrimSpace(e.V); v != "" {
+			vals = append(vals, v)
+		}
+	}
+	return strings.Join(vals, "、")
+}
+
+// parseInfobox 提取信息框中 infoboxKeys 列出的字段，没有任何匹配时返回 nil。
+func parseInfobox(items []infoboxItem) map[string]string {
+	var out map[string]string
+	for _, it := range items {
+		key := strings.TrimSpace(it.Key)
+		if !slices.Contains(infoboxKeys, key) {
+			continue
+		}
+		if v := it.text(); v != "" {
+			if out == nil {
+				out = make(map[string]string)
+			}
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// SubjectBatchResult 是批量获取条目的结果，Errors 记录失败 ID 的错误信息。
+type SubjectBatchResult struct {
+	Subjects map[int]*Subject `json:"subjects"`
+	Errors   map[int]string   `json:"errors,omitempty"`
+}
+
+// GetSubjectRaw 原样返回 v0 API 的条目 JSON，供前端读取未建模的字段。与 GetSubject 共用缓存。
+func (c *Client) GetSubjectRaw(id int) (json.RawMessage, error) {
+	if id <= 0 {
+		return nil, badRequestError("无效的条目 ID")
+	}
+
+	data, err := c.cachedGet(fmt.Sprintf("%s%d", bgmV0SubjectURL, id))
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("条目 %d 的响应不是合法 JSON", id)
+	}
+	return data, nil
+}
+
+// GetSubject 通过 v0 API 获取单个条目详情（带缓存）。
+func (c *Client) GetSubject(id int) (*Subject, error) {
+	if id <= 0 {
+		return nil, badRequestError("无效的条目 ID")
+	}
+
+	data, err := c.cachedGet(fmt.Sprintf("%s%d", bgmV0SubjectURL, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		ID       int           `json:"id"`
+		Name     string        `json:"name"`
+		NameCN   string        `json:"name_cn"`
+		Type     int           `json:"type"`
+		Images   bgmImages     `json:"images"`
+		Platform string        `json:"platform"`
+		Date     string        `json:"date"`
+		Summary  string        `json:"summary"`
+		Infobox  []infoboxItem `json:"infobox"`
+		Rating   struct {
+			Score float64 `json:"score"`
+			Rank  int     `json:"rank"`
+		} `json:"rating"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析条目详情失败: %w", err)
+	}
+
+	label := TypeLabels[raw.Type]
+	if raw.Type == 1 {
+		label = bookLabelFromPlatform(raw.Platform)
+	}
+	return &Subject{
+		ID:        raw.ID,
+		Name:      raw.Name,
+		NameCN:    raw.NameCN,
+		Cover:     raw.Images.bestURL(),
+		Type:      raw.Type,
+		TypeLabel: label,
+		Score:     raw.Rating.Score,
+		Rank:      raw.Rating.Rank,
+		Date:      raw.Date,
+		Summary:   truncateRunes(raw.Summary, 300),
+		Infobox:   parseInfobox(raw.Infobox),
+	}, nil
+}
+
+// GetSubjectsBatch 分块并发获取多个条目详情，重复 ID 只请求一次。
+// 单个 ID 失败（如 404）不影响其他条目，错误记录在 Errors 中。
+// GetSubject 走 cachedGet，并发批次中重叠的 ID 会经 singleflight 合并为一次网络请求。
+func (c *Client) GetSubjectsBatch(ids []int, opts BatchOptions) (*SubjectBatchResult, error) {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) > subjectBatchMaxIDs {
+		return nil, badRequestError(fmt.Sprintf("单次最多获取 %d 个条目，当前 %d 个", subjectBatchMaxIDs, len(unique)))
+	}
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = subjectBatchWorkers
+	}
+	workers = min(workers, subjectBatchMaxWorkers)
+	chunk := opts.ChunkSize
+	if chunk <= 0 || chunk > len(unique) {
+		chunk = len(unique)
+	}
+
+	result := &SubjectBatchResult{
+		Subjects: make(map[int]*Subject),
+		Errors:   make(map[int]string),
+	}
+	var mu sync.Mutex
+	sem := make(chan struct{}, workers)
+	for start := 0; start < len(unique); start += chunk {
+		var wg sync.WaitGroup
+		for _, id := range unique[start:min(start+chunk, len(unique))] {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(id int) {
+				defer func() { <-sem; wg.Done() }()
+				subj, err := c.GetSubject(id)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					result.Errors[id] = err.Error()
+					return
+				}
+				result.Subjects[id] = subj
+			}(id)
+		}
+		wg.Wait()
+	}
+	return result, nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import "net/http"
+
+// 默认连接池参数。批量下载与智能推荐会在短时间内向同一主机发出大量请求，
+// 标准库默认每主机只保留 2 个空闲连接，多余的连接用完即关，下一批请求又要重新握手
+// （BenchmarkConnPool 比较了两者的握手次数）。
+// 两项都取 -upstream-queue 的默认值 32：进程发往上游的并发请求已被该队列限制在 32 以内，
+// 因此每主机 32 个连接在启用队列时不会成为新的瓶颈，一批并发请求结束后的连接也都能留到下一批复用；
+// 队列关闭（-upstream-queue 0）时，它仍限制单个主机同时承受的连接数。
+const (
+	DefaultMaxIdleConnsPerHost = 32
+	DefaultMaxConnsPerHost     = 32
+)
+
+// WithConnPool 设置每个上游主机保留的空闲连接数与最大连接数（0 表示不限制）；
+// idle 非正或 max 为负时对应项保持默认。
+func WithConnPool(idle, max int) Option {
+	return func(o *clientOptions) {
+		if idle > 0 {
+			o.maxIdleConnsPerHost = idle
+		}
+		if max >= 0 {
+			o.maxConnsPerHost = max
+		}
+	}
+}
+
+// WithTransport 让客户端通过 rt 发出全部请求，此时 WithConnPool 不再生效。用于测试或自定义代理链。
+func WithTransport(rt http.RoundTripper) Option {
+	return func(o *clientOptions) {
+		o.transport = rt
+	}
+}
+
+// newTransport 基于 http.DefaultTransport 的配置（代理、拨号与 TLS 超时等）创建客户端独占的 Transport，
+// 并按选项调整连接池大小。
+func (o clientOptions) newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.MaxIdleConnsPerHost = o.maxIdleConnsPerHost
+	t.MaxConnsPerHost = o.maxConnsPerHost
+	t.MaxIdleConns = max(t.MaxIdleConns, o.maxIdleConnsPerHost)
+	return t
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// BenchmarkConnPool 以 24 个并发请求为一批向本地 TLS 服务器发请求，比较标准库默认连接池与默认参数下的握手次数。
+// handshakes/op 是每批新建的连接数，批次之间连接能复用时趋近于 0。
+func BenchmarkConnPool(b *testing.B) {
+	const batch = 24
+	for _, bc := range []struct {
+		name      string
+		transport func() *http.Transport
+	}{
+		// http.DefaultTransport：每主机 2 个空闲连接
+		{"stdlib", func() *http.Transport { return http.DefaultTransport.(*http.Transport).Clone() }},
+		{"default", buildOptions(nil).newTransport},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var conns atomic.Int64
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+				if s == http.StateNew {
+					conns.Add(1)
+				}
+			}
+			srv.StartTLS()
+			defer srv.Close()
+
+			t := bc.transport()
+			t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+			defer t.CloseIdleConnections()
+			client := &http.Client{Transport: t}
+
+			b.ReportAllocs()
+			for b.Loop() {
+				var wg sync.WaitGroup
+				for range batch {
+					wg.Go(func() {
+						resp, err := client.Get(srv.URL)
+						if err != nil {
+							b.Error(err)
+							return
+						}
+						resp.Body.Close()
+					})
+				}
+				wg.Wait()
+			}
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "handshakes/op")
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// 热门条目参数。
+const (
+	trendingCacheTTL = time.Hour
+	trendingMonths   = 6  // 只看最近几个月内放送/发售的条目
+	trendingLimit    = 30 // 返回的条目数
+)
+
+// GetTrending 返回近期热门条目：按热度（heat）排序，限定最近 trendingMonths 个月内放送或发售。
+// subjectType 为 Bangumi 类型 ID（1=书籍 2=动画 4=游戏）。结果缓存一小时。
+func (c *Client) GetTrending(subjectType int) ([]Card, error) {
+	if _, ok := TypeLabels[subjectType]; !ok {
+		return nil, badRequestError(fmt.Sprintf("不支持的条目类型: %d", subjectType))
+	}
+
+	// 起始日期按天取整，同一天内的请求共用缓存键
+	since := time.Now().AddDate(0, -trendingMonths, 0).Format("2006-01-02")
+	body := map[string]any{
+		"sort": "heat",
+		"filter": map[string]any{
+			"type":     []int{subjectType},
+			"air_date": []string{">=" + since},
+		},
+	}
+	apiURL := fmt.Sprintf("%s?limit=%d&offset=0", bgmV0SearchURL, min(trendingLimit, c.opts.browseLimit))
+	data, err := c.cachedPostTTL(apiURL, body, trendingCacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Data []struct {
+			ID     int       `json:"id"`
+			Name   string    `json:"name"`
+			NameCN string    `json:"name_cn"`
+			Images bgmImages `json:"images"`
+			Type   int       `json:"type"`
+			Rating struct {
+				Score float64 `json:"score"`
+			} `json:"rating"`
+			Platform string `json:"platform"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析热门条目失败: %w", err)
+	}
+
+	cards := make([]Card, 0, len(raw.Data))
+	for _, it := range raw.Data {
+		label := TypeLabels[it.Type]
+		if it.Type == 1 {
+			label = bookLabelFromPlatform(it.Platform)
+		}
+		cards = append(cards, Card{
+			ID:        strconv.Itoa(it.ID),
+			Name:      it.Name,
+			NameCN:    it.NameCN,
+			Cover:     it.Images.bestURL(),
+			Score:     it.Rating.Score,
+			TypeLabel: label,
+			Source:    SourceBangumi,
+		})
+	}
+	return cards, nil
+}
@@ -2,14 +2,15 @@ package api
 
 import (
 	"bytes"
-	"crypto/md5"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,33 +18,47 @@ import (
 
 // VNDB Kana v2 相关配置常量。
 const (
-	vndbBaseURL         = "https://api.vndb.org/kana"
-	vndbVNURL           = vndbBaseURL + "/vn"
-	vndbStatsURL        = vndbBaseURL + "/stats"
-	vndbAuthInfoURL     = vndbBaseURL + "/authinfo"
-	vndbSchemaURL       = vndbBaseURL + "/schema"
-	vndbUserAgent       = "OtakuChartMaker/1.0 (https://github.com/Aytrw/otaku-chart-maker)"
-	vndbCacheTTL        = 5 * time.Minute
-	vndbCacheCleanTick  = 1 * time.Minute
-	vndbCacheMaxEntries = 800
-	vndbDefaultResults  = 20
-	vndbMaxResults      = 100
+	vndbBaseURL        = "https://api.vndb.org/kana"
+	vndbVNURL          = vndbBaseURL + "/vn"
+	vndbStatsURL       = vndbBaseURL + "/stats"
+	vndbAuthInfoURL    = vndbBaseURL + "/authinfo"
+	vndbSchemaURL      = vndbBaseURL + "/schema"
+	vndbCacheTTL       = 5 * time.Minute
+	vndbDefaultResults = 20
+	vndbDefaultFields  = "id,title,alttitle,image.url,image.thumbnail,rating,released"
+	vndbTagFields      = "tags.id,tags.name,tags.rating,tags.spoiler"
+	vndbSimilarTags    = 5 // 相似推荐使用的源 VN 标签数量
 )
 
+// VNDBMaxResults 是 Kana API 允许的单次结果上限，也是 -vndb-max-results 的默认值与最大值。
+const VNDBMaxResults = 100
+
+// vndbIDPattern 校验 VNDB 视觉小说 ID（如 v17）。
+var vndbIDPattern = regexp.MustCompile(`^v[0-9]+$`)
+
+// vndbExtraFields 是允许客户端额外请求的 VNDB 字段白名单，排除开销较大的关联字段。
+var vndbExtraFields = map[string]bool{
+	"aliases": true, "olang": true, "devstatus": true, "languages": true,
+	"platforms": true, "length": true, "length_minutes": true, "length_votes": true,
+	"votecount": true, "description": true,
+	"developers.id": true, "developers.name": true, "developers.original": true,
+}
+
+// vndbBaseKeys 是 VNDBVN 已建模的响应字段，其余字段进入 Extra 透传。
+var vndbBaseKeys = map[string]bool{
+	"id": true, "title": true, "alttitle": true, "image": true, "rating": true, "released": true,
+	"tags": true,
+}
+
 // VNDBClient 是 VNDB Kana v2 API 客户端。
 type VNDBClient struct {
 	http      *http.Client
+	opts      clientOptions
 	token     string
 	coversDir string
-	mu        sync.Mutex
-	cache     map[string]vndbCacheEntry
-}
-
-// vndbCacheEntry 是 VNDB 客户端缓存条目。
-type vndbCacheEntry struct {
-	data   []byte
-	expire time.Time
-	added  time.Time
+	mu        sync.Mutex // 保护 token
+	cache     Cache
+	downloads downloadCounter // 本次运行的封面下载统计
 }
 
 // VNDBQueryRequest 定义 Kana v2 查询请求体。
@@ -57,6 +72,9 @@ type VNDBQueryRequest struct {
 	Count             bool   `json:"count,omitempty"`
 	CompactFilters    bool   `json:"compact_filters,omitempty"`
 	NormalizedFilters bool   `json:"normalized_filters,omitempty"`
+
+	// ExtraFields 追加到默认字段集的额外字段，需在白名单内。
+	ExtraFields []string `json:"-"`
 }
 
 // VNDBVN 是视觉小说基础数据结构。
@@ -67,6 +85,41 @@ type VNDBVN struct {
 	Image    VNDBImage `json:"image"`
 	Rating   float64   `json:"rating"`
 	Released string    `json:"released"`
+	Tags     []VNDBTag `json:"tags,omitempty"`
+
+	// Extra 保存通过 ExtraFields 请求的额外字段原始 JSON。
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON 解析已建模字段，并把其余字段收集到 Extra。
+func (vn *VNDBVN) UnmarshalJSON(data []byte) error {
+	type plain VNDBVN
+	if err := json.Unmarshal(data, (*plain)(vn)); err != nil {
+		return err
+	}
+
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return err
+	}
+	for key, raw := range all {
+		if vndbBaseKeys[key] {
+			continue
+		}
+		if vn.Extra == nil {
+			vn.Extra = make(map[string]json.RawMessage)
+		}
+		vn.Extra[key] = raw
+	}
+	return nil
+}
+
+// VNDBTag 是视觉小说关联的标签及其评分。
+type VNDBTag struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Rating  float64 `json:"rating"`
+	Spoiler int     `json:"spoiler"`
 }
 
 // VNDBImage 是 VNDB 图片字段。
@@ -111,14 +164,14 @@ type VNDBAuthInfo struct {
 }
 
 // NewVNDBClient 创建 VNDB API 客户端。
-func NewVNDBClient(coversDir, token string) *VNDBClient {
+func NewVNDBClient(coversDir, token string, opts ...Option) *VNDBClient {
 	c := &VNDBClient{
-		http:      &http.Client{Timeout: 15 * time.Second},
+		opts:      buildOptions(opts),
 		token:     strings.TrimSpace(token),
 		coversDir: coversDir,
-		cache:     make(map[string]vndbCacheEntry),
 	}
-	go c.startCacheCleaner()
+	c.cache = c.opts.newCache()
+	c.http = c.opts.newHTTPClient()
 	return c
 }
 
@@ -131,15 +184,20 @@ func (c *VNDBClient) SetToken(token string) {
 
 // QueryVN 按 Kana v2 格式查询视觉小说。
 func (c *VNDBClient) QueryVN(req VNDBQueryRequest) (*VNDBQueryResponse, error) {
-	if req.Results <= 0 || req.Results > vndbMaxResults {
-		req.Results = vndbDefaultResults
+	if req.Results <= 0 || req.Results > c.opts.vndbMaxResults {
+		req.Results = min(vndbDefaultResults, c.opts.vndbMaxResults)
 	}
 	if req.Page <= 0 {
 		req.Page = 1
 	}
 	if strings.TrimSpace(req.Fields) == "" {
-		req.Fields = "id,title,alttitle,image.url,image.thumbnail,rating,released"
+		req.Fields = vndbDefaultFields
+	}
+	fields, err := mergeVNDBFields(req.Fields, req.ExtraFields)
+	if err != nil {
+		return nil, err
 	}
+	req.Fields = fields
 	if strings.TrimSpace(req.Sort) == "" {
 		req.Sort = "id"
 	}
@@ -156,24 +214,127 @@ func (c *VNDBClient) QueryVN(req VNDBQueryRequest) (*VNDBQueryResponse, error) {
 	return &resp, nil
 }
 
-// SearchVN 使用关键词进行视觉小说搜索。
-func (c *VNDBClient) SearchVN(keyword string, page, results int) (*VNDBQueryResponse, error) {
+// SearchVN 使用关键词进行视觉小说搜索。extraFields 为可选的额外字段。
+func (c *VNDBClient) SearchVN(keyword string, page, results int, extraFields ...string) (*VNDBQueryResponse, error) {
 	keyword = strings.TrimSpace(keyword)
 	if keyword == "" {
 		return nil, badRequestError("关键词不能为空")
 	}
 
 	req := VNDBQueryRequest{
-		Filters: []any{"search", "=", keyword},
-		Fields:  "id,title,alttitle,image.url,image.thumbnail,rating,released",
-		Sort:    "searchrank",
-		Results: results,
-		Page:    page,
-		Count:   true,
+		Filters:     []any{"search", "=", keyword},
+		Fields:      vndbDefaultFields,
+		Sort:        "searchrank",
+		Results:     results,
+		Page:        page,
+		Count:       true,
+		ExtraFields: extraFields,
 	}
 	return c.QueryVN(req)
 }
 
+// GetVN 按 ID 获取单个视觉小说，附带标签信息。ID 格式不对时返回参数错误，条目不存在时返回 ErrNotFound 类错误。
+func (c *VNDBClient) GetVN(id string) (*VNDBVN, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if !vndbIDPattern.MatchString(id) {
+		return nil, badRequestError("无效的 VNDB ID: " + id)
+	}
+
+	resp, err := c.QueryVN(VNDBQueryRequest{
+		Filters: []any{"id", "=", id},
+		Fields:  vndbDefaultFields + "," + vndbTagFields,
+		Results: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, notFoundError{msg: "未找到视觉小说: " + id}
+	}
+	return &resp.Results[0], nil
+}
+
+// SimilarVN 取源 VN 评分最高的若干非剧透标签，查询共享这些标签的其他 VN，按评分降序返回。
+// limit 非正时取默认数量，超过 -vndb-max-results 时取该上限。结果按源 ID 与数量缓存。
+func (c *VNDBClient) SimilarVN(id string, limit int) (*VNDBQueryResponse, error) {
+	if limit <= 0 {
+		limit = vndbDefaultResults
+	}
+	limit = min(limit, c.opts.vndbMaxResults)
+
+	src, err := c.GetVN(id)
+	if err != nil {
+		return nil, err
+	}
+
+	key := c.opts.cacheKey(vndbVNURL+"#similar/"+src.ID, []byte(strconv.Itoa(limit)))
+	if data, ok := c.cache.Get(key); ok {
+		var cached VNDBQueryResponse
+		if json.Unmarshal(data, &cached) == nil {
+			return &cached, nil
+		}
+	}
+
+	tags := make([]VNDBTag, 0, len(src.Tags))
+	for _, t := range src.Tags {
+		if t.Spoiler == 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].Rating > tags[j].Rating })
+	if len(tags) > vndbSimilarTags {
+		tags = tags[:vndbSimilarTags]
+	}
+	if len(tags) == 0 {
+		return &VNDBQueryResponse{Results: []VNDBVN{}}, nil
+	}
+
+	tagFilter := []any{"or"}
+	for _, t := range tags {
+		tagFilter = append(tagFilter, []any{"tag", "=", t.ID})
+	}
+	resp, err := c.QueryVN(VNDBQueryRequest{
+		Filters: []any{"and", tagFilter, []any{"id", "!=", src.ID}},
+		Fields:  vndbDefaultFields,
+		Sort:    "rating",
+		Reverse: true,
+		Results: limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(resp); err == nil {
+		c.cache.Set(key, data, vndbCacheTTL)
+	}
+	return resp, nil
+}
+
+// mergeVNDBFields 将白名单内的额外字段合并进基础字段集并去重。
+func mergeVNDBFields(base string, extra []string) (string, error) {
+	seen := map[string]bool{}
+	fields := make([]string, 0, len(extra)+8)
+	for _, f := range strings.Split(base, ",") {
+		f = strings.TrimSpace(f)
+		if f != "" && !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+	for _, f := range extra {
+		f = strings.TrimSpace(f)
+		if f == "" || seen[f] {
+			continue
+		}
+		if !vndbExtraFields[f] {
+			return "", badRequestError("不支持的 VNDB 字段: " + f)
+		}
+		seen[f] = true
+		fields = append(fields, f)
+	}
+	return strings.Join(fields, ","), nil
+}
+
 // GetStats 获取 VNDB 数据库统计信息。
 func (c *VNDBClient) GetStats() (*VNDBStats, error) {
 	body, err := c.get(vndbStatsURL, false)
@@ -220,63 +381,102 @@ func (c *VNDBClient) GetAuthInfo() (*VNDBAuthInfo, error) {
 	return &info, nil
 }
 
-// DownloadCover 下载 VNDB 封面到本地 covers 目录。
-func (c *VNDBClient) DownloadCover(imgURL, filename string) (*DownloadResult, error) {
+// DownloadCover 下载 VNDB 封面到本地 covers 目录。info 供文件名模板使用，可为空。
+func (c *VNDBClient) DownloadCover(imgURL, filename string, info CoverInfo) (*DownloadResult, error) {
 	imgURL = strings.TrimSpace(imgURL)
 	if imgURL == "" {
 		return nil, badRequestError("缺少图片 URL")
 	}
 
-	filename = sanitizeFilename(imgURL, filename)
+	filename = c.opts.coverFilename(imgURL, filename, SourceVNDB, info)
+	dir, prefix := c.opts.coverDir(c.coversDir, SourceVNDB)
 
-	// 同名封面已存在则直接复用，跳过重复下载
-	if existing := findExistingCover(c.coversDir, filename); existing != nil {
-		return existing, nil
+	// 同名封面已存在时发条件请求，未更新则直接复用
+	if existing := findExistingCover(dir, prefix, filename); existing != nil {
+		result := refreshExistingCover(c.coversDir, SourceVNDB, imgURL, existing, c.opts.checkImageData, c.fetchCover)
+		if result.Refreshed {
+			c.downloads.record(result)
+		}
+		return result, nil
 	}
-	req, err := http.NewRequest(http.MethodGet, imgURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("构建请求失败: %w", err)
+	if err := c.opts.checkCoverURL(imgURL); err != nil {
+		return nil, err
 	}
-	req.Header.Set("User-Agent", vndbUserAgent)
 
-	resp, err := c.http.Do(req)
+	if err := EnsureCoversDir(c.coversDir); err != nil {
+		return nil, err
+	}
+	if err := MkdirCovers(dir); err != nil {
+		return nil, fmt.Errorf("创建封面目录失败: %w", err)
+	}
+	partPath := coverPartPath(dir, imgURL)
+	ct, err := c.opts.downloadResumable(c.opts.coverClient(c.http), partPath, func(ctx context.Context) (*http.Request, error) {
+		return c.coverRequest(ctx, imgURL)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("下载失败: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("下载失败 HTTP %d", resp.StatusCode)
+	filename = fixExtByContentType(filename, ct)
+	filename = UniqueFilename(dir, filename)
+	result, err := finishCoverDownload(partPath, dir, prefix, filename, SourceVNDB, strings.TrimSpace(info.ID), imgURL)
+	if err != nil {
+		return nil, err
 	}
+	c.downloads.record(result)
+	return result, nil
+}
 
-	ct := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(ct, "image/") {
-		return nil, fmt.Errorf("非图片类型: %s", ct)
+// RestoreCover 重新下载封面并按原相对路径保存，用于恢复被误删的文件。
+func (c *VNDBClient) RestoreCover(rel, imgURL string) (*DownloadResult, error) {
+	data, _, err := c.fetchCover(imgURL, time.Time{})
+	if err != nil {
+		return nil, err
 	}
-
-	data, err := io.ReadAll(resp.Body)
+	if err := c.opts.checkImageData(data); err != nil {
+		return nil, err
+	}
+	result, err := restoreCoverFile(c.coversDir, rel, SourceVNDB, imgURL, data)
 	if err != nil {
-		return nil, fmt.Errorf("读取图片失败: %w", err)
+		return nil, err
 	}
+	c.downloads.record(result)
+	return result, nil
+}
 
-	filename = fixExtByContentType(filename, ct)
-	filename = UniqueFilename(c.coversDir, filename)
-	_ = os.MkdirAll(c.coversDir, 0o755)
-	savePath := filepath.Join(c.coversDir, filename)
-	if err := os.WriteFile(savePath, data, 0o644); err != nil {
-		return nil, fmt.Errorf("保存封面失败: %w", err)
+// fetchCover 下载封面图片，返回内容与 Content-Type。
+// since 非零时发送 If-Modified-Since，未更新时返回 errCoverFresh。
+func (c *VNDBClient) fetchCover(imgURL string, since time.Time) ([]byte, string, error) {
+	if err := c.opts.checkCoverURL(imgURL); err != nil {
+		return nil, "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.downloadTimeout)
+	defer cancel()
+	req, err := c.coverRequest(ctx, imgURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("构建请求失败: %w", err)
 	}
+	if !since.IsZero() {
+		req.Header.Set("If-Modified-Since", since.UTC().Format(http.TimeFormat))
+	}
+	return readCoverResponse(c.opts.coverClient(c.http).Do(req))
+}
 
-	return &DownloadResult{
-		Filename: filename,
-		Path:     "covers/" + filename,
-		Size:     len(data),
-	}, nil
+// coverRequest 构建封面图片请求。
+func (c *VNDBClient) coverRequest(ctx context.Context, imgURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imgURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.userAgent)
+	return req, nil
 }
 
 // get 发送 GET 请求并返回响应字节。
 func (c *VNDBClient) get(apiURL string, needAuth bool) ([]byte, error) {
-	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.apiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -293,7 +493,9 @@ func (c *VNDBClient) get(apiURL string, needAuth bool) ([]byte, error) {
 
 // post 发送 POST 请求并返回响应字节。
 func (c *VNDBClient) post(apiURL string, bodyJSON []byte, needAuth bool) ([]byte, error) {
-	req, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(bodyJSON))
+	ctx, cancel := context.WithTimeout(context.Background(), c.opts.apiTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(bodyJSON))
 	if err != nil {
 		return nil, err
 	}
@@ -316,37 +518,23 @@ func (c *VNDBClient) cachedPost(apiURL string, body any) ([]byte, error) {
 		return nil, err
 	}
 
-	key := c.makeCacheKey(apiURL, bodyJSON)
-	now := time.Now()
-
-	c.mu.Lock()
-	if entry, ok := c.cache[key]; ok {
-		if now.Before(entry.expire) {
-			c.mu.Unlock()
-			return entry.data, nil
-		}
-		delete(c.cache, key)
+	key := c.opts.cacheKey(apiURL, bodyJSON)
+	if data, ok := c.cache.Get(key); ok {
+		return data, nil
 	}
-	c.mu.Unlock()
 
 	result, err := c.post(apiURL, bodyJSON, false)
 	if err != nil {
 		return nil, err
 	}
 
-	cachedAt := time.Now()
-	c.mu.Lock()
-	c.cache[key] = vndbCacheEntry{data: result, expire: cachedAt.Add(vndbCacheTTL), added: cachedAt}
-	c.pruneExpiredLocked(cachedAt)
-	c.evictOverflowLocked()
-	c.mu.Unlock()
-
+	c.cache.Set(key, result, vndbCacheTTL)
 	return result, nil
 }
 
 // applyHeaders 设置 VNDB 请求所需公共请求头。
 func (c *VNDBClient) applyHeaders(req *http.Request, needAuth bool) {
-	req.Header.Set("User-Agent", vndbUserAgent)
+	req.Header.Set("User-Agent", c.opts.userAgent)
 	req.Header.Set("Accept", "application/json")
 	if !needAuth {
 		return
@@ -382,73 +570,9 @@ func (c *VNDBClient) readAPIResponse(resp *http.Response) ([]byte, error) {
 	case http.StatusUnauthorized:
 		return nil, fmt.Errorf("VNDB 认证失败: %s", msg)
 	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("VNDB 请求过于频繁: %s", msg)
+		return nil, classifyStatus(resp, "VNDB 请求过于频繁: "+msg)
 	default:
-		return nil, fmt.Errorf("VNDB API 错误 %d: %s", resp.StatusCode, msg)
-	}
-}
-
-// makeCacheKey 使用 URL 与请求体生成缓存键。
-func (c *VNDBClient) makeCacheKey(apiURL string, bodyJSON []byte) string {
-	h := md5.New()
-	h.Write([]byte(apiURL))
-	h.Write(bodyJSON)
-	return fmt.Sprintf("%x", h.Sum(nil))
-}
-
-// startCacheCleaner 周期清理过期缓存。
-func (c *VNDBClient) startCacheCleaner() {
-	ticker := time.NewTicker(vndbCacheCleanTick)
-	for now := range ticker.C {
-		c.mu.Lock()
-		c.pruneExpiredLocked(now)
-		c.evictOverflowLocked()
-		c.mu.Unlock()
-	}
-}
-
-// pruneExpiredLocked 清理过期缓存条目。
-func (c *VNDBClient) pruneExpiredLocked(now time.Time) {
-	for key, entry := range c.cache {
-		if !now.Before(entry.expire) {
-			delete(c.cache, key)
-		}
-	}
-}
-
-// evictOverflowLocked 当缓存超过上限时批量淘汰最早加入的条目（调用方需持锁）。
-func (c *VNDBClient) evictOverflowLocked() {
-	excess := len(c.cache) - vndbCacheMaxEntries
-	if excess <= 0 {
-		return
-	}
-	victims := make([]struct {
-		key   string
-		added time.Time
-	}, 0, excess)
-	for key, entry := range c.cache {
-		if len(victims) < excess {
-			victims = append(victims, struct {
-				key   string
-				added time.Time
-			}{key, entry.added})
-		} else {
-			newest := 0
-			for vi := 1; vi < len(victims); vi++ {
-				if victims[vi].added.After(victims[newest].added) {
-					newest = vi
-				}
-			}
-			if entry.added.Before(victims[newest].added) {
-				victims[newest] = struct {
-					key   string
-					added time.Time
-				}{key, entry.added}
-			}
-		}
-	}
-	for _, v := range victims {
-		delete(c.cache, v.key)
+		return nil, classifyStatus(resp, fmt.Sprintf("VNDB API 错误 %d: %s", resp.StatusCode, msg))
 	}
 }
 
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGetVNNotFound(t *testing.T) {
+	c := NewVNDBClient(t.TempDir(), "", WithCache(noopCache{}))
+	stubUpstream(c.http, func([]byte) string { return `{"results":[],"more":false}` })
+
+	_, err := c.GetVN("v99999999")
+	if !IsNotFound(err) || IsBadRequest(err) {
+		t.Fatalf("GetVN(missing) error = %v, want not found", err)
+	}
+	if _, err := c.GetVN("17"); !IsBadRequest(err) {
+		t.Fatalf("GetVN(17) error = %v, want bad request", err)
+	}
+}
+
+func TestSimilarVNClampsLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		limit int
+		want  int
+	}{
+		{"default", nil, 0, vndbDefaultResults},
+		{"within cap", nil, 30, 30},
+		{"above cap", nil, 150, VNDBMaxResults},
+		{"above configured cap", []Option{WithVNDBMaxResults(50)}, 150, 50},
+		{"default above configured cap", []Option{WithVNDBMaxResults(10)}, 0, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewVNDBClient(t.TempDir(), "", append(tt.opts, WithCache(noopCache{}))...)
+			requests := stubUpstream(c.http, func(body []byte) string {
+				if strings.Contains(string(body), `"tag"`) {
+					return `{"results":[],"more":false}`
+				}
+				return `{"results":[{"id":"v17","title":"t","tags":[{"id":"g1","name":"a","rating":2.5,"spoiler":0}]}],"more":false}`
+			})
+
+			if _, err := c.SimilarVN("v17", tt.limit); err != nil {
+				t.Fatal(err)
+			}
+			bodies := requests()
+			if len(bodies) != 2 {
+				t.Fatalf("upstream requests = %d, want 2", len(bodies))
+			}
+			var sent struct {
+				Results int `json:"results"`
+			}
+			if err := json.Unmarshal(bodies[1], &sent); err != nil {
+				t.Fatal(err)
+			}
+			if sent.Results != tt.want {
+				t.Errorf("results sent = %d, want %d", sent.Results, tt.want)
+			}
+		})
+	}
+}
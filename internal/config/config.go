@@ -1,3 +1,326 @@
 package config
 
 // config 包负责配置加载与校验。
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// 日志输出格式。
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// 状态文件的 JSON 缩进方式。
+const (
+	StateIndentTwo  = "2"    // 两个空格（默认）
+	StateIndentTab  = "tab"  // 制表符
+	StateIndentNone = "none" // 紧凑单行
+)
+
+// Config 是命令行参数解析后的运行配置。
+type Config struct {
+	LogFormat string // 日志格式：text（默认，人类可读）或 json（结构化）
+	FontPath  string // 服务端渲染标签所用的 TTF/OTF 字体，为空时自动探测系统 CJK 字体
+	Prefetch  bool   // 启动后在后台预热常用浏览查询缓存
+	UserAgent string // 上游请求的 User-Agent，为空使用默认值
+
+	PregenThumbs bool // 启动后及新增封面时在后台预生成默认宽度的缩略图
+
+	APITimeout      time.Duration // 搜索/浏览等元数据请求的单次超时
+	DownloadTimeout time.Duration // 封面下载的单次超时
+
+	CoverSubdirs bool // 下载的封面按数据源存入 covers/<source>/ 子目录
+	VerifyImages bool // 保存前完整解码下载的封面，拒绝截断或损坏的图片
+
+	CoversFileMode os.FileMode // 下载、上传写入 covers/ 的文件权限
+	CoversDirMode  os.FileMode // 在 covers/ 下新建目录的权限
+
+	LibraryDirs []string // 额外的只读封面图库目录，与 covers/ 合并列出，同名时靠后的目录优先、covers/ 最优先
+
+	CoverHosts   []string // 允许下载封面的域名白名单，为空使用内置默认列表
+	AllowAnyHost bool     // 关闭域名白名单，允许从任意 http(s) 地址下载封面
+
+	FilenameTemplate  string // 下载封面的文件名模板（text/template），为空沿用前端给出的文件名
+	FilenameMaxLength int    // 封面文件名（含扩展名）的最大字节数（32~255），超出时截短并追加短哈希
+
+	StateIndent string // 保存图表状态时的 JSON 缩进：2、tab 或 none
+
+	AutosaveInterval time.Duration // 排队中的图表状态兜底落盘周期，0 表示关闭
+
+	ArchiveInterval time.Duration // 把 covers/ 与全部图表打包为 zip 归档的周期，0 表示关闭
+	ArchiveKeep     int           // 保留的最近归档数
+
+	CacheIgnoreFields []string // 生成上游响应缓存键时忽略的请求体字段
+	CacheBackend      string   // 上游响应缓存后端：memory（默认）、disk（数据目录下的 cache/）或 none
+
+	UpstreamQueue int // 发往上游的并发请求上限（两个数据源共享），已满时新请求返回 503；0 表示不限制
+
+	MaxIdleConnsPerHost int // 每个上游主机保留的空闲连接数，批量下载时复用连接以免反复 TLS 握手
+	MaxConnsPerHost     int // 每个上游主机的最大连接数，0 表示不限制
+
+	MaxBrowseLimit int // Bangumi 浏览单页上限（1~100）
+	VNDBMaxResults int // VNDB 单次查询结果上限（1~100）
+	VNDBPageSize   int // VNDB 搜索未指定 limit 时的每页结果数（不超过 VNDBMaxResults）
+
+	DisabledSources []string // 关闭的数据源（bgm/vndb/anidb），视同未配置：聚合接口跳过，显式访问返回 400
+
+	AniDBClient    string // 在 AniDB 注册的 HTTP API 客户端名，与 AniDBClientVer 同时设置时启用 AniDB
+	AniDBClientVer int    // 在 AniDB 注册的客户端版本
+
+	AuthUser string // HTTP Basic Auth 用户名，与 AuthPass 同时设置时启用认证
+	AuthPass string // HTTP Basic Auth 密码
+
+	TrashDays int // 删除的封面在回收站中保留的天数，0 表示不自动清除
+
+	ReadOnly bool // 只读模式：拒绝保存状态、下载/上传/删除封面等写操作
+
+	PrettyJSON bool // 所有 API 的 JSON 响应缩进输出，便于调试；单个请求也可用 ?pretty=1
+
+	FrontendDir string // 从指定目录读取前端文件，为空时自动检测
+	Embedded    bool   // 强制使用嵌入的前端文件，忽略磁盘上的 frontend/
+
+	Phash          bool // 启用封面感知哈希索引，检测视觉近似的重复封面
+	PhashThreshold int  // 判定近似重复的最大汉明距离（0~64）
+}
+
+// Default 返回全部字段取默认值的配置。
+func Default() Config {
+	return Config{
+		LogFormat:           LogFormatText,
+		StateIndent:         StateIndentTwo,
+		MaxBrowseLimit:      api.MaxBrowseLimit,
+		VNDBMaxResults:      api.VNDBMaxResults,
+		VNDBPageSize:        20,
+		VerifyImages:        true,
+		CoversFileMode:      0o644,
+		CoversDirMode:       0o755,
+		APITimeout:          15 * time.Second,
+		DownloadTimeout:     15 * time.Second,
+		AutosaveInterval:    30 * time.Second,
+		ArchiveKeep:         7,
+		PhashThreshold:      10,
+		TrashDays:           30,
+		UpstreamQueue:       32,
+		CacheBackend:        "memory",
+		MaxIdleConnsPerHost: 32,
+		MaxConnsPerHost:     32,
+		FilenameMaxLength:   api.DefaultFilenameMaxLen,
+	}
+}
+
+// Load 解析命令行参数（不含程序名）并校验，返回最终配置。
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("otaku-chart-maker", flag.ContinueOnError)
+	fs.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "日志格式：text 或 json")
+	fs.StringVar(&cfg.UserAgent, "user-agent", cfg.UserAgent, "上游 API 请求的 User-Agent（建议附联系方式）")
+	fs.DurationVar(&cfg.APITimeout, "api-timeout", cfg.APITimeout, "搜索/浏览等元数据请求的超时")
+	fs.DurationVar(&cfg.DownloadTimeout, "download-timeout", cfg.DownloadTimeout, "封面下载的超时")
+	fs.BoolVar(&cfg.CoverSubdirs, "cover-subdirs", cfg.CoverSubdirs, "下载的封面按数据源存入 covers/<source>/ 子目录")
+	fs.BoolVar(&cfg.VerifyImages, "verify-images", cfg.VerifyImages, "保存前解码校验下载的封面，拒绝无法解码的数据（-verify-images=false 关闭）")
+	fs.Func("covers-file-mode", "写入 covers/ 的文件权限（八进制，默认 644）", func(v string) error {
+		return parseFileMode(v, &cfg.CoversFileMode)
+	})
+	fs.Func("covers-dir-mode", "在 covers/ 下新建目录的权限（八进制，默认 755）", func(v string) error {
+		return parseFileMode(v, &cfg.CoversDirMode)
+	})
+	fs.Func("library-dirs", "额外的只读封面图库目录，逗号分隔，与 covers/ 合并显示", func(v string) error {
+		cfg.LibraryDirs = nil
+		for _, d := range strings.Split(v, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				cfg.LibraryDirs = append(cfg.LibraryDirs, d)
+			}
+		}
+		return nil
+	})
+	fs.Func("cover-hosts", "允许下载封面的域名，逗号分隔，替换默认列表（*.example.com 匹配子域名）", func(v string) error {
+		cfg.CoverHosts = nil
+		for _, h := range strings.Split(v, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				cfg.CoverHosts = append(cfg.CoverHosts, h)
+			}
+		}
+		return nil
+	})
+	fs.BoolVar(&cfg.AllowAnyHost, "allow-any-host", cfg.AllowAnyHost, "允许从任意域名下载封面（关闭白名单）")
+	fs.StringVar(&cfg.FilenameTemplate, "filename-template", cfg.FilenameTemplate, "封面文件名模板，可用 {{.ID}} {{.Source}} {{.Title}} {{.Ext}} {{.Date}}")
+	fs.IntVar(&cfg.FilenameMaxLength, "filename-max-length", cfg.FilenameMaxLength,
+		fmt.Sprintf("封面文件名的最大字节数（%d~%d），超出时截短", api.MinFilenameMaxLen, api.MaxFilenameMaxLen))
+	fs.StringVar(&cfg.StateIndent, "state-indent", cfg.StateIndent, "保存图表状态的 JSON 缩进：2、tab 或 none")
+	fs.DurationVar(&cfg.AutosaveInterval, "autosave-interval", cfg.AutosaveInterval, "排队中的图表状态兜底落盘周期，0 表示关闭")
+	fs.DurationVar(&cfg.ArchiveInterval, "archive-interval", cfg.ArchiveInterval, "定时把 covers/ 与图表打包到 backups/ 的周期，如 24h，0 表示关闭")
+	fs.IntVar(&cfg.ArchiveKeep, "archive-keep", cfg.ArchiveKeep, "保留的最近归档数")
+	fs.Func("cache-ignore-fields", "生成上游响应缓存键时忽略的请求体字段，逗号分隔", func(v string) error {
+		cfg.CacheIgnoreFields = nil
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f != "" {
+				cfg.CacheIgnoreFields = append(cfg.CacheIgnoreFields, f)
+			}
+		}
+		return nil
+	})
+	fs.StringVar(&cfg.CacheBackend, "cache-backend", cfg.CacheBackend, "上游响应缓存后端：memory、disk（存入数据目录下的 cache/）或 none")
+	fs.IntVar(&cfg.UpstreamQueue, "upstream-queue", cfg.UpstreamQueue, "发往上游的并发请求上限，已满时返回 503，0 表示不限制")
+	fs.IntVar(&cfg.MaxIdleConnsPerHost, "max-idle-conns-per-host", cfg.MaxIdleConnsPerHost, "每个上游主机保留的空闲连接数")
+	fs.IntVar(&cfg.MaxConnsPerHost, "max-conns-per-host", cfg.MaxConnsPerHost, "每个上游主机的最大连接数，0 表示不限制")
+	fs.IntVar(&cfg.MaxBrowseLimit, "max-browse-limit", cfg.MaxBrowseLimit,
+		fmt.Sprintf("Bangumi 浏览单页结果上限（1~%d）", api.MaxBrowseLimit))
+	fs.IntVar(&cfg.VNDBMaxResults, "vndb-max-results", cfg.VNDBMaxResults,
+		fmt.Sprintf("VNDB 单次查询结果上限（1~%d）", api.VNDBMaxResults))
+	fs.IntVar(&cfg.VNDBPageSize, "vndb-page-size", cfg.VNDBPageSize, "VNDB 搜索默认每页结果数（未指定时不超过 -vndb-max-results）")
+	fs.Func("disable-sources", "关闭的数据源，逗号分隔（bgm/vndb/anidb）", func(v string) error {
+		cfg.DisabledSources = nil
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				cfg.DisabledSources = append(cfg.DisabledSources, s)
+			}
+		}
+		return nil
+	})
+	fs.StringVar(&cfg.AniDBClient, "anidb-client", cfg.AniDBClient, "在 AniDB 注册的 HTTP API 客户端名（需同时设置 -anidb-clientver）")
+	fs.IntVar(&cfg.AniDBClientVer, "anidb-clientver", cfg.AniDBClientVer, "在 AniDB 注册的客户端版本")
+	fs.StringVar(&cfg.AuthUser, "auth-user", cfg.AuthUser, "启用 HTTP Basic Auth 的用户名（需同时设置 -auth-pass）")
+	fs.StringVar(&cfg.AuthPass, "auth-pass", cfg.AuthPass, "启用 HTTP Basic Auth 的密码（需同时设置 -auth-user）")
+	fs.IntVar(&cfg.TrashDays, "trash-days", cfg.TrashDays, "删除的封面在回收站中保留的天数，0 表示不自动清除")
+	fs.BoolVar(&cfg.ReadOnly, "read-only", cfg.ReadOnly, "只读模式：只允许查看图表与搜索，拒绝所有写操作")
+	fs.BoolVar(&cfg.PrettyJSON, "pretty", cfg.PrettyJSON, "调试用：所有 JSON 响应缩进输出")
+	fs.StringVar(&cfg.FrontendDir, "frontend-dir", cfg.FrontendDir, "从指定目录读取前端文件（需含 index.html），覆盖自动检测")
+	fs.BoolVar(&cfg.Embedded, "embedded", cfg.Embedded, "强制使用嵌入的前端文件，忽略磁盘上的 frontend/")
+	fs.BoolVar(&cfg.Prefetch, "prefetch", cfg.Prefetch, "启动后在后台预热常用浏览查询")
+	fs.BoolVar(&cfg.PregenThumbs, "pregen-thumbs", cfg.PregenThumbs, "在后台预生成封面缩略图，加快首次加载")
+	fs.BoolVar(&cfg.Phash, "phash", cfg.Phash, "启用封面感知哈希去重检测")
+	fs.IntVar(&cfg.PhashThreshold, "phash-threshold", cfg.PhashThreshold, "近似重复判定的最大汉明距离（0~64）")
+	fs.StringVar(&cfg.FontPath, "font", cfg.FontPath, "服务端渲染标签使用的 TTF/OTF 字体路径（需含 CJK 字形）")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	// 未显式指定 -vndb-page-size 时跟随调小的 -vndb-max-results，只有显式给出过大的值才报错
+	pageSizeSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "vndb-page-size" {
+			pageSizeSet = true
+		}
+	})
+	if !pageSizeSet {
+		cfg.VNDBPageSize = min(cfg.VNDBPageSize, cfg.VNDBMaxResults)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// AuthEnabled 报告是否启用了 HTTP Basic Auth。
+func (c *Config) AuthEnabled() bool {
+	return c.AuthUser != "" && c.AuthPass != ""
+}
+
+// Validate 校验配置取值是否合法。
+func (c *Config) Validate() error {
+	switch c.LogFormat {
+	case LogFormatText, LogFormatJSON:
+	default:
+		return fmt.Errorf("无效的 -log-format: %q（可选 text/json）", c.LogFormat)
+	}
+	switch c.StateIndent {
+	case StateIndentTwo, StateIndentTab, StateIndentNone:
+	default:
+		return fmt.Errorf("无效的 -state-indent: %q（可选 2/tab/none）", c.StateIndent)
+	}
+	if c.APITimeout <= 0 {
+		return fmt.Errorf("-api-timeout 必须大于 0: %s", c.APITimeout)
+	}
+	if c.DownloadTimeout <= 0 {
+		return fmt.Errorf("-download-timeout 必须大于 0: %s", c.DownloadTimeout)
+	}
+	if c.AutosaveInterval < 0 {
+		return fmt.Errorf("-autosave-interval 不能为负数: %s", c.AutosaveInterval)
+	}
+	if c.ArchiveInterval != 0 && c.ArchiveInterval < time.Minute {
+		return fmt.Errorf("-archive-interval 不能小于 1 分钟: %s", c.ArchiveInterval)
+	}
+	if c.ArchiveKeep < 1 {
+		return fmt.Errorf("-archive-keep 必须大于 0: %d", c.ArchiveKeep)
+	}
+	if c.CoversFileMode&^0o777 != 0 || c.CoversFileMode&0o600 != 0o600 {
+		return fmt.Errorf("-covers-file-mode 需为 0~777 的八进制权限且包含属主读写: %#o", c.CoversFileMode)
+	}
+	if c.CoversDirMode&^0o777 != 0 || c.CoversDirMode&0o700 != 0o700 {
+		return fmt.Errorf("-covers-dir-mode 需为 0~777 的八进制权限且包含属主读写执行: %#o", c.CoversDirMode)
+	}
+	if c.AllowAnyHost && len(c.CoverHosts) > 0 {
+		return fmt.Errorf("-cover-hosts 与 -allow-any-host 不能同时使用")
+	}
+	if c.MaxBrowseLimit < 1 || c.MaxBrowseLimit > api.MaxBrowseLimit {
+		return fmt.Errorf("-max-browse-limit 需在 1~%d 之间: %d", api.MaxBrowseLimit, c.MaxBrowseLimit)
+	}
+	if c.VNDBMaxResults < 1 || c.VNDBMaxResults > api.VNDBMaxResults {
+		return fmt.Errorf("-vndb-max-results 需在 1~%d 之间: %d", api.VNDBMaxResults, c.VNDBMaxResults)
+	}
+	if c.VNDBPageSize < 1 || c.VNDBPageSize > c.VNDBMaxResults {
+		return fmt.Errorf("-vndb-page-size 需在 1~%d（-vndb-max-results）之间: %d", c.VNDBMaxResults, c.VNDBPageSize)
+	}
+	if c.FilenameMaxLength < api.MinFilenameMaxLen || c.FilenameMaxLength > api.MaxFilenameMaxLen {
+		return fmt.Errorf("-filename-max-length 需在 %d~%d 之间: %d", api.MinFilenameMaxLen, api.MaxFilenameMaxLen, c.FilenameMaxLength)
+	}
+	switch c.CacheBackend {
+	case "memory", "disk", "none":
+	default:
+		return fmt.Errorf("无效的 -cache-backend: %q（可选 memory/disk/none）", c.CacheBackend)
+	}
+	if c.UpstreamQueue < 0 {
+		return fmt.Errorf("-upstream-queue 不能为负数: %d", c.UpstreamQueue)
+	}
+	if c.MaxIdleConnsPerHost < 1 {
+		return fmt.Errorf("-max-idle-conns-per-host 必须大于 0: %d", c.MaxIdleConnsPerHost)
+	}
+	if c.MaxConnsPerHost < 0 {
+		return fmt.Errorf("-max-conns-per-host 不能为负数: %d", c.MaxConnsPerHost)
+	}
+	for _, s := range c.DisabledSources {
+		if s != "bgm" && s != "vndb" && s != "anidb" {
+			return fmt.Errorf("无效的 -disable-sources: %q（可选 bgm/vndb/anidb）", s)
+		}
+	}
+	if c.AniDBClientVer < 0 {
+		return fmt.Errorf("-anidb-clientver 不能为负数: %d", c.AniDBClientVer)
+	}
+	if (c.AniDBClient == "") != (c.AniDBClientVer == 0) {
+		return fmt.Errorf("-anidb-client 与 -anidb-clientver 需同时设置")
+	}
+	if c.TrashDays < 0 {
+		return fmt.Errorf("-trash-days 不能为负数: %d", c.TrashDays)
+	}
+	if c.FrontendDir != "" && c.Embedded {
+		return fmt.Errorf("-frontend-dir 与 -embedded 不能同时使用")
+	}
+	if (c.AuthUser == "") != (c.AuthPass == "") {
+		return fmt.Errorf("-auth-user 与 -auth-pass 需同时设置")
+	}
+	if c.PhashThreshold < 0 || c.PhashThreshold > 64 {
+		return fmt.Errorf("-phash-threshold 需在 0~64 之间: %d", c.PhashThreshold)
+	}
+	return nil
+}
+
+// parseFileMode 解析八进制权限字符串（如 664、0o775），只接受权限位。
+func parseFileMode(v string, dst *os.FileMode) error {
+	v = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(v), "0o"), "0O")
+	n, err := strconv.ParseUint(v, 8, 32)
+	if err != nil || n > 0o777 {
+		return fmt.Errorf("无效的八进制权限: %q", v)
+	}
+	*dst = os.FileMode(n)
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+func TestLoadVNDBPageSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    int
+		wantErr bool
+	}{
+		{"default", nil, 20, false},
+		{"max results only", []string{"-vndb-max-results", "10"}, 10, false},
+		{"explicit within limit", []string{"-vndb-max-results", "10", "-vndb-page-size", "5"}, 5, false},
+		{"explicit over limit", []string{"-vndb-max-results", "10", "-vndb-page-size", "20"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Load(%v) succeeded, want error", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load(%v): %v", tt.args, err)
+			}
+			if cfg.VNDBPageSize != tt.want {
+				t.Errorf("VNDBPageSize = %d, want %d", cfg.VNDBPageSize, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateResultCaps(t *testing.T) {
+	tests := []struct {
+		flag    string
+		max     int
+		current func(*Config) int
+	}{
+		{"-max-browse-limit", api.MaxBrowseLimit, func(c *Config) int { return c.MaxBrowseLimit }},
+		{"-vndb-max-results", api.VNDBMaxResults, func(c *Config) int { return c.VNDBMaxResults }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			cfg, err := Load(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.current(cfg); got != tt.max {
+				t.Errorf("default = %d, want %d", got, tt.max)
+			}
+			if _, err := Load([]string{tt.flag, strconv.Itoa(tt.max)}); err != nil {
+				t.Errorf("Load(%s %d): %v", tt.flag, tt.max, err)
+			}
+			for _, n := range []int{0, tt.max + 1} {
+				if _, err := Load([]string{tt.flag, strconv.Itoa(n)}); err == nil {
+					t.Errorf("Load(%s %d) succeeded, want error", tt.flag, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package imaging
+
+import (
+	"cmp"
+	"fmt"
+	"image"
+	"slices"
+)
+
+// colorSampleSide 是主色提取时长边的采样点数，64×64 以内的采样足以区分主要色块。
+const colorSampleSide = 64
+
+// Swatch 是一种主色及其在采样像素中的占比。
+type Swatch struct {
+	R, G, B uint8
+	Weight  float64 // 0~1
+}
+
+// Hex 返回 #rrggbb 形式的颜色值。
+func (s Swatch) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", s.R, s.G, s.B)
+}
+
+// DominantColors 用中位切分提取图片中最多 k 种主色，按占比从高到低排列。
+// 按步长抽样而非逐像素扫描，大图的耗时与小图相近；半透明以下的像素不参与统计。
+func DominantColors(img image.Image, k int) []Swatch {
+	b := img.Bounds()
+	if k <= 0 || b.Dx() == 0 || b.Dy() == 0 {
+		return nil
+	}
+
+	step := max(max(b.Dx(), b.Dy())/colorSampleSide, 1)
+	var pixels [][3]uint8
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if a < 0x8000 {
+				continue
+			}
+			pixels = append(pixels, [3]uint8{uint8(r >> 8), uint8(g >> 8), uint8(bl >> 8)})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil
+	}
+
+	boxes := [][][3]uint8{pixels}
+	for len(boxes) < k {
+		// 选通道跨度最大的色块切分，跨度为 0 说明剩余色块都是纯色，无需再分
+		best, bestCh, bestSpan := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, span := widestChannel(box); span > bestSpan {
+				best, bestCh, bestSpan = i, ch, span
+			}
+		}
+		if best < 0 {
+			break
+		}
+		box := boxes[best]
+		slices.SortFunc(box, func(a, b [3]uint8) int { return cmp.Compare(a[bestCh], b[bestCh]) })
+		mid := len(box) / 2
+		boxes[best] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	swatches := make([]Swatch, 0, len(boxes))
+	for _, box := range boxes {
+		var sum [3]int
+		for _, p := range box {
+			sum[0] += int(p[0])
+			sum[1] += int(p[1])
+			sum[2] += int(p[2])
+		}
+		n := len(box)
+		swatches = append(swatches, Swatch{
+			R:      uint8(sum[0] / n),
+			G:      uint8(sum[1] / n),
+			B:      uint8(sum[2] / n),
+			Weight: float64(n) / float64(len(pixels)),
+		})
+	}
+	slices.SortStableFunc(swatches, func(a, b Swatch) int { return cmp.Compare(b.Weight, a.Weight) })
+	return swatches
+}
+
+// widestChannel 返回色块中取值跨度最大的通道及其跨度。
+func widestChannel(box [][3]uint8) (ch, span int) {
+	lo := [3]uint8{255, 255, 255}
+	var hi [3]uint8
+	for _, p := range box {
+		for c := range 3 {
+			lo[c] = min(lo[c], p[c])
+			hi[c] = max(hi[c], p[c])
+		}
+	}
+	for c := range 3 {
+		if s := int(hi[c]) - int(lo[c]); s > span {
+			ch, span = c, s
+		}
+	}
+	return ch, span
+}
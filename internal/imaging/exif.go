@@ -0,0 +1,226 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNoEXIF 表示图片不是 JPEG 或其中没有 EXIF 段。
+var ErrNoEXIF = errors.New("没有 EXIF 信息")
+
+// EXIF 是从 JPEG 的 APP1 段读出的常用拍摄信息，缺失的字段为零值。
+type EXIF struct {
+	CaptureTime string // 拍摄时间（DateTimeOriginal，缺失时取 DateTime），格式 2006-01-02T15:04:05，不含时区
+	Make        string // 相机/扫描仪厂商
+	Model       string // 相机/扫描仪型号
+	Software    string // 处理软件
+	Orientation int    // 方向（1~8），0 表示未记录
+	Width       int    // PixelXDimension
+	Height      int    // PixelYDimension
+}
+
+// 读取的 EXIF 标签。
+const (
+	tagMake             = 0x010f
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagSoftware         = 0x0131
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+	tagPixelXDimension  = 0xa002
+	tagPixelYDimension  = 0xa003
+)
+
+// exifHeader 是 APP1 段中 EXIF 数据的前缀。
+const exifHeader = "Exif\x00\x00"
+
+// ReadEXIF 读取 JPEG 文件的 EXIF 信息；非 JPEG 或没有 EXIF 段时返回 ErrNoEXIF。
+func ReadEXIF(path string) (*EXIF, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := findEXIFSegment(bufio.NewReader(f))
+	if err != nil {
+		return nil, err
+	}
+	return parseTIFF(data)
+}
+
+// findEXIFSegment 顺序扫描 JPEG 段，返回 EXIF APP1 段中 TIFF 头开始的数据。
+// 遇到图像数据（SOS）或文件结束仍未找到时返回 ErrNoEXIF，不读取像素数据。
+func findEXIFSegment(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xff, 0xd8} {
+		return nil, ErrNoEXIF
+	}
+	for {
+		marker, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrNoEXIF
+		}
+		if marker != 0xff {
+			return nil, fmt.Errorf("JPEG 段格式错误")
+		}
+		kind, err := r.ReadByte()
+		for err == nil && kind == 0xff { // 段之间允许填充 0xff
+			kind, err = r.ReadByte()
+		}
+		if err != nil || kind == 0xd9 || kind == 0xda {
+			return nil, ErrNoEXIF
+		}
+		if kind >= 0xd0 && kind <= 0xd7 || kind == 0x01 {
+			continue // 无长度字段的标记
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, ErrNoEXIF
+		}
+		n := int(binary.BigEndian.Uint16(size[:])) - 2
+		if n < 0 {
+			return nil, fmt.Errorf("JPEG 段长度错误")
+		}
+		if kind != 0xe1 {
+			if _, err := r.Discard(n); err != nil {
+				return nil, ErrNoEXIF
+			}
+			continue
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return nil, ErrNoEXIF
+		}
+		if data, ok := bytes.CutPrefix(seg, []byte(exifHeader)); ok {
+			return data, nil
+		}
+		// 其他 APP1（如 XMP）继续查找
+	}
+}
+
+// tiffReader 按 TIFF 头声明的字节序读取 IFD，所有偏移都做越界检查。
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// ifdEntry 是 IFD 中的一项；value 是原始的 4 字节值/偏移字段。
+type ifdEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// parseTIFF 解析 TIFF 头、IFD0 与 Exif 子 IFD，取出 EXIF 中的常用字段。
+func parseTIFF(data []byte) (*EXIF, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("EXIF 数据过短")
+	}
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("EXIF 字节序无效")
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, fmt.Errorf("EXIF 头无效")
+	}
+
+	ifd0, err := t.ifd(t.order.Uint32(data[4:]))
+	if err != nil {
+		return nil, err
+	}
+	var exifIFD map[uint16]ifdEntry
+	if e, ok := ifd0[tagExifIFD]; ok {
+		// Exif 子 IFD 损坏时仍返回 IFD0 中的信息
+		exifIFD, _ = t.ifd(t.order.Uint32(e.value))
+	}
+
+	x := &EXIF{
+		Make:        t.ascii(ifd0[tagMake]),
+		Model:       t.ascii(ifd0[tagModel]),
+		Software:    t.ascii(ifd0[tagSoftware]),
+		Orientation: t.uint(ifd0[tagOrientation]),
+		Width:       t.uint(exifIFD[tagPixelXDimension]),
+		Height:      t.uint(exifIFD[tagPixelYDimension]),
+	}
+	captured := t.ascii(exifIFD[tagDateTimeOriginal])
+	if captured == "" {
+		captured = t.ascii(ifd0[tagDateTime])
+	}
+	x.CaptureTime = formatEXIFTime(captured)
+	return x, nil
+}
+
+// ifd 读取 off 处的一个 IFD，返回标签到条目的映射。
+func (t *tiffReader) ifd(off uint32) (map[uint16]ifdEntry, error) {
+	if uint64(off)+2 > uint64(len(t.data)) {
+		return nil, fmt.Errorf("EXIF IFD 偏移越界")
+	}
+	n := int(t.order.Uint16(t.data[off:]))
+	start := int(off) + 2
+	if start+n*12 > len(t.data) {
+		return nil, fmt.Errorf("EXIF IFD 条目越界")
+	}
+	entries := make(map[uint16]ifdEntry, n)
+	for i := range n {
+		b := t.data[start+i*12:]
+		entries[t.order.Uint16(b)] = ifdEntry{
+			typ:   t.order.Uint16(b[2:]),
+			count: t.order.Uint32(b[4:]),
+			value: b[8:12],
+		}
+	}
+	return entries, nil
+}
+
+// ascii 读取 ASCII 类型（2）的条目；不超过 4 字节时值内联在条目中，否则位于偏移处。
+func (t *tiffReader) ascii(e ifdEntry) string {
+	if e.typ != 2 || e.count == 0 {
+		return ""
+	}
+	raw := e.value
+	if e.count > 4 {
+		off := uint64(t.order.Uint32(e.value))
+		if off+uint64(e.count) > uint64(len(t.data)) {
+			return ""
+		}
+		raw = t.data[off : off+uint64(e.count)]
+	} else {
+		raw = raw[:e.count]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(raw), "\x00"))
+}
+
+// uint 读取 SHORT（3）或 LONG（4）类型的单值条目。
+func (t *tiffReader) uint(e ifdEntry) int {
+	if e.count != 1 {
+		return 0
+	}
+	switch e.typ {
+	case 3:
+		return int(t.order.Uint16(e.value))
+	case 4:
+		return int(t.order.Uint32(e.value))
+	}
+	return 0
+}
+
+// formatEXIFTime 把 EXIF 的 "2006:01:02 15:04:05" 转为 "2006-01-02T15:04:05"；格式不符或全零时返回空串。
+func formatEXIFTime(s string) string {
+	if len(s) != 19 || s[4] != ':' || s[7] != ':' || s[10] != ' ' || strings.HasPrefix(s, "0000") {
+		return ""
+	}
+	return s[:4] + "-" + s[5:7] + "-" + s[8:10] + "T" + s[11:]
+}
@@ -0,0 +1,331 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// tiffEntry 是测试用 IFD 条目：value 为 ASCII 等原始字节，不超过 4 字节时内联，否则写入数据区；
+// value 为 nil 时按 typ 把 num 编码为 SHORT/LONG。
+type tiffEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+	num   uint32
+}
+
+func asciiEntry(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), value: []byte(s + "\x00")}
+}
+
+func shortEntry(tag uint16, n uint16) tiffEntry {
+	return tiffEntry{tag: tag, typ: 3, count: 1, num: uint32(n)}
+}
+
+func longEntry(tag uint16, n uint32) tiffEntry {
+	return tiffEntry{tag: tag, typ: 4, count: 1, num: n}
+}
+
+// buildTIFF 生成 TIFF 头 + IFD0（+ Exif 子 IFD）+ 数据区。exif 非 nil 时在 IFD0 末尾追加指向它的条目。
+func buildTIFF(order binary.ByteOrder, ifd0, exif []tiffEntry) []byte {
+	ifdSize := func(n int) int { return 2 + n*12 + 4 }
+	if exif != nil {
+		ifd0 = append(append([]tiffEntry{}, ifd0...), tiffEntry{tag: tagExifIFD, typ: 4, count: 1})
+	}
+	ifd0Off := 8
+	exifOff := ifd0Off + ifdSize(len(ifd0))
+	dataOff := exifOff
+	if exif != nil {
+		dataOff += ifdSize(len(exif))
+	}
+
+	buf := make([]byte, dataOff)
+	if order == binary.LittleEndian {
+		copy(buf, "II")
+	} else {
+		copy(buf, "MM")
+	}
+	order.PutUint16(buf[2:], 42)
+	order.PutUint32(buf[4:], uint32(ifd0Off))
+
+	write := func(off int, entries []tiffEntry) {
+		order.PutUint16(buf[off:], uint16(len(entries)))
+		for i, e := range entries {
+			pos := off + 2 + i*12
+			order.PutUint16(buf[pos:], e.tag)
+			order.PutUint16(buf[pos+2:], e.typ)
+			order.PutUint32(buf[pos+4:], e.count)
+			switch {
+			case e.tag == tagExifIFD && exif != nil:
+				order.PutUint32(buf[pos+8:], uint32(exifOff))
+			case e.value == nil && e.typ == 3:
+				order.PutUint16(buf[pos+8:], uint16(e.num))
+			case e.value == nil:
+				order.PutUint32(buf[pos+8:], e.num)
+			case len(e.value) <= 4:
+				copy(buf[pos+8:pos+12], e.value)
+			default:
+				order.PutUint32(buf[pos+8:], uint32(len(buf)))
+				buf = append(buf, e.value...)
+			}
+		}
+	}
+	write(ifd0Off, ifd0)
+	if exif != nil {
+		write(exifOff, exif)
+	}
+	return buf
+}
+
+// sampleTIFF 是字段齐全的 EXIF：Make 位于偏移处，Model 内联，宽为 LONG、高为 SHORT。
+func sampleTIFF(order binary.ByteOrder) []byte {
+	return buildTIFF(order,
+		[]tiffEntry{
+			asciiEntry(tagMake, "Canon"),
+			asciiEntry(tagModel, "X1"),
+			asciiEntry(tagSoftware, " GIMP 2.10 "),
+			shortEntry(tagOrientation, 6),
+			asciiEntry(tagDateTime, "2020:01:01 00:00:00"),
+		},
+		[]tiffEntry{
+			asciiEntry(tagDateTimeOriginal, "2024:05:06 07:08:09"),
+			longEntry(tagPixelXDimension, 4000),
+			shortEntry(tagPixelYDimension, 3000),
+		})
+}
+
+var sampleEXIF = &EXIF{
+	CaptureTime: "2024-05-06T07:08:09",
+	Make:        "Canon",
+	Model:       "X1",
+	Software:    "GIMP 2.10",
+	Orientation: 6,
+	Width:       4000,
+	Height:      3000,
+}
+
+func TestParseTIFF(t *testing.T) {
+	le := binary.LittleEndian
+	tests := []struct {
+		name    string
+		data    func() []byte
+		want    *EXIF
+		wantErr bool
+	}{
+		{"little endian", func() []byte { return sampleTIFF(binary.LittleEndian) }, sampleEXIF, false},
+		{"big endian", func() []byte { return sampleTIFF(binary.BigEndian) }, sampleEXIF, false},
+		{
+			"inline ascii of exactly 4 bytes",
+			func() []byte { return buildTIFF(le, []tiffEntry{asciiEntry(tagModel, "ABC")}, nil) },
+			&EXIF{Model: "ABC"}, false,
+		},
+		{
+			"DateTime when DateTimeOriginal is missing",
+			func() []byte {
+				return buildTIFF(le, []tiffEntry{asciiEntry(tagDateTime, "2021:02:03 04:05:06")}, []tiffEntry{})
+			},
+			&EXIF{CaptureTime: "2021-02-03T04:05:06"}, false,
+		},
+		{
+			"zero date dropped",
+			func() []byte { return buildTIFF(le, []tiffEntry{asciiEntry(tagDateTime, "0000:00:00 00:00:00")}, nil) },
+			&EXIF{}, false,
+		},
+		{
+			"ascii offset out of range",
+			func() []byte {
+				bad := tiffEntry{tag: tagMake, typ: 2, count: 50, value: []byte{0xff, 0xff, 0, 0}}
+				return buildTIFF(le, []tiffEntry{bad, asciiEntry(tagModel, "X1")}, nil)
+			},
+			&EXIF{Model: "X1"}, false,
+		},
+		{
+			"wrong types ignored",
+			func() []byte {
+				return buildTIFF(le, []tiffEntry{
+					{tag: tagMake, typ: 3, count: 1, num: 7},
+					{tag: tagOrientation, typ: 2, count: 2, value: []byte("6\x00")},
+					{tag: tagModel, typ: 3, count: 2, num: 1},
+				}, nil)
+			},
+			&EXIF{}, false,
+		},
+		{
+			"exif sub-IFD out of range keeps IFD0",
+			func() []byte {
+				return buildTIFF(le, []tiffEntry{asciiEntry(tagModel, "X1"), longEntry(tagExifIFD, 1<<20)}, nil)
+			},
+			&EXIF{Model: "X1"}, false,
+		},
+		{"too short", func() []byte { return []byte("II*\x00") }, nil, true},
+		{"bad byte order", func() []byte { return []byte("XX*\x00\x08\x00\x00\x00\x00\x00") }, nil, true},
+		{
+			"bad magic",
+			func() []byte {
+				b := sampleTIFF(le)
+				le.PutUint16(b[2:], 43)
+				return b
+			},
+			nil, true,
+		},
+		{
+			"IFD0 offset out of range",
+			func() []byte {
+				b := sampleTIFF(le)
+				le.PutUint32(b[4:], 1<<31)
+				return b
+			},
+			nil, true,
+		},
+		{
+			"IFD0 offset at last byte",
+			func() []byte {
+				b := sampleTIFF(le)
+				le.PutUint32(b[4:], uint32(len(b)-1))
+				return b
+			},
+			nil, true,
+		},
+		{
+			"truncated IFD",
+			func() []byte { return sampleTIFF(binary.BigEndian)[:8+2+12*2] },
+			nil, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTIFF(tt.data())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseTIFF = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTIFF = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// jpegSegment 编码一个带长度字段的 JPEG 段。
+func jpegSegment(kind byte, payload []byte) []byte {
+	seg := []byte{0xff, kind, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// jpegWith 拼出 SOI + 各段 + SOS 开始的图像数据。
+func jpegWith(segments ...[]byte) []byte {
+	b := []byte{0xff, 0xd8}
+	for _, s := range segments {
+		b = append(b, s...)
+	}
+	b = append(b, jpegSegment(0xda, []byte{1, 2, 3})...)
+	return append(b, 0xde, 0xad, 0xff, 0xd9)
+}
+
+func TestFindEXIFSegment(t *testing.T) {
+	tiff := sampleTIFF(binary.LittleEndian)
+	exif := jpegSegment(0xe1, append([]byte(exifHeader), tiff...))
+	xmp := jpegSegment(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	app0 := jpegSegment(0xe0, []byte("JFIF\x00\x01\x02"))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []byte
+		wantErr error
+	}{
+		{"exif after app0", jpegWith(app0, exif), tiff, nil},
+		{"exif after xmp", jpegWith(xmp, exif), tiff, nil},
+		{"fill bytes before marker", append([]byte{0xff, 0xd8, 0xff, 0xff}, exif[1:]...), tiff, nil},
+		{"xmp only", jpegWith(app0, xmp), nil, ErrNoEXIF},
+		{"no app1", jpegWith(app0), nil, ErrNoEXIF},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), nil, ErrNoEXIF},
+		{"empty", nil, nil, ErrNoEXIF},
+		{"soi only", []byte{0xff, 0xd8}, nil, ErrNoEXIF},
+		{"truncated segment", jpegWith(exif)[:len(exif)-4], nil, ErrNoEXIF},
+		{"truncated length", []byte{0xff, 0xd8, 0xff, 0xe1, 0x00}, nil, ErrNoEXIF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findEXIFSegment(bufio.NewReader(bytes.NewReader(tt.data)))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("segment = %x, want %x", got, tt.want)
+			}
+		})
+	}
+
+	// 段标记前不是 0xff、段长度小于 2 属于格式错误，但不是 ErrNoEXIF
+	for _, data := range [][]byte{
+		{0xff, 0xd8, 0x00, 0xe1},
+		{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x01},
+	} {
+		if _, err := findEXIFSegment(bufio.NewReader(bytes.NewReader(data))); err == nil || errors.Is(err, ErrNoEXIF) {
+			t.Errorf("findEXIFSegment(%x) err = %v, want format error", data, err)
+		}
+	}
+}
+
+func TestReadEXIF(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tiff := sampleTIFF(binary.BigEndian)
+	got, err := ReadEXIF(write("a.jpg", jpegWith(jpegSegment(0xe1, append([]byte(exifHeader), tiff...)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, sampleEXIF) {
+		t.Errorf("ReadEXIF = %+v, want %+v", got, sampleEXIF)
+	}
+
+	if _, err := ReadEXIF(write("b.png", []byte("\x89PNG\r\n\x1a\n"))); !errors.Is(err, ErrNoEXIF) {
+		t.Errorf("ReadEXIF(png) err = %v, want ErrNoEXIF", err)
+	}
+	if _, err := ReadEXIF(filepath.Join(dir, "missing.jpg")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadEXIF(missing) err = %v, want ErrNotExist", err)
+	}
+}
+
+func FuzzParseTIFF(f *testing.F) {
+	f.Add(sampleTIFF(binary.LittleEndian))
+	f.Add(sampleTIFF(binary.BigEndian))
+	f.Add(buildTIFF(binary.LittleEndian, []tiffEntry{asciiEntry(tagModel, "ABC")}, nil))
+	f.Add([]byte("MM\x00\x2a\x00\x00\x00\x08\xff\xff"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		x, err := parseTIFF(data)
+		if err != nil {
+			return
+		}
+		if x.CaptureTime != "" && len(x.CaptureTime) != 19 {
+			t.Errorf("CaptureTime = %q", x.CaptureTime)
+		}
+	})
+}
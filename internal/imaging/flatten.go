@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+)
+
+// pngSignature 是 PNG 文件的 8 字节文件头。
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// Flatten 在 data 是动图（多帧 GIF 或 APNG）时返回第一帧的 PNG 编码与 true；
+// 静态图片及其他格式原样返回 false，不重新编码。
+func Flatten(data []byte) ([]byte, bool, error) {
+	var frame image.Image
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("解码 GIF 失败: %w", err)
+		}
+		if len(g.Image) < 2 {
+			return data, false, nil
+		}
+		// 第一帧可能只覆盖画布的一部分，合成到完整画布上
+		canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+		draw.Draw(canvas, g.Image[0].Bounds(), g.Image[0], g.Image[0].Bounds().Min, draw.Over)
+		frame = canvas
+	case bytes.HasPrefix(data, []byte(pngSignature)):
+		if !isAPNG(data) {
+			return data, false, nil
+		}
+		// 标准 PNG 解码器只读取默认图像（IDAT），即 APNG 的第一帧
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("解码 PNG 失败: %w", err)
+		}
+		frame = img
+	default:
+		return data, false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, frame); err != nil {
+		return nil, false, fmt.Errorf("编码 PNG 失败: %w", err)
+	}
+	return buf.Bytes(), true, nil
+}
+
+// isAPNG 判断 PNG 在图像数据之前是否带有动画控制块（acTL）。
+func isAPNG(data []byte) bool {
+	for rest := data[len(pngSignature):]; len(rest) >= 8; {
+		n := int(binary.BigEndian.Uint32(rest[:4]))
+		switch string(rest[4:8]) {
+		case "acTL":
+			return true
+		case "IDAT":
+			return false
+		}
+		if n < 0 || len(rest) < 12+n {
+			return false
+		}
+		rest = rest[12+n:]
+	}
+	return false
+}
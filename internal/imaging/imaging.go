@@ -0,0 +1,54 @@
+package imaging
+
+// imaging 包提供封面图片的解码与分析工具（感知哈希等）。
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif" // 注册 GIF 解码器
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+
+	_ "golang.org/x/image/bmp" // 注册 BMP 解码器
+	_ "golang.org/x/image/webp"
+)
+
+// Load 解码图片文件。
+func Load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+	return img, nil
+}
+
+// Verify 完整解码 r 中的图片并丢弃结果，用于确认数据没有截断或损坏。
+func Verify(r io.Reader) error {
+	if _, _, err := image.Decode(r); err != nil {
+		return fmt.Errorf("解码图片失败: %w", err)
+	}
+	return nil
+}
+
+// Dimensions 只解析图片头部，返回宽高，不解码像素数据。
+func Dimensions(path string) (width, height int, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("解析图片尺寸失败: %w", err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
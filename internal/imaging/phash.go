@@ -0,0 +1,62 @@
+package imaging
+
+import (
+	"image"
+	"math/bits"
+)
+
+// DHash 计算 64 位差值哈希：缩放为 9×8 灰度后比较水平相邻像素的明暗。
+// 重新压缩、轻微缩放的同一图片哈希相近，可用汉明距离判断近似重复。
+func DHash(img image.Image) uint64 {
+	const w, h = 9, 8
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return 0
+	}
+
+	// 区域平均采样，比最近邻更抗压缩噪点
+	var gray [h][w]float64
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/h, y0+1)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(b.Min.X+(x+1)*b.Dx()/w, x0+1)
+			gray[y][x] = averageLuma(img, x0, y0, x1, y1)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance 返回两个哈希的汉明距离（0~64），越小越相似。
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// averageLuma 计算矩形区域的平均亮度；区域较大时按步长抽样以控制开销。
+func averageLuma(img image.Image, x0, y0, x1, y1 int) float64 {
+	step := max((x1-x0)/16, (y1-y0)/16, 1)
+	var sum float64
+	var n int
+	for y := y0; y < y1; y += step {
+		for x := x0; x < x1; x += step {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
@@ -0,0 +1,20 @@
+package imaging
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// Resize 将图片等比缩放到不超过 maxWidth 的宽度；原图不宽于 maxWidth 时原样返回。
+func Resize(img image.Image, maxWidth int) image.Image {
+	b := img.Bounds()
+	if maxWidth <= 0 || b.Dx() <= maxWidth {
+		return img
+	}
+
+	height := max(1, b.Dy()*maxWidth/b.Dx())
+	dst := image.NewRGBA(image.Rect(0, 0, maxWidth, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
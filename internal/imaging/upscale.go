@@ -0,0 +1,25 @@
+package imaging
+
+import (
+	"image"
+	"math"
+
+	"golang.org/x/image/draw"
+)
+
+// Upscale 在图片短边小于 minSide 时用 CatmullRom 等比放大，使短边达到 minSide；
+// 放大倍数不超过 maxFactor，避免把极小的图片拉成一片模糊。短边已达到 minSide 时原样返回 false。
+func Upscale(img image.Image, minSide int, maxFactor float64) (image.Image, bool) {
+	b := img.Bounds()
+	short := min(b.Dx(), b.Dy())
+	if short <= 0 || short >= minSide || maxFactor <= 1 {
+		return img, false
+	}
+
+	factor := min(float64(minSide)/float64(short), maxFactor)
+	w := int(math.Round(float64(b.Dx()) * factor))
+	h := int(math.Round(float64(b.Dy()) * factor))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst, true
+}
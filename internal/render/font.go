@@ -0,0 +1,88 @@
+package render
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/font/opentype"
+)
+
+// LatinFontName 是内置 Latin 字体的显示名（PDF 标准 Helvetica，不含 CJK 字形）。
+const LatinFontName = "Helvetica"
+
+// systemCJKFonts 是常见系统自带、含 CJK 字形的 TrueType 字体路径，未指定 -font 时依次探测。
+// PDF 嵌入只支持 glyf 轮廓的 TTF，因此不列入 OTF/TTC 字体。
+var systemCJKFonts = []string{
+	`C:\Windows\Fonts\simhei.ttf`,
+	`C:\Windows\Fonts\simkai.ttf`,
+	"/Library/Fonts/Arial Unicode.ttf",
+	"/System/Library/Fonts/Supplemental/Arial Unicode.ttf",
+	"/usr/share/fonts/truetype/droid/DroidSansFallbackFull.ttf",
+	"/usr/share/fonts/truetype/arphic-gkai00mp/gkai00mp.ttf",
+}
+
+// embeddedFonts 是编入程序的回退字体目录，见 fonts/README.md。只放子集 TTF，控制二进制体积。
+//
+//go:embed fonts
+var embeddedFonts embed.FS
+
+// Font 是渲染标签使用的字体；Data 为空时使用内置 Latin 字体。
+type Font struct {
+	Name string
+	Data []byte
+}
+
+// LatinFont 返回内置 Latin 字体。
+func LatinFont() *Font {
+	return &Font{Name: LatinFontName}
+}
+
+// IsLatin 判断是否为内置 Latin 字体。
+func (f *Font) IsLatin() bool {
+	return f == nil || len(f.Data) == 0
+}
+
+// LoadFont 读取并校验 TTF/OTF 字体文件。
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取字体失败: %w", err)
+	}
+	return parseFont(filepath.Base(path), data)
+}
+
+// parseFont 校验字体数据。
+func parseFont(name string, data []byte) (*Font, error) {
+	if _, err := opentype.Parse(data); err != nil {
+		return nil, fmt.Errorf("解析字体失败: %w", err)
+	}
+	return &Font{Name: name, Data: data}, nil
+}
+
+// DetectCJKFont 探测系统中可用的 CJK 字体，都没有时使用内嵌的子集字体（最后一级回退），仍没有时返回 nil。
+func DetectCJKFont() *Font {
+	for _, path := range systemCJKFonts {
+		if font, err := LoadFont(path); err == nil {
+			return font
+		}
+	}
+	return EmbeddedCJKFont()
+}
+
+// EmbeddedCJKFont 返回 fonts/ 中按文件名排序的第一个可用 TTF，构建时未放入字体则返回 nil。
+func EmbeddedCJKFont() *Font {
+	names, _ := fs.Glob(embeddedFonts, "fonts/*.ttf") // 按文件名排序
+	for _, name := range names {
+		data, err := embeddedFonts.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		if font, err := parseFont(filepath.Base(name), data); err == nil {
+			return font
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package render
+
+import (
+	"testing"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// commonCJK 是标签中常见的汉字与假名，内嵌的子集字体必须全部包含。
+const commonCJK = "的一是不了人我在有他这中大来上国个到说们为子和你地出道也时年动画漫游戏小恋爱冒险校园奇幻科悬疑日常百合搞笑运战斗魔法治愈偶像音乐后宫热血推理历史あいうえおアイウエオー"
+
+func TestEmbeddedCJKFontCoversCommonCJK(t *testing.T) {
+	font := EmbeddedCJKFont()
+	if font == nil {
+		t.Skip("internal/render/fonts/ 中未放入子集字体")
+	}
+	// PDF 只能嵌入 glyf 轮廓的 TrueType，CFF 轮廓的字体会在渲染时被静默替换为 Helvetica
+	if len(font.Data) < 4 || string(font.Data[:4]) != "\x00\x01\x00\x00" {
+		t.Fatalf("%s 不是 glyf 轮廓的 TrueType 字体", font.Name)
+	}
+	f, err := sfnt.Parse(font.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf sfnt.Buffer
+	var missing []rune
+	for _, r := range commonCJK {
+		if idx, err := f.GlyphIndex(&buf, r); err != nil || idx == 0 {
+			missing = append(missing, r)
+		}
+	}
+	if len(missing) > 0 {
+		t.Errorf("%s 缺少 %d 个常用字形: %s", font.Name, len(missing), string(missing))
+	}
+}
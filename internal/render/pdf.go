@@ -0,0 +1,215 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// A4 纵向页面布局参数（单位 mm）。
+const (
+	pdfPageW  = 210.0
+	pdfPageH  = 297.0
+	pdfMargin = 10.0
+	pdfTitleH = 12.0
+	pdfLabelH = 6.0
+	pdfGap    = 2.0
+)
+
+// pdfLabelFamily 是嵌入 UTF-8 字体时在 PDF 内注册的字体族名。
+const pdfLabelFamily = "label"
+
+// 嵌入字体没有粗体字形，标题用描边模拟加粗。
+const (
+	pdfTextFill       = 0   // PDF 文字渲染模式：仅填充
+	pdfTextFillStroke = 2   // PDF 文字渲染模式：填充并描边
+	pdfTitleStroke    = 0.3 // 标题描边线宽（mm）
+)
+
+// PDF 将图表排版到 A4 页面并写出 PDF；格子超过一页时自动分页。
+// 标签使用 font 渲染，font 为 nil 或无法嵌入时回退到内置 Latin 字体。返回实际使用的字体与画布尺寸。
+func PDF(w io.Writer, chart Chart, font *Font) (Result, error) {
+	return PDFWithProgress(w, chart, font, nil)
+}
+
+// PDFWithProgress 与 PDF 相同，但每合成完一个格子调用一次 progress(已完成数, 总数)。
+// progress 为 nil 时不回调。
+func PDFWithProgress(w io.Writer, chart Chart, font *Font, progress func(done, total int)) (Result, error) {
+	if err := chart.normalize(); err != nil {
+		return Result{}, err
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+
+	family, tr, fontName := setupPDFFont(pdf, font)
+
+	gridTop := pdfMargin
+	if chart.Title != "" {
+		gridTop += pdfTitleH
+	}
+
+	// 格子宽度先按页宽均分；竖长比例下单行超出页高时按页高缩小，网格水平居中
+	cellW := (pdfPageW - 2*pdfMargin - float64(chart.Columns-1)*pdfGap) / float64(chart.Columns)
+	coverH := cellW / chart.CellAspect
+	if maxCoverH := pdfPageH - gridTop - pdfMargin - pdfLabelH; coverH > maxCoverH {
+		coverH = maxCoverH
+		cellW = coverH * chart.CellAspect
+	}
+	cellH := coverH + pdfLabelH
+	gridLeft := (pdfPageW - float64(chart.Columns)*cellW - float64(chart.Columns-1)*pdfGap) / 2
+	rowsPerPage := int(math.Floor((pdfPageH - gridTop - pdfMargin + pdfGap) / (cellH + pdfGap)))
+	if rowsPerPage < 1 {
+		rowsPerPage = 1
+	}
+	perPage := rowsPerPage * chart.Columns
+
+	for i, cell := range chart.Cells {
+		if i%perPage == 0 {
+			pdf.AddPage()
+			if chart.Title != "" {
+				drawTitle(pdf, family, tr(chart.Title))
+			}
+		}
+
+		idx := i % perPage
+		x := gridLeft + float64(idx%chart.Columns)*(cellW+pdfGap)
+		y := gridTop + float64(idx/chart.Columns)*(cellH+pdfGap)
+
+		pdf.SetDrawColor(200, 200, 200)
+		pdf.Rect(x, y, cellW, coverH, "D")
+		if cell.ImagePath != "" {
+			if err := placeCover(pdf, fmt.Sprintf("cell%d", i), cell.ImagePath, chart.Fit, x, y, cellW, coverH); err != nil {
+				return Result{}, err
+			}
+		}
+
+		pdf.SetFont(family, "", 8)
+		pdf.SetXY(x, y+coverH)
+		pdf.CellFormat(cellW, pdfLabelH, tr(cell.Label), "", 0, "C", false, 0, "")
+		if progress != nil {
+			progress(i+1, len(chart.Cells))
+		}
+	}
+
+	if err := pdf.Error(); err != nil {
+		return Result{}, fmt.Errorf("生成 PDF 失败: %w", err)
+	}
+	res := Result{
+		Font:        fontName,
+		Pages:       pdf.PageCount(),
+		PageWidth:   pdfPageW,
+		PageHeight:  pdfPageH,
+		CellWidth:   math.Round(cellW*100) / 100,
+		CoverHeight: math.Round(coverH*100) / 100,
+	}
+	return res, pdf.Output(w)
+}
+
+// drawTitle 在页首居中绘制粗体标题。Helvetica 直接用粗体字形；嵌入的 UTF-8 字体只注册了常规字重，
+// 改用“填充 + 描边”的文字渲染模式加粗，绘制后恢复原来的渲染模式与线宽。
+func drawTitle(pdf *gofpdf.Fpdf, family, title string) {
+	pdf.SetXY(pdfMargin, pdfMargin)
+	if family == LatinFontName {
+		pdf.SetFont(family, "B", 16)
+		pdf.CellFormat(pdfPageW-2*pdfMargin, pdfTitleH-2, title, "", 0, "C", false, 0, "")
+		return
+	}
+
+	pdf.SetFont(family, "", 16)
+	lineWidth := pdf.GetLineWidth()
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.SetLineWidth(pdfTitleStroke)
+	pdf.SetTextRenderingMode(pdfTextFillStroke)
+	pdf.CellFormat(pdfPageW-2*pdfMargin, pdfTitleH-2, title, "", 0, "C", false, 0, "")
+	pdf.SetTextRenderingMode(pdfTextFill)
+	pdf.SetLineWidth(lineWidth)
+}
+
+// setupPDFFont 注册标签字体，返回字体族名、文本转换函数和实际字体名。
+// UTF-8 字体嵌入失败（如 CFF 轮廓的 OTF）时清除错误并回退到 Helvetica。
+func setupPDFFont(pdf *gofpdf.Fpdf, font *Font) (family string, tr func(string) string, name string) {
+	latin := func() (string, func(string) string, string) {
+		return LatinFontName, pdf.UnicodeTranslatorFromDescriptor(""), LatinFontName
+	}
+	if font.IsLatin() {
+		return latin()
+	}
+
+	ok := func() (ok bool) {
+		defer func() {
+			if recover() != nil {
+				ok = false
+			}
+		}()
+		pdf.AddUTF8FontFromBytes(pdfLabelFamily, "", font.Data)
+		return !pdf.Err()
+	}()
+	if !ok {
+		pdf.ClearError()
+		return latin()
+	}
+	return pdfLabelFamily, func(s string) string { return s }, font.Name
+}
+
+// placeCover 解码封面并统一转为 JPEG 嵌入，按 fit 放入格子：contain 等比缩放后居中留白，
+// cover 先按格子比例居中裁切再铺满。统一转码可兼容 gofpdf 不支持的 WebP/BMP 及 16 位 PNG。
+func placeCover(pdf *gofpdf.Fpdf, name, path, fit string, x, y, w, h float64) error {
+	img, err := imaging.Load(path)
+	if err != nil {
+		// 单张封面损坏不影响整体导出，留空格子即可
+		return nil
+	}
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 {
+		return nil
+	}
+
+	crop, dx, dy, dw, dh := fitCover(b, fit, w, h)
+	if crop != b {
+		dst := image.NewRGBA(image.Rect(0, 0, crop.Dx(), crop.Dy()))
+		draw.Draw(dst, dst.Bounds(), img, crop.Min, draw.Src)
+		img = dst
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		return fmt.Errorf("封面转码失败: %w", err)
+	}
+	pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "JPG"}, &buf)
+	pdf.ImageOptions(name, x+dx, y+dy, dw, dh, false, gofpdf.ImageOptions{ImageType: "JPG"}, 0, "")
+	return nil
+}
+
+// fitCover 计算 bounds 大小的图片放入 w×h 区域时使用的源区域，以及在区域内的偏移与绘制尺寸。
+// contain 使用整张图，按较小的缩放比居中留白；cover 从图片中央裁出与区域同比例的部分，铺满整个区域。
+func fitCover(bounds image.Rectangle, fit string, w, h float64) (crop image.Rectangle, dx, dy, dw, dh float64) {
+	imgW, imgH := float64(bounds.Dx()), float64(bounds.Dy())
+	if fit != FitCover {
+		scale := math.Min(w/imgW, h/imgH)
+		dw, dh = imgW*scale, imgH*scale
+		return bounds, (w - dw) / 2, (h - dh) / 2, dw, dh
+	}
+
+	crop = bounds
+	if imgW/imgH > w/h {
+		// 图片更宽：保留全部高度，左右裁切
+		cw := max(int(math.Round(imgH*w/h)), 1)
+		crop.Min.X += (bounds.Dx() - cw) / 2
+		crop.Max.X = crop.Min.X + cw
+	} else {
+		// 图片更高：保留全部宽度，上下裁切
+		ch := max(int(math.Round(imgW*h/w)), 1)
+		crop.Min.Y += (bounds.Dy() - ch) / 2
+		crop.Max.Y = crop.Min.Y + ch
+	}
+	return crop, 0, 0, w, h
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jung-kurt/gofpdf"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// titlePDF 用未压缩的内容流绘制标题，返回 PDF 文本。
+func titlePDF(t *testing.T, font *Font) string {
+	t.Helper()
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetCompression(false)
+	family, tr, _ := setupPDFFont(pdf, font)
+	pdf.AddPage()
+	drawTitle(pdf, family, tr("Title"))
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDrawTitleBold(t *testing.T) {
+	if out := titlePDF(t, LatinFont()); !strings.Contains(out, "/BaseFont /Helvetica-Bold") {
+		t.Error("Latin title not set in Helvetica-Bold")
+	}
+
+	font, err := parseFont("Go-Regular.ttf", goregular.TTF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := titlePDF(t, font)
+	if !strings.Contains(out, "2 Tr") || !strings.Contains(out, "0 Tr") {
+		t.Error("UTF-8 title not drawn with fill+stroke rendering mode")
+	}
+}
+
+func TestPDFWithTitle(t *testing.T) {
+	chart := Chart{Title: "Chart", Columns: 2, Cells: []Cell{{Label: "a"}, {Label: "b"}}}
+	var buf bytes.Buffer
+	res, err := PDF(&buf, chart, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Font != LatinFontName || res.Pages != 1 {
+		t.Fatalf("result = %+v", res)
+	}
+}
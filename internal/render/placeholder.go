@@ -0,0 +1,146 @@
+package render
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// 占位图排版参数，均相对于图片宽度。
+const (
+	placeholderMargin   = 0.08 // 文字区域的左右与上下留白
+	placeholderFontSize = 0.09 // 字号
+	placeholderLeading  = 1.3  // 行高相对字号的倍数
+)
+
+// placeholderText 是占位图的文字颜色。
+var placeholderText = color.RGBA{0xf5, 0xf5, 0xf5, 0xff}
+
+// Placeholder 生成 w×h 的纯色占位图，把 text 自动换行后居中绘制在上面；放不下的部分以 ... 截断。
+// 背景色由文字的哈希决定，同一标题总是得到同一颜色。font 无 CJK 字形时回退到内置的 Go 字体。
+func Placeholder(text string, w, h int, f *Font) (image.Image, error) {
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("无效的占位图尺寸 %dx%d", w, h)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{placeholderColor(text)}, image.Point{}, draw.Src)
+
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return img, nil
+	}
+
+	data := goregular.TTF
+	if !f.IsLatin() {
+		data = f.Data
+	}
+	parsed, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析字体失败: %w", err)
+	}
+	size := max(float64(w)*placeholderFontSize, 8)
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, fmt.Errorf("创建字体失败: %w", err)
+	}
+	defer face.Close()
+
+	margin := int(float64(w) * placeholderMargin)
+	lineH := int(size * placeholderLeading)
+	maxLines := max((h-2*margin)/lineH, 1)
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(placeholderText), Face: face}
+	lines := wrapText(d, text, fixed.I(w-2*margin), maxLines)
+
+	ascent := face.Metrics().Ascent.Ceil()
+	top := (h - len(lines)*lineH) / 2
+	for i, line := range lines {
+		d.Dot = fixed.P((w-d.MeasureString(line).Ceil())/2, top+i*lineH+ascent+(lineH-int(size))/2)
+		d.DrawString(line)
+	}
+	return img, nil
+}
+
+// wrapText 按宽度把文字拆成最多 maxLines 行。优先在空格处断开，CJK 等无空格的文字逐字断开；
+// 超出行数时最后一行以 ... 结尾。
+func wrapText(d *font.Drawer, text string, width fixed.Int26_6, maxLines int) []string {
+	var lines []string
+	rest := []rune(text)
+	for len(rest) > 0 {
+		if len(lines) == maxLines-1 {
+			lines = append(lines, truncateText(d, string(rest), width))
+			break
+		}
+		n := 1
+		for n < len(rest) && d.MeasureString(string(rest[:n+1])) <= width {
+			n++
+		}
+		if n < len(rest) && !unicode.IsSpace(rest[n]) {
+			// 不在单词中间断开，除非整行只有一个单词
+			for i := n - 1; i > 0; i-- {
+				if unicode.IsSpace(rest[i]) {
+					n = i
+					break
+				}
+			}
+		}
+		lines = append(lines, strings.TrimSpace(string(rest[:n])))
+		rest = []rune(strings.TrimLeftFunc(string(rest[n:]), unicode.IsSpace))
+	}
+	return lines
+}
+
+// truncateText 在文字超出宽度时截断并追加 ...。
+func truncateText(d *font.Drawer, text string, width fixed.Int26_6) string {
+	if d.MeasureString(text) <= width {
+		return text
+	}
+	const ellipsis = "..."
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		if s := strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + ellipsis; d.MeasureString(s) <= width {
+			return s
+		}
+	}
+	return ellipsis
+}
+
+// placeholderColor 由文字哈希选取色相，固定较低的饱和度与亮度，保证浅色文字清晰可读。
+func placeholderColor(text string) color.RGBA {
+	sum := fnv.New32a()
+	sum.Write([]byte(text))
+	return hslToRGB(float64(sum.Sum32()%360), 0.35, 0.42)
+}
+
+// hslToRGB 把 HSL（色相 0~360，饱和度与亮度 0~1）转换为 RGB。
+func hslToRGB(hue, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	hp := hue / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+	var r, g, b float64
+	switch int(hp) {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := l - c/2
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 0xff}
+}
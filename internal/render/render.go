@@ -0,0 +1,88 @@
+package render
+
+// render 包负责在服务端把网格图表合成为可下载的文件（PDF 等）。
+
+import "fmt"
+
+// Cell 是图表中的一个格子；ImagePath 为空表示该格未放置封面。
+type Cell struct {
+	Label     string
+	ImagePath string
+}
+
+// 封面放入格子的方式。
+const (
+	FitContain = "contain" // 完整显示封面，比例不符时留白（默认）
+	FitCover   = "cover"   // 铺满封面区域，比例不符时居中裁切
+)
+
+// Chart 描述待渲染的网格图表。CellAspect 是封面区域的宽高比（宽/高），0 表示默认的海报比例 3:4。
+type Chart struct {
+	Title      string
+	Columns    int
+	CellAspect float64
+	Fit        string
+	Cells      []Cell
+}
+
+// Result 描述渲染结果：实际字体与画布尺寸。PDF 的尺寸单位为 mm。
+type Result struct {
+	Font        string  `json:"font"`
+	Pages       int     `json:"pages"`
+	PageWidth   float64 `json:"pageWidth"`
+	PageHeight  float64 `json:"pageHeight"`
+	CellWidth   float64 `json:"cellWidth"`   // 封面区域宽度
+	CoverHeight float64 `json:"coverHeight"` // 封面区域高度，不含标签
+}
+
+// defaultColumns 与前端网格列数保持一致。
+const defaultColumns = 6
+
+// maxColumns 限制列数，避免格子过窄无法辨认。
+const maxColumns = 12
+
+// maxCells 限制单次渲染的格子数（前端网格为 6×10）。每个格子都要解码并重新编码封面，
+// 不设上限时一次请求即可占满 CPU、内存与临时目录。
+const maxCells = 500
+
+// 封面区域宽高比的默认值与允许范围。
+const (
+	defaultCellAspect = 3.0 / 4.0 // 与前端海报比例一致
+	minCellAspect     = 0.25
+	maxCellAspect     = 4.0
+)
+
+// Validate 检查图表参数是否可以渲染，不修改 c。
+func (c Chart) Validate() error {
+	return c.normalize()
+}
+
+// normalize 校正列数等参数。
+func (c *Chart) normalize() error {
+	if len(c.Cells) == 0 {
+		return fmt.Errorf("图表没有任何格子")
+	}
+	if len(c.Cells) > maxCells {
+		return fmt.Errorf("格子数不能超过 %d: %d", maxCells, len(c.Cells))
+	}
+	if c.Columns <= 0 {
+		c.Columns = defaultColumns
+	}
+	if c.Columns > maxColumns {
+		return fmt.Errorf("列数不能超过 %d", maxColumns)
+	}
+	if c.CellAspect == 0 {
+		c.CellAspect = defaultCellAspect
+	}
+	if c.CellAspect < minCellAspect || c.CellAspect > maxCellAspect {
+		return fmt.Errorf("格子宽高比需在 %g~%g 之间: %g", minCellAspect, maxCellAspect, c.CellAspect)
+	}
+	switch c.Fit {
+	case "":
+		c.Fit = FitContain
+	case FitContain, FitCover:
+	default:
+		return fmt.Errorf("无效的填充方式: %q（可选 %s/%s）", c.Fit, FitContain, FitCover)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// handleAniDBSearch 在 AniDB 标题列表中搜索动画（POST /api/anidb/search）。
+// 标题列表不含封面，结果的 cover 为空，前端按需调用 /api/anidb/anime 获取。
+func (h *handler) handleAniDBSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Keyword string `json:"keyword"`
+		Limit   int    `json:"limit"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+
+	cards, err := h.anidb.SearchAnime(req.Keyword, req.Limit)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"results": cards})
+}
+
+// handleAniDBAnime 返回 AniDB 条目详情与封面地址（GET /api/anidb/anime?id=<aid>）。
+// AniDB 限速严格，请求按间隔排队发出，结果缓存一天。
+func (h *handler) handleAniDBAnime(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少或无效的 id 参数"})
+		return
+	}
+	anime, err := h.anidb.GetAnime(id)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, anime)
+}
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 定时归档参数。归档与图表备份共用 backups 目录，放在顶层，不会被当作某个图表的备份目录。
+const (
+	archivePrefix = "covers-"
+	archiveSuffix = ".zip"
+)
+
+// coverArchiver 定期把 covers 目录与全部图表打包为 backups/covers-<时间>.zip，只保留最近 keep 份。
+// 打包前先比对指纹（图表内容与封面的路径、大小、修改时间），与上次相同时不读取封面直接跳过；
+// 每份归档的 zip 注释记录内容哈希，与上一份相同时同样丢弃本次归档（重启后从最新归档读取）。
+type coverArchiver struct {
+	h        *handler
+	dir      string
+	interval time.Duration
+	keep     int
+	lastHash string
+	lastFP   string // 上次打包或确认无变化时的指纹，仅在内存中
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+// newCoverArchiver 创建归档器并在后台启动：立即检查一次，之后每隔 interval 检查。
+func newCoverArchiver(h *handler, dir string, interval time.Duration, keep int) *coverArchiver {
+	a := &coverArchiver{
+		h:        h,
+		dir:      dir,
+		interval: interval,
+		keep:     keep,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	if archives := a.list(); len(archives) > 0 {
+		if r, err := zip.OpenReader(archives[len(archives)-1]); err == nil {
+			a.lastHash = r.Comment
+			r.Close()
+		}
+	}
+	go a.run()
+	return a
+}
+
+// run 按周期归档，直到 close。
+func (a *coverArchiver) run() {
+	defer close(a.stopped)
+	a.archive()
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.archive()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// close 停止定时归档；正在进行的归档会先完成。
+func (a *coverArchiver) close() {
+	select {
+	case <-a.done:
+	default:
+		close(a.done)
+	}
+	<-a.stopped
+}
+
+// list 按时间升序返回已有的归档路径。
+func (a *coverArchiver) list() []string {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		stem, ok := strings.CutPrefix(e.Name(), archivePrefix)
+		if !ok || e.IsDir() || !strings.HasSuffix(stem, archiveSuffix) {
+			continue
+		}
+		if _, err := time.ParseInLocation(backupTimeLayout, strings.TrimSuffix(stem, archiveSuffix), time.Local); err != nil {
+			continue // 非本程序生成的文件不参与清理
+		}
+		out = append(out, filepath.Join(a.dir, e.Name()))
+	}
+	slices.Sort(out) // 时间格式定长，按名称排序即按时间排序
+	return out
+}
+
+// archive 执行一次归档：指纹未变时直接跳过；否则边写临时文件边计算内容哈希，内容与上一份相同时丢弃临时文件。
+func (a *coverArchiver) archive() {
+	start := time.Now()
+	fp, err := a.fingerprint()
+	if err != nil {
+		slog.Warn("计算归档指纹失败", "error", err)
+	} else if fp == a.lastFP {
+		slog.Debug("封面与图表的文件信息自上次归档后没有变化，跳过")
+		return
+	}
+
+	if err := os.MkdirAll(a.dir, 0o755); err != nil {
+		slog.Warn("创建备份目录失败", "dir", a.dir, "error", err)
+		return
+	}
+	tmp, err := os.CreateTemp(a.dir, ".archive-*.tmp")
+	if err != nil {
+		slog.Warn("创建归档临时文件失败", "error", err)
+		return
+	}
+	defer os.Remove(tmp.Name()) // 成功时已改名，删除失败无影响
+
+	files, sum, err := a.write(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		slog.Warn("封面归档失败", "error", err)
+		return
+	}
+	if sum == a.lastHash {
+		a.lastFP = fp
+		slog.Debug("封面与图表自上次归档后没有变化，跳过", "files", files)
+		return
+	}
+
+	name := filepath.Join(a.dir, archivePrefix+start.Format(backupTimeLayout)+archiveSuffix)
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		slog.Warn("保存归档失败", "path", name, "error", err)
+		return
+	}
+	a.lastHash, a.lastFP = sum, fp
+	var size int64
+	if info, err := os.Stat(name); err == nil {
+		size = info.Size()
+	}
+	slog.Info("封面归档完成", "path", name, "files", files, "bytes", size,
+		"duration_ms", time.Since(start).Milliseconds())
+
+	archives := a.list()
+	for _, old := range archives[:max(len(archives)-a.keep, 0)] {
+		if err := os.Remove(old); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("删除旧归档失败", "path", old, "error", err)
+		}
+	}
+}
+
+// eachChart 按固定顺序对每个图表调用 fn，rel 是相对数据目录的 / 分隔路径；图表取写入队列中的最新内容。
+func (a *coverArchiver) eachChart(fn func(rel string, data []byte) error) error {
+	charts, err := a.h.chartNames()
+	if err != nil {
+		return err
+	}
+	for _, chart := range charts {
+		path, err := a.h.chartPath(chart)
+		if err != nil {
+			continue
+		}
+		data, err := a.h.readChart(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		rel, _ := filepath.Rel(filepath.Dir(a.h.chartsDir), path)
+		if err := fn(filepath.ToSlash(rel), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkCovers 按固定顺序对 covers 下每个普通文件调用 fn，rel 是相对数据目录的 / 分隔路径。
+// 以点开头的文件与目录（回收站、下载临时文件）被跳过；covers 目录不存在时不报错。
+func (a *coverArchiver) walkCovers(fn func(path, rel string, d fs.DirEntry) error) error {
+	root := a.h.coversDir
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.Dir(root), path)
+		if err != nil {
+			return err
+		}
+		return fn(path, filepath.ToSlash(rel), d)
+	})
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// fingerprint 计算图表内容与封面路径、大小、修改时间的摘要，不读取封面内容。
+func (a *coverArchiver) fingerprint() (string, error) {
+	sum := sha256.New()
+	err := a.eachChart(func(rel string, data []byte) error {
+		fmt.Fprintf(sum, "%s\x00%x\x00", rel, sha256.Sum256(data))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	err = a.walkCovers(func(_, rel string, d fs.DirEntry) error {
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil // 遍历期间被删除
+			}
+			return err
+		}
+		fmt.Fprintf(sum, "%s\x00%d\x00%d\x00", rel, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// write 把图表与封面写入 zip，返回文件数与内容哈希（文件路径与内容按固定顺序计入）。
+func (a *coverArchiver) write(w io.Writer) (int, string, error) {
+	zw := zip.NewWriter(w)
+	sum := sha256.New()
+	files := 0
+
+	err := a.eachChart(func(rel string, data []byte) error {
+		if err := addArchiveEntry(zw, sum, rel, zip.Deflate, bytes.NewReader(data)); err != nil {
+			return err
+		}
+		files++
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	err = a.walkCovers(func(path, rel string, d fs.DirEntry) error {
+		f, err := os.Open(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil // 遍历期间被删除
+			}
+			return err
+		}
+		defer f.Close()
+		// 图片本身已压缩，直接存储；元数据等文本文件压缩
+		method := zip.Store
+		if !isImageName(d.Name()) {
+			method = zip.Deflate
+		}
+		if err := addArchiveEntry(zw, sum, rel, method, f); err != nil {
+			return err
+		}
+		files++
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	hash := hex.EncodeToString(sum.Sum(nil))
+	if err := zw.SetComment(hash); err != nil {
+		return 0, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, "", err
+	}
+	return files, hash, nil
+}
+
+// addArchiveEntry 向 zip 写入一个文件，同时把路径与内容计入哈希。
+func addArchiveEntry(zw *zip.Writer, sum hash.Hash, name string, method uint16, r io.Reader) error {
+	fmt.Fprintf(sum, "%s\x00", name)
+	dst, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.MultiWriter(dst, sum), r)
+	return err
+}
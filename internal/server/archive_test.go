@@ -0,0 +1,44 @@
+package server
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestArchiveSkipsWhenFingerprintUnchanged(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	path := writeTestCover(t, h.coversDir, "a.png")
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	a := &coverArchiver{h: h, dir: t.TempDir(), keep: 3}
+
+	a.archive()
+	first := a.lastHash
+	if first == "" || len(a.list()) != 1 {
+		t.Fatalf("first archive: hash %q, archives %v", first, a.list())
+	}
+
+	// 内容变化但大小与修改时间不变：指纹相同，不重新打包
+	if err := os.WriteFile(path, []byte("IMG"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	a.archive()
+	if a.lastHash != first {
+		t.Fatal("archive re-zipped although size and mtime were unchanged")
+	}
+
+	// 修改时间变化：重新打包并得到新的内容哈希
+	if err := os.Chtimes(path, mtime.Add(time.Minute), mtime.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	a.archive()
+	if a.lastHash == first {
+		t.Fatal("archive skipped although mtime changed")
+	}
+}
@@ -0,0 +1,324 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 状态备份参数。
+const (
+	backupsDirName      = "backups"
+	backupPolicyFile    = "policy.json"
+	backupTimeLayout    = "20060102-150405"
+	backupMinInterval   = 5 * time.Minute // 同一图表两次备份的最小间隔，避免拖拽编辑产生大量备份
+	backupSweepInterval = time.Hour       // 定期按保留策略清理的间隔
+	backupMaxCountLimit = 1000
+)
+
+// backupPolicy 是状态备份的保留策略，0 表示该项不限制。
+type backupPolicy struct {
+	MaxCount   int `json:"maxCount"`   // 每个图表最多保留的备份数
+	MaxAgeDays int `json:"maxAgeDays"` // 备份最长保留天数
+}
+
+// defaultBackupPolicy 是未配置时的保留策略。
+var defaultBackupPolicy = backupPolicy{MaxCount: 20, MaxAgeDays: 30}
+
+// validate 校验策略取值。
+func (p backupPolicy) validate() error {
+	if p.MaxCount < 0 || p.MaxCount > backupMaxCountLimit {
+		return fmt.Errorf("maxCount 须在 0-%d 之间", backupMaxCountLimit)
+	}
+	if p.MaxAgeDays < 0 {
+		return errors.New("maxAgeDays 不能为负数")
+	}
+	return nil
+}
+
+// backupUsage 汇总备份占用的磁盘空间。
+type backupUsage struct {
+	Files  int                       `json:"files"`
+	Bytes  int64                     `json:"bytes"`
+	Charts map[string]backupChartUse `json:"charts"`
+}
+
+// backupChartUse 是单个图表的备份占用。
+type backupChartUse struct {
+	Count  int    `json:"count"`
+	Bytes  int64  `json:"bytes"`
+	Latest string `json:"latest,omitempty"`
+}
+
+// backupStore 在图表落盘前保存带时间戳的旧版本，按 backups/<图表名>/<时间>.json 存放，
+// 并按保留策略在每次保存后与定期清理。
+type backupStore struct {
+	dir     string
+	mu      sync.Mutex
+	policy  backupPolicy
+	last    map[string]time.Time // 图表名 -> 最近一次备份时间
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newBackupStore 读取已保存的策略（缺失时使用默认值）并启动定期清理。
+func newBackupStore(dir string) *backupStore {
+	s := &backupStore{
+		dir:     dir,
+		policy:  defaultBackupPolicy,
+		last:    make(map[string]time.Time),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, backupPolicyFile)); err == nil {
+		var p backupPolicy
+		err := json.Unmarshal(data, &p)
+		if err == nil {
+			err = p.validate()
+		}
+		if err != nil {
+			slog.Warn("备份策略文件无效，使用默认策略", "error", err)
+		} else {
+			s.policy = p
+		}
+	}
+	go s.run()
+	return s
+}
+
+// backup 在 path 被覆盖前为图表保存一份旧版本，并按策略清理。
+// 距上次备份不足 backupMinInterval 时跳过；调用方需持有 stateMu，保证读到的是落盘前的内容。
+func (s *backupStore) backup(chart, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.last[chart]) < backupMinInterval {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("读取待备份状态失败", "path", path, "error", err)
+		}
+		return
+	}
+
+	dir := filepath.Join(s.dir, chart)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		slog.Warn("创建备份目录失败", "dir", dir, "error", err)
+		return
+	}
+	name := filepath.Join(dir, now.Format(backupTimeLayout)+".json")
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		slog.Warn("写入状态备份失败", "path", name, "error", err)
+		return
+	}
+	s.last[chart] = now
+	s.pruneLocked(chart, now)
+}
+
+// backupFile 是目录中的一份备份。
+type backupFile struct {
+	path string
+	name string
+	size int64
+	time time.Time
+}
+
+// listLocked 按时间升序列出图表的备份，调用方需持有 s.mu。
+func (s *backupStore) listLocked(chart string) []backupFile {
+	dir := filepath.Join(s.dir, chart)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []backupFile
+	for _, e := range entries {
+		stem, ok := strings.CutSuffix(e.Name(), ".json")
+		if e.IsDir() || !ok {
+			continue
+		}
+		t, err := time.ParseInLocation(backupTimeLayout, stem, time.Local)
+		if err != nil {
+			continue // 非本程序生成的文件不参与清理
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFile{path: filepath.Join(dir, e.Name()), name: e.Name(), size: info.Size(), time: t})
+	}
+	slices.SortFunc(files, func(a, b backupFile) int { return a.time.Compare(b.time) })
+	return files
+}
+
+// pruneLocked 删除超出数量或年龄上限的备份（从最旧的开始），调用方需持有 s.mu。
+func (s *backupStore) pruneLocked(chart string, now time.Time) {
+	files := s.listLocked(chart)
+	remove := 0
+	if s.policy.MaxCount > 0 && len(files) > s.policy.MaxCount {
+		remove = len(files) - s.policy.MaxCount
+	}
+	if s.policy.MaxAgeDays > 0 {
+		cutoff := now.AddDate(0, 0, -s.policy.MaxAgeDays)
+		for remove < len(files) && files[remove].time.Before(cutoff) {
+			remove++
+		}
+	}
+	for _, f := range files[:remove] {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("删除过期备份失败", "path", f.path, "error", err)
+		}
+	}
+}
+
+// chartsLocked 列出有备份的图表名，调用方需持有 s.mu。
+func (s *backupStore) chartsLocked() []string {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil
+	}
+	var charts []string
+	for _, e := range entries {
+		if e.IsDir() {
+			charts = append(charts, e.Name())
+		}
+	}
+	return charts
+}
+
+// sweep 按当前策略清理所有图表的备份。
+func (s *backupStore) sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, chart := range s.chartsLocked() {
+		s.pruneLocked(chart, now)
+	}
+}
+
+// usage 统计备份数量与占用空间。
+func (s *backupStore) usage() backupUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := backupUsage{Charts: make(map[string]backupChartUse)}
+	for _, chart := range s.chartsLocked() {
+		files := s.listLocked(chart)
+		if len(files) == 0 {
+			continue
+		}
+		var cu backupChartUse
+		for _, f := range files {
+			cu.Count++
+			cu.Bytes += f.size
+		}
+		cu.Latest = files[len(files)-1].time.Format(time.RFC3339)
+		u.Files += cu.Count
+		u.Bytes += cu.Bytes
+		u.Charts[chart] = cu
+	}
+	return u
+}
+
+// getPolicy 返回当前保留策略。
+func (s *backupStore) getPolicy() backupPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policy
+}
+
+// setPolicy 持久化新策略并立即按其清理。
+func (s *backupStore) setPolicy(p backupPolicy) error {
+	if err := p.validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, backupPolicyFile), append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.policy = p
+	s.mu.Unlock()
+	s.sweep()
+	return nil
+}
+
+// run 定期清理过期备份，直到 close。
+func (s *backupStore) run() {
+	defer close(s.stopped)
+	s.sweep()
+	ticker := time.NewTicker(backupSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// close 停止定期清理。
+func (s *backupStore) close() {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	<-s.stopped
+}
+
+// chartNameOf 将图表文件路径还原为图表名，默认状态文件对应 default。
+func (h *handler) chartNameOf(path string) string {
+	if path == h.stateFile {
+		return defaultChartName
+	}
+	return strings.TrimSuffix(filepath.Base(path), ".json")
+}
+
+// handleBackupPolicy 查看或修改状态备份的保留策略（GET/POST /api/state/backup-policy），
+// 响应附带当前备份的磁盘占用。
+func (h *handler) handleBackupPolicy(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var p backupPolicy
+		if err := readJSON(r, &p); err != nil {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+			return
+		}
+		if err := p.validate(); err != nil {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := h.backups.setPolicy(p); err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"policy": h.backups.getPolicy(),
+		"usage":  h.backups.usage(),
+	})
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// writeBackups 在 dir/chart 下按时间写入备份文件，返回文件名。
+func writeBackups(t *testing.T, dir, chart string, times ...time.Time) []string {
+	t.Helper()
+	chartDir := filepath.Join(dir, chart)
+	if err := os.MkdirAll(chartDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, ts := range times {
+		name := ts.Format(backupTimeLayout) + ".json"
+		if err := os.WriteFile(filepath.Join(chartDir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// backupNames 返回 dir/chart 下的全部文件名（含非备份文件），按名称排序。
+func backupNames(t *testing.T, dir, chart string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, chart))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestBackupPrune(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	days := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	// 非本程序生成的文件名不参与清理
+	foreign := []string{"notes.json", "2026-01-01.json", "20200101-000000.txt"}
+
+	tests := []struct {
+		name   string
+		policy backupPolicy
+		times  []time.Time
+		keep   []int // 保留的 times 下标
+	}{
+		{"by count", backupPolicy{MaxCount: 2}, []time.Time{days(4), days(3), days(2), days(1)}, []int{2, 3}},
+		{"by age", backupPolicy{MaxAgeDays: 7}, []time.Time{days(10), days(8), days(6), days(0)}, []int{2, 3}},
+		{"count and age", backupPolicy{MaxCount: 3, MaxAgeDays: 5}, []time.Time{days(9), days(4), days(3), days(2), days(1)}, []int{2, 3, 4}},
+		{"unlimited", backupPolicy{}, []time.Time{days(400), days(1)}, []int{0, 1}},
+		{"under limits", backupPolicy{MaxCount: 5, MaxAgeDays: 30}, []time.Time{days(2), days(1)}, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			names := writeBackups(t, dir, "c", tt.times...)
+			for _, name := range foreign {
+				if err := os.WriteFile(filepath.Join(dir, "c", name), []byte("x"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			s := &backupStore{dir: dir, policy: tt.policy}
+			s.pruneLocked("c", now)
+
+			want := slices.Clone(foreign)
+			for _, i := range tt.keep {
+				want = append(want, names[i])
+			}
+			slices.Sort(want)
+			if got := backupNames(t, dir, "c"); !slices.Equal(got, want) {
+				t.Errorf("files = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBackupPolicyPersisted(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().Truncate(time.Second)
+	names := writeBackups(t, dir, "c", now.Add(-3*time.Hour), now.Add(-2*time.Hour), now.Add(-time.Hour))
+
+	s := newBackupStore(dir)
+	if got := s.getPolicy(); got != defaultBackupPolicy {
+		t.Fatalf("initial policy = %+v, want default", got)
+	}
+	if err := s.setPolicy(backupPolicy{MaxCount: -1}); err == nil {
+		t.Error("setPolicy accepted a negative maxCount")
+	}
+	p := backupPolicy{MaxCount: 1, MaxAgeDays: 3}
+	if err := s.setPolicy(p); err != nil {
+		t.Fatal(err)
+	}
+	s.close()
+
+	// setPolicy 立即按新策略清理
+	if got := backupNames(t, dir, "c"); !slices.Equal(got, names[2:]) {
+		t.Errorf("files after setPolicy = %v, want %v", got, names[2:])
+	}
+
+	s = newBackupStore(dir)
+	defer s.close()
+	if got := s.getPolicy(); got != p {
+		t.Errorf("reloaded policy = %+v, want %+v", got, p)
+	}
+}
+
+func TestBackupPolicyInvalidFileFallsBack(t *testing.T) {
+	dir := t.TempDir()
+	for _, content := range []string{"not json", `{"maxCount":-5}`} {
+		if err := os.WriteFile(filepath.Join(dir, backupPolicyFile), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		s := newBackupStore(dir)
+		if got := s.getPolicy(); got != defaultBackupPolicy {
+			t.Errorf("policy from %q = %+v, want default", content, got)
+		}
+		s.close()
+	}
+}
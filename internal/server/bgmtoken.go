@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// bgmTokenFileName 保存 Bangumi Access Token，与 state.json 同目录。
+// 不写入 state.json，避免经 GET /api/state 或协同编辑泄露给其他客户端。
+const bgmTokenFileName = "bgm-token"
+
+// loadBangumiToken 读取已保存的 Token；文件缺失时返回空串（匿名访问）。
+func loadBangumiToken(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("读取 Bangumi Token 失败", "path", path, "error", err)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// saveBangumiToken 持久化 Token（仅所有者可读），空值删除文件。
+func saveBangumiToken(path, token string) error {
+	if token == "" {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return os.WriteFile(path, []byte(token+"\n"), 0o600)
+}
+
+// handleBangumiToken 查看或设置 Bangumi Access Token（GET/POST /api/bangumi/token）。
+// POST 请求体为 {"token": "..."}，先经 /v0/me 校验再生效并保存；空 token 清除并恢复匿名访问。
+// 响应不回显 Token 本身。
+func (h *handler) handleBangumiToken(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.writeJSON(w, http.StatusOK, map[string]any{"enabled": h.bgm.HasToken()})
+	case http.MethodPost:
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+			return
+		}
+		token := strings.TrimSpace(req.Token)
+
+		var user *api.BangumiUser
+		if token != "" {
+			var err error
+			user, err = h.bgm.VerifyToken(token)
+			if err != nil {
+				status := http.StatusBadGateway
+				if api.IsBadRequest(err) {
+					status = http.StatusBadRequest
+				}
+				h.writeJSON(w, status, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+		if err := saveBangumiToken(h.bgmTokenFile, token); err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "保存 Token 失败: " + err.Error()})
+			return
+		}
+		h.bgm.SetToken(token)
+
+		resp := map[string]any{"enabled": token != ""}
+		if user != nil {
+			resp["user"] = user
+		}
+		h.writeJSON(w, http.StatusOK, resp)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
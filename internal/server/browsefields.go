@@ -0,0 +1,60 @@
+package server
+
+import (
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// 浏览响应的字段集合。
+const (
+	browseFieldsFull    = "full"    // 完整字段（默认）
+	browseFieldsMinimal = "minimal" // 只保留 id、name、cover，供紧凑列表使用
+)
+
+// minimalBrowseResult 是 fields=minimal 时的单条结果。
+type minimalBrowseResult struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Cover string `json:"cover"`
+}
+
+// minimalSeasonGroup 是 fields=minimal 时的季度分组。
+type minimalSeasonGroup struct {
+	Season string                `json:"season"`
+	Year   int                   `json:"year"`
+	Items  []minimalBrowseResult `json:"items"`
+}
+
+// minimalBrowseResponse 是 fields=minimal 时的浏览响应，分页字段与完整响应相同。
+type minimalBrowseResponse struct {
+	Results []minimalBrowseResult `json:"results"`
+	Total   int                   `json:"total"`
+	Offset  int                   `json:"offset"`
+	Limit   int                   `json:"limit"`
+	Seasons []minimalSeasonGroup  `json:"seasons,omitempty"`
+}
+
+// minimalBrowseResults 把浏览结果裁剪为精简结构。
+func minimalBrowseResults(results []api.BrowseResult) []minimalBrowseResult {
+	out := make([]minimalBrowseResult, len(results))
+	for i, it := range results {
+		out[i] = minimalBrowseResult{ID: it.ID, Name: it.Name, Cover: it.Cover}
+	}
+	return out
+}
+
+// trimBrowseResponse 按 fields 构造响应：minimal 时另建精简结构，使省略的字段不出现在 JSON 中。
+func trimBrowseResponse(resp *api.BrowseResponse, fields string) any {
+	if fields != browseFieldsMinimal {
+		return resp
+	}
+	out := minimalBrowseResponse{
+		Results: minimalBrowseResults(resp.Results),
+		Total:   resp.Total,
+		Offset:  resp.Offset,
+		Limit:   resp.Limit,
+	}
+	for _, g := range resp.Seasons {
+		out.Seasons = append(out.Seasons, minimalSeasonGroup{Season: g.Season, Year: g.Year, Items: minimalBrowseResults(g.Items)})
+	}
+	return out
+}
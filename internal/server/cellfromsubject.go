@@ -0,0 +1,151 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// subjectCell 是 /api/cell/from-subject 返回的可直接放入图表的格子：
+// Cell 是写入图表 cells 的封面地址，SubjectID 写入 subjectIDs。
+type subjectCell struct {
+	Label     string  `json:"label"`
+	Cell      string  `json:"cell"`
+	Filename  string  `json:"filename"`
+	Source    string  `json:"source"`
+	SubjectID string  `json:"subjectID"`
+	Title     string  `json:"title"`
+	Name      string  `json:"name"`
+	NameCN    string  `json:"name_cn"`
+	Score     float64 `json:"score"`
+	CoverURL  string  `json:"coverURL"` // 上游封面原地址
+}
+
+// 格子生成失败的阶段，写入错误响应的 stage 字段。
+const (
+	cellStageSubject  = "subject"  // 获取条目详情
+	cellStageCover    = "cover"    // 条目没有封面
+	cellStageDownload = "download" // 下载封面
+)
+
+// fetchSubjectCard 按数据源获取条目，统一为卡片格式。
+func (h *handler) fetchSubjectCard(source, id string) (*api.Card, error) {
+	switch source {
+	case api.SourceVNDB:
+		vn, err := h.vndb.GetVN(id)
+		if err != nil {
+			return nil, err
+		}
+		return &api.Card{ID: vn.ID, Name: vn.Title, NameCN: vn.Alttitle, Cover: vn.Image.BestURL(), Score: vn.Rating / 10, Source: source}, nil
+	case api.SourceAniDB:
+		aid, _ := strconv.Atoi(id) // 非数字按 0 处理，由 GetAnime 报告无效 ID
+		anime, err := h.anidb.GetAnime(aid)
+		if err != nil {
+			return nil, err
+		}
+		return &anime.Card, nil
+	default:
+		sid, _ := strconv.Atoi(id)
+		s, err := h.bgm.GetSubject(sid)
+		if err != nil {
+			return nil, err
+		}
+		return &api.Card{ID: strconv.Itoa(s.ID), Name: s.Name, NameCN: s.NameCN, Cover: s.Cover, Score: s.Score, TypeLabel: s.TypeLabel, Source: source}, nil
+	}
+}
+
+// downloadSubjectCover 用对应数据源的客户端下载封面。
+func (h *handler) downloadSubjectCover(card *api.Card, title string) (*api.DownloadResult, error) {
+	filename := api.CoverBaseName(title, card.ID)
+	info := api.CoverInfo{ID: card.ID, Title: title}
+	switch card.Source {
+	case api.SourceVNDB:
+		return h.vndb.DownloadCover(card.Cover, filename, info)
+	case api.SourceAniDB:
+		return h.anidb.DownloadCover(card.Cover, filename, info)
+	}
+	return h.bgm.DownloadCover(card.Cover, filename, info)
+}
+
+// handleCellFromSubject 把一个条目直接转为图表格子（POST /api/cell/from-subject）：
+// 获取条目详情、下载封面，返回可直接放入图表的格子，省去前端"查找 → 下载 → 放置"的多次往返。
+// 失败时响应的 stage 指明出错的步骤（subject/cover/download），状态码与对应单步接口一致。
+func (h *handler) handleCellFromSubject(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Source string `json:"source"` // bgm（默认）、vndb 或 anidb
+		ID     any    `json:"id"`     // 数字或字符串
+		Label  string `json:"label"`  // 可选，默认取条目标题
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	source := cmp.Or(req.Source, api.SourceBangumi)
+	if !slices.ContainsFunc(knownSources, func(s sourceInfo) bool { return s.ID == source }) {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "未知的数据源: " + source})
+		return
+	}
+	id := subjectIDString(req.ID)
+	if id == "" {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少或无效的 id"})
+		return
+	}
+	if !h.checkSource(w, source) {
+		return
+	}
+
+	card, err := h.fetchSubjectCard(source, id)
+	if err != nil {
+		code, msg := apiErrorStatus(w, err)
+		h.writeJSON(w, code, map[string]string{"error": "获取条目失败: " + msg, "stage": cellStageSubject})
+		return
+	}
+	if card.Cover == "" {
+		h.writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "条目没有封面", "stage": cellStageCover})
+		return
+	}
+
+	title := cmp.Or(card.NameCN, card.Name)
+	result, err := h.downloadSubjectCover(card, title)
+	if err != nil {
+		code, msg := apiErrorStatus(w, err)
+		h.writeJSON(w, code, map[string]string{"error": "下载封面失败: " + msg, "stage": cellStageDownload})
+		return
+	}
+	h.coverDownloaded(result)
+
+	h.writeJSON(w, http.StatusOK, subjectCell{
+		Label:     cmp.Or(strings.TrimSpace(req.Label), title),
+		Cell:      result.Path,
+		Filename:  result.Filename,
+		Source:    source,
+		SubjectID: card.ID,
+		Title:     title,
+		Name:      card.Name,
+		NameCN:    card.NameCN,
+		Score:     card.Score,
+		CoverURL:  card.Cover,
+	})
+}
+
+// subjectIDString 把 JSON 中的数字或字符串 ID 转为字符串，其他类型返回空串。
+func subjectIDString(v any) string {
+	switch id := v.(type) {
+	case string:
+		return strings.TrimSpace(id)
+	case float64:
+		if id > 0 && id == float64(int64(id)) {
+			return strconv.FormatInt(int64(id), 10)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/config"
+)
+
+// defaultChartName 是 state.json 对应的默认图表名。
+const defaultChartName = "default"
+
+// chartNamePattern 限制图表名只含字母、数字、下划线和连字符，避免路径穿越。
+var chartNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,64}$`)
+
+// chartPath 将图表名解析为状态文件路径：空名或 default 对应 state.json，其余位于 charts/ 目录。
+func (h *handler) chartPath(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == defaultChartName {
+		return h.stateFile, nil
+	}
+	if !chartNamePattern.MatchString(name) {
+		return "", fmt.Errorf("无效的图表名: %s", name)
+	}
+	return filepath.Join(h.chartsDir, name+".json"), nil
+}
+
+// chartDisplayName 返回请求中图表名的规范形式，空名视为 default。
+func chartDisplayName(name string) string {
+	if name = strings.TrimSpace(name); name == "" {
+		return defaultChartName
+	}
+	return name
+}
+
+// marshalState 按 -state-indent 编码图表状态，无论缩进方式如何都以换行结尾。
+func (h *handler) marshalState(v any) ([]byte, error) {
+	var (
+		b   []byte
+		err error
+	)
+	switch h.cfg.StateIndent {
+	case config.StateIndentNone:
+		b, err = json.Marshal(v)
+	case config.StateIndentTab:
+		b, err = json.MarshalIndent(v, "", "\t")
+	default:
+		b, err = json.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// writeChartFile 写入图表文件，按需创建 charts 目录。
+func writeChartFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// chartNames 返回所有图表名，default 固定排在首位。
+func (h *handler) chartNames() ([]string, error) {
+	names := []string{defaultChartName}
+	entries, err := os.ReadDir(h.chartsDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return names, nil
+		}
+		return nil, err
+	}
+
+	var named []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), ".json")
+		if chartNamePattern.MatchString(name) && name != defaultChartName {
+			named = append(named, name)
+		}
+	}
+	sort.Strings(named)
+	return append(names, named...), nil
+}
+
+// handleCharts 返回图表名列表（GET /api/charts）。
+func (h *handler) handleCharts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	names, err := h.chartNames()
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"charts": names})
+}
+
+// handleCloneChart 复制已有图表为新图表（POST /api/charts/clone）。
+// 封面在图表间共享，只复制状态文件；目标已存在时需显式 overwrite。
+func (h *handler) handleCloneChart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	if strings.TrimSpace(req.To) == "" {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少目标图表名"})
+		return
+	}
+
+	src, err := h.chartPath(req.From)
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	dst, err := h.chartPath(req.To)
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if src == dst {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "源图表与目标图表相同"})
+		return
+	}
+
+	// 先落盘排队中的保存，避免复制到旧内容或随后被队列覆盖
+	h.editMu.Lock()
+	defer h.editMu.Unlock()
+	h.states.Flush()
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "源图表不存在"})
+			return
+		}
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := os.Stat(dst); err == nil && !req.Overwrite {
+		h.writeJSON(w, http.StatusConflict, map[string]string{"error": "目标图表已存在"})
+		return
+	}
+	if err := writeChartFile(dst, data); err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.publishSnapshot(dst, data)
+	h.events.publish(eventStateSaved, map[string]string{"chart": chartDisplayName(req.To)})
+	h.writeJSON(w, http.StatusOK, map[string]any{"ok": true, "chart": strings.TrimSpace(req.To)})
+}
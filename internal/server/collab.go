@@ -0,0 +1,311 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// 协同编辑连接参数。
+const (
+	collabSendBuf      = 32               // 每个连接的待发送消息缓冲
+	collabWriteTimeout = 10 * time.Second // 单条消息写出超时
+	collabReadLimit    = 1 << 20          // 单条补丁最大字节数
+)
+
+// collabMessage 是 /ws/state 上收发的消息。
+// 客户端发送 {"patch": {...}, "seq": n}；服务端回复 ack（携带 seq）或 error，
+// 并向同一图表的其他连接广播 patch（from 为发送方连接 ID）。连接建立时先下发 snapshot。
+type collabMessage struct {
+	Type  string          `json:"type,omitempty"`
+	ID    uint64          `json:"id,omitempty"`
+	From  uint64          `json:"from,omitempty"`
+	Seq   uint64          `json:"seq,omitempty"`
+	State json.RawMessage `json:"state,omitempty"`
+	Patch json.RawMessage `json:"patch,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+// collabClient 是一个协同编辑连接；消息经 send 队列由单独的协程写出。
+type collabClient struct {
+	id   uint64
+	path string
+	send chan []byte
+}
+
+// collabHub 按图表文件管理协同编辑连接。
+// 广播从不阻塞：连接发送队列写满时直接断开，客户端重连后会收到最新快照。
+type collabHub struct {
+	mu     sync.Mutex
+	nextID uint64
+	rooms  map[string]map[*collabClient]struct{}
+	closed bool
+}
+
+// newCollabHub 创建协同编辑中心。
+func newCollabHub() *collabHub {
+	return &collabHub{rooms: make(map[string]map[*collabClient]struct{})}
+}
+
+// join 为图表注册新连接；中心已关闭时返回 nil。
+func (hub *collabHub) join(path string) *collabClient {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return nil
+	}
+	hub.nextID++
+	c := &collabClient{id: hub.nextID, path: path, send: make(chan []byte, collabSendBuf)}
+	if hub.rooms[path] == nil {
+		hub.rooms[path] = make(map[*collabClient]struct{})
+	}
+	hub.rooms[path][c] = struct{}{}
+	return c
+}
+
+// leave 注销连接并关闭其发送队列；已被移除的连接忽略。
+func (hub *collabHub) leave(c *collabClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.removeLocked(c)
+}
+
+// removeLocked 移除连接并关闭其发送队列，调用方需持有 hub.mu。
+func (hub *collabHub) removeLocked(c *collabClient) {
+	room := hub.rooms[c.path]
+	if _, ok := room[c]; !ok {
+		return
+	}
+	delete(room, c)
+	if len(room) == 0 {
+		delete(hub.rooms, c.path)
+	}
+	close(c.send)
+}
+
+// broadcast 向图表的所有连接（except 除外）发送消息。
+func (hub *collabHub) broadcast(path string, msg []byte, except *collabClient) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for c := range hub.rooms[path] {
+		if c == except {
+			continue
+		}
+		select {
+		case c.send <- msg:
+		default:
+			hub.removeLocked(c)
+		}
+	}
+}
+
+// send 向单个连接排入消息；队列已满时断开该连接。
+func (hub *collabHub) send(c *collabClient, msg []byte) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.rooms[c.path][c]; !ok {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		hub.removeLocked(c)
+	}
+}
+
+// close 断开全部连接并拒绝新连接。
+func (hub *collabHub) close() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.closed = true
+	for _, room := range hub.rooms {
+		for c := range room {
+			hub.removeLocked(c)
+		}
+	}
+}
+
+// mergePatch 按 RFC 7396 将补丁合并到目标值：对象逐键递归合并，null 删除键，其余类型整体替换。
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any, len(p))
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+// applyStatePatch 在编辑锁内读取图表、合并补丁、排队写入并广播给协同编辑连接（from 除外），
+// 返回合并后的完整状态。广播在锁内进行，保证各连接收到补丁的顺序与应用顺序一致。
+func (h *handler) applyStatePatch(path string, patch json.RawMessage, from *collabClient) ([]byte, error) {
+	var p map[string]any
+	if err := json.Unmarshal(patch, &p); err != nil || p == nil {
+		return nil, errInvalidPatch
+	}
+
+	h.editMu.Lock()
+	defer h.editMu.Unlock()
+
+	var current any = map[string]any{}
+	data, err := h.readChart(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &current); err != nil {
+			return nil, err
+		}
+	}
+
+	next := mergePatch(current, p)
+	if err := validateStateMeta(next); err != nil {
+		return nil, err
+	}
+	merged, err := h.marshalState(next)
+	if err != nil {
+		return nil, err
+	}
+	h.states.Enqueue(path, merged)
+
+	msg := collabMessage{Type: "patch", Patch: patch}
+	if from != nil {
+		msg.From = from.id
+	}
+	if b, err := json.Marshal(msg); err == nil {
+		h.collab.broadcast(path, b, from)
+	}
+	return merged, nil
+}
+
+// errInvalidPatch 表示补丁不是 JSON 对象。
+var errInvalidPatch = errors.New("补丁必须是 JSON 对象")
+
+// publishSnapshot 把整体替换后的图表内容作为快照广播给协同编辑连接，调用方需持有 editMu。
+func (h *handler) publishSnapshot(path string, state []byte) {
+	if b, err := json.Marshal(collabMessage{Type: "snapshot", State: state}); err == nil {
+		h.collab.broadcast(path, b, nil)
+	}
+}
+
+// patchState 以 JSON Merge Patch 增量更新图表（PATCH /api/state）。
+func (h *handler) patchState(w http.ResponseWriter, r *http.Request, path string) {
+	var patch json.RawMessage
+	if err := readJSON(r, &patch); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "请求体不是合法 JSON"})
+		return
+	}
+
+	merged, err := h.applyStatePatch(path, patch, nil)
+	if err != nil {
+		if errors.Is(err, errInvalidPatch) || errors.Is(err, errInvalidStateMeta) {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	h.events.publish(eventStateSaved, map[string]string{"chart": chartDisplayName(r.URL.Query().Get("chart"))})
+	h.writeJSONRaw(w, http.StatusOK, merged)
+}
+
+// handleStateWS 提供图表协同编辑的 WebSocket 连接（/ws/state?chart=xxx）。
+// 客户端发送 JSON Merge Patch，服务端在锁内合并落盘后转发给同一图表的其他连接。
+func (h *handler) handleStateWS(w http.ResponseWriter, r *http.Request) {
+	chart := r.URL.Query().Get("chart")
+	path, err := h.chartPath(chart)
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(collabReadLimit)
+
+	// 在编辑锁内注册并排入快照，保证快照先于后续补丁且不遗漏并发修改
+	h.editMu.Lock()
+	client := h.collab.join(path)
+	state, err := h.readChart(path)
+	if client != nil && (err == nil || errors.Is(err, os.ErrNotExist)) {
+		if len(state) == 0 {
+			state = []byte("{}")
+		}
+		h.sendCollab(client, collabMessage{Type: "snapshot", ID: client.id, State: state})
+	}
+	h.editMu.Unlock()
+	if client == nil {
+		conn.Close(websocket.StatusGoingAway, "服务正在关闭")
+		return
+	}
+	defer h.collab.leave(client)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		conn.Close(websocket.StatusInternalError, "读取状态失败")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	go h.writeCollab(ctx, cancel, conn, client)
+
+	chart = chartDisplayName(chart)
+	for {
+		var msg collabMessage
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, &msg); err != nil || len(msg.Patch) == 0 {
+			h.sendCollab(client, collabMessage{Type: "error", Seq: msg.Seq, Error: "消息格式应为 {\"patch\": {...}}"})
+			continue
+		}
+		if _, err := h.applyStatePatch(path, msg.Patch, client); err != nil {
+			h.sendCollab(client, collabMessage{Type: "error", Seq: msg.Seq, Error: err.Error()})
+			continue
+		}
+		h.sendCollab(client, collabMessage{Type: "ack", Seq: msg.Seq})
+		h.events.publish(eventStateSaved, map[string]string{"chart": chart})
+	}
+}
+
+// sendCollab 向单个连接排入消息。
+func (h *handler) sendCollab(c *collabClient, msg collabMessage) {
+	if b, err := json.Marshal(msg); err == nil {
+		h.collab.send(c, b)
+	}
+}
+
+// writeCollab 把发送队列中的消息写到连接；队列关闭（慢连接被移除或服务退出）时关闭连接。
+func (h *handler) writeCollab(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, c *collabClient) {
+	defer cancel()
+	for b := range c.send {
+		wctx, wcancel := context.WithTimeout(ctx, collabWriteTimeout)
+		err := conn.Write(wctx, websocket.MessageText, b)
+		wcancel()
+		if err != nil {
+			slog.Debug("协同编辑连接写入失败", "id", c.id, "error", err)
+			return
+		}
+	}
+	conn.Close(websocket.StatusGoingAway, "连接已断开，请重新连接")
+}
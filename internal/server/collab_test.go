@@ -0,0 +1,186 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name, target, patch, want string
+	}{
+		{"replace value", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add key", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"null deletes", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"null for missing key", `{"a":"b"}`, `{"x":null}`, `{"a":"b"}`},
+		{"nested merge", `{"a":{"b":"c","d":"e"}}`, `{"a":{"b":"x","d":null,"f":1}}`, `{"a":{"b":"x","f":1}}`},
+		{"array replaced", `{"a":[1,2,3]}`, `{"a":[4]}`, `{"a":[4]}`},
+		{"array to object", `{"a":[1]}`, `{"a":{"b":null,"c":1}}`, `{"a":{"c":1}}`},
+		{"object to scalar", `{"a":{"b":1}}`, `{"a":2}`, `{"a":2}`},
+		{"non-object target", `[1,2]`, `{"a":1}`, `{"a":1}`},
+		{"non-object patch", `{"a":1}`, `[1]`, `[1]`},
+		{"empty patch", `{"a":1}`, `{}`, `{"a":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target, patch, want any
+			for _, v := range []struct {
+				src string
+				dst *any
+			}{{tt.target, &target}, {tt.patch, &patch}, {tt.want, &want}} {
+				if err := json.Unmarshal([]byte(v.src), v.dst); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollabHubDropsSlowClient(t *testing.T) {
+	hub := newCollabHub()
+	slow := hub.join("a.json")
+	fast := hub.join("a.json")
+	other := hub.join("b.json")
+
+	for i := range collabSendBuf + 1 {
+		hub.broadcast("a.json", []byte{byte(i)}, nil)
+		<-fast.send // 正常连接及时取走
+	}
+
+	hub.mu.Lock()
+	_, slowJoined := hub.rooms["a.json"][slow]
+	_, fastJoined := hub.rooms["a.json"][fast]
+	hub.mu.Unlock()
+	if slowJoined || !fastJoined {
+		t.Fatalf("slow joined = %v, fast joined = %v; want only the slow client dropped", slowJoined, fastJoined)
+	}
+	n := 0
+	for range slow.send { // 队列已关闭，读完缓冲后结束
+		n++
+	}
+	if n != collabSendBuf {
+		t.Errorf("slow client received %d messages before drop, want %d", n, collabSendBuf)
+	}
+	if len(other.send) != 0 {
+		t.Error("broadcast leaked into another chart")
+	}
+
+	hub.broadcast("a.json", []byte("x"), fast)
+	if len(fast.send) != 0 {
+		t.Error("broadcast sent to the excepted client")
+	}
+	hub.close()
+	if _, ok := <-fast.send; ok {
+		t.Error("send queue still open after close")
+	}
+	if hub.join("a.json") != nil {
+		t.Error("join succeeded after close")
+	}
+}
+
+// dialCollab 连接 /ws/state 并返回按顺序读取消息的函数。
+func dialCollab(t *testing.T, ctx context.Context, url string) (*websocket.Conn, func() collabMessage) {
+	t.Helper()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(url, "http")+"/ws/state", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.CloseNow() })
+	return conn, func() collabMessage {
+		t.Helper()
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg collabMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+}
+
+func TestStateWSRoundTrip(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	if rec := do(t, h, http.MethodPost, "/api/state", `{"title":"t","cells":[1]}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("seed state: %d %s", rec.Code, rec.Body)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a, readA := dialCollab(t, ctx, srv.URL)
+	_, readB := dialCollab(t, ctx, srv.URL)
+
+	snapA, snapB := readA(), readB()
+	for _, snap := range []collabMessage{snapA, snapB} {
+		if snap.Type != "snapshot" || snap.ID == 0 {
+			t.Fatalf("first message = %+v, want snapshot with id", snap)
+		}
+		var state map[string]any
+		if err := json.Unmarshal(snap.State, &state); err != nil || state["title"] != "t" {
+			t.Fatalf("snapshot state = %s", snap.State)
+		}
+	}
+
+	for seq := uint64(1); seq <= 3; seq++ {
+		title := "t" + strings.Repeat("x", int(seq))
+		patch := fmt.Sprintf(`{"seq":%d,"patch":{"title":%q,"cells":null}}`, seq, title)
+		if err := a.Write(ctx, websocket.MessageText, []byte(patch)); err != nil {
+			t.Fatal(err)
+		}
+		if ack := readA(); ack.Type != "ack" || ack.Seq != seq {
+			t.Fatalf("sender got %+v, want ack seq %d", ack, seq)
+		}
+		got := readB()
+		if got.Type != "patch" || got.From != snapA.ID {
+			t.Fatalf("peer got %+v, want patch from %d", got, snapA.ID)
+		}
+		var p map[string]any
+		if err := json.Unmarshal(got.Patch, &p); err != nil || p["title"] != title {
+			t.Fatalf("peer patch %d = %s, out of order", seq, got.Patch)
+		}
+	}
+
+	if err := a.Write(ctx, websocket.MessageText, []byte(`{"seq":9,"patch":[1]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if msg := readA(); msg.Type != "error" || msg.Seq != 9 {
+		t.Fatalf("non-object patch got %+v, want error seq 9", msg)
+	}
+
+	rec := do(t, h, http.MethodGet, "/api/state", "")
+	var state map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &state); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state["cells"]; ok || state["title"] != "txxx" {
+		t.Errorf("merged state = %s", rec.Body)
+	}
+}
+
+func TestPatchStateRejectsNonObject(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	for _, body := range []string{`[1,2]`, `"x"`, `null`, `42`, `{`} {
+		if rec := do(t, h, http.MethodPatch, "/api/state", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("PATCH %s status = %d, want 400", body, rec.Code)
+		}
+	}
+	rec := do(t, h, http.MethodPatch, "/api/state", `{"title":"t"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"title"`) {
+		t.Errorf("valid PATCH status = %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// 主色提取参数。
+const (
+	coverColorsDefault = 5
+	coverColorsMin     = 3
+	coverColorsMax     = 8
+	coverColorsCache   = 512 // 最多缓存的结果数，超出时整体清空
+)
+
+// coverColor 是响应中的一种主色。
+type coverColor struct {
+	Hex    string  `json:"hex"`
+	Weight float64 `json:"weight"`
+}
+
+// colorKey 标识一次主色提取；文件大小或修改时间变化后自然失效。
+type colorKey struct {
+	path    string
+	n       int
+	size    int64
+	modTime time.Time
+}
+
+// colorCache 缓存主色提取结果，避免前端反复请求时重复解码。零值可直接使用。
+type colorCache struct {
+	mu      sync.Mutex
+	entries map[colorKey][]coverColor
+}
+
+// get 返回缓存的结果。
+func (c *colorCache) get(k colorKey) ([]coverColor, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[k]
+	return v, ok
+}
+
+// put 写入结果；条目过多时清空重来，过期条目随之回收。
+func (c *colorCache) put(k colorKey, v []coverColor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= coverColorsCache {
+		c.entries = make(map[colorKey][]coverColor)
+	}
+	c.entries[k] = v
+}
+
+// handleCoverColors 返回封面的主色（GET /api/cover/colors?file=xxx&n=5），按占比从高到低排列。
+// 结果按文件名与修改时间缓存。
+func (h *handler) handleCoverColors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := r.URL.Query().Get("file")
+	rel, ok := coverRelPath(file)
+	if !ok || !isImageName(rel) {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "无效的封面文件名"})
+		return
+	}
+	n := coverColorsDefault
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		n, err = strconv.Atoi(v)
+		if err != nil || n < coverColorsMin || n > coverColorsMax {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("n 须在 %d-%d 之间", coverColorsMin, coverColorsMax),
+			})
+			return
+		}
+	}
+
+	path, _ := h.covers.find(rel)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "封面不存在"})
+			return
+		}
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	key := colorKey{path: path, n: n, size: info.Size(), modTime: info.ModTime()}
+	colors, ok := h.colors.get(key)
+	if !ok {
+		img, err := imaging.Load(path)
+		if err != nil {
+			h.writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		colors = []coverColor{}
+		for _, s := range imaging.DominantColors(img, n) {
+			colors = append(colors, coverColor{Hex: s.Hex(), Weight: s.Weight})
+		}
+		h.colors.put(key, colors)
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"file": file, "colors": colors})
+}
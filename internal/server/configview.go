@@ -0,0 +1,157 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// configView 是 GET /api/config 返回的生效配置。由解析参数得到的 config.Config 与启动时确定的目录构建，
+// 不包含任何密钥：Basic Auth 只报告是否启用，AniDB 客户端名与 Bangumi Token 只报告是否已设置。
+type configView struct {
+	ListenAddr string `json:"listenAddr,omitempty"` // 处理本次请求的本地监听地址
+
+	Dirs configDirs `json:"dirs"`
+
+	Sources         []sourceInfo `json:"sources"`
+	DisabledSources []string     `json:"disabledSources"`
+	AniDBConfigured bool         `json:"anidbConfigured"`
+	BgmTokenSet     bool         `json:"bgmTokenSet"`
+
+	ReadOnly    bool `json:"readOnly"`
+	AuthEnabled bool `json:"authEnabled"`
+	PrettyJSON  bool `json:"prettyJson"`
+	Embedded    bool `json:"embedded"`
+
+	LogFormat   string `json:"logFormat"`
+	StateIndent string `json:"stateIndent"`
+	UserAgent   string `json:"userAgent"`
+	FontPath    string `json:"fontPath,omitempty"`
+
+	APITimeout       string `json:"apiTimeout"`
+	DownloadTimeout  string `json:"downloadTimeout"`
+	AutosaveInterval string `json:"autosaveInterval"`
+	ArchiveInterval  string `json:"archiveInterval"`
+	ArchiveKeep      int    `json:"archiveKeep"`
+	TrashDays        int    `json:"trashDays"`
+
+	CoverSubdirs      bool     `json:"coverSubdirs"`
+	VerifyImages      bool     `json:"verifyImages"`
+	CoversFileMode    string   `json:"coversFileMode"`
+	CoversDirMode     string   `json:"coversDirMode"`
+	CoverHosts        []string `json:"coverHosts"`
+	AllowAnyHost      bool     `json:"allowAnyHost"`
+	FilenameTemplate  string   `json:"filenameTemplate,omitempty"`
+	FilenameMaxLength int      `json:"filenameMaxLength"`
+
+	UpstreamQueue       int      `json:"upstreamQueue"`
+	MaxIdleConnsPerHost int      `json:"maxIdleConnsPerHost"`
+	MaxConnsPerHost     int      `json:"maxConnsPerHost"`
+	CacheIgnoreFields   []string `json:"cacheIgnoreFields"`
+	CacheBackend        string   `json:"cacheBackend"`
+
+	MaxBrowseLimit int `json:"maxBrowseLimit"`
+	VNDBMaxResults int `json:"vndbMaxResults"`
+	VNDBPageSize   int `json:"vndbPageSize"`
+
+	PregenThumbs   bool `json:"pregenThumbs"`
+	Prefetch       bool `json:"prefetch"`
+	Phash          bool `json:"phash"`
+	PhashThreshold int  `json:"phashThreshold"`
+}
+
+// configDirs 是服务实际使用的数据目录。
+type configDirs struct {
+	Covers   string   `json:"covers"`
+	Library  []string `json:"library"`
+	Charts   string   `json:"charts"`
+	Thumbs   string   `json:"thumbs"`
+	State    string   `json:"state"`
+	Frontend string   `json:"frontend,omitempty"`
+}
+
+// handleConfig 返回脱敏后的生效配置（GET /api/config），用于排查部署时实际使用的参数。
+func (h *handler) handleConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cfg := h.cfg
+	v := configView{
+		Dirs: configDirs{
+			Covers:   h.coversDir,
+			Library:  append([]string{}, cfg.LibraryDirs...),
+			Charts:   h.chartsDir,
+			Thumbs:   h.thumbsDir,
+			State:    h.stateFile,
+			Frontend: cfg.FrontendDir,
+		},
+
+		Sources:         h.sources(),
+		DisabledSources: append([]string{}, cfg.DisabledSources...),
+		AniDBConfigured: h.anidb.Configured(),
+		BgmTokenSet:     h.bgm.HasToken(),
+
+		ReadOnly:    cfg.ReadOnly,
+		AuthEnabled: cfg.AuthEnabled(),
+		PrettyJSON:  cfg.PrettyJSON,
+		Embedded:    cfg.Embedded,
+
+		LogFormat:   cfg.LogFormat,
+		StateIndent: cfg.StateIndent,
+		UserAgent:   cmp.Or(cfg.UserAgent, api.DefaultUserAgent),
+		FontPath:    cfg.FontPath,
+
+		APITimeout:       cfg.APITimeout.String(),
+		DownloadTimeout:  cfg.DownloadTimeout.String(),
+		AutosaveInterval: cfg.AutosaveInterval.String(),
+		ArchiveInterval:  cfg.ArchiveInterval.String(),
+		ArchiveKeep:      cfg.ArchiveKeep,
+		TrashDays:        cfg.TrashDays,
+
+		CoverSubdirs:      cfg.CoverSubdirs,
+		VerifyImages:      cfg.VerifyImages,
+		CoversFileMode:    fileModeString(cfg.CoversFileMode),
+		CoversDirMode:     fileModeString(cfg.CoversDirMode),
+		AllowAnyHost:      cfg.AllowAnyHost,
+		FilenameTemplate:  cfg.FilenameTemplate,
+		FilenameMaxLength: cfg.FilenameMaxLength,
+
+		UpstreamQueue:       cfg.UpstreamQueue,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		CacheIgnoreFields:   append([]string{}, cfg.CacheIgnoreFields...),
+		CacheBackend:        cfg.CacheBackend,
+
+		MaxBrowseLimit: cfg.MaxBrowseLimit,
+		VNDBMaxResults: cfg.VNDBMaxResults,
+		VNDBPageSize:   cfg.VNDBPageSize,
+
+		PregenThumbs:   cfg.PregenThumbs,
+		Prefetch:       cfg.Prefetch,
+		Phash:          cfg.Phash,
+		PhashThreshold: cfg.PhashThreshold,
+	}
+	switch {
+	case cfg.AllowAnyHost:
+		v.CoverHosts = []string{}
+	case len(cfg.CoverHosts) > 0:
+		v.CoverHosts = append([]string{}, cfg.CoverHosts...)
+	default:
+		v.CoverHosts = append([]string{}, api.DefaultCoverHosts...)
+	}
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		v.ListenAddr = addr.String()
+	}
+	h.writeJSON(w, http.StatusOK, v)
+}
+
+// fileModeString 把权限格式化为四位八进制，如 0644。
+func fileModeString(m os.FileMode) string {
+	return fmt.Sprintf("%04o", uint32(m.Perm()))
+}
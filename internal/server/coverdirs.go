@@ -0,0 +1,229 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// coverDir 是一个封面目录；经 os.Root 访问，符号链接无法逃逸到目录之外。
+type coverDir struct {
+	path  string
+	state *coverRoot
+}
+
+// coverRoot 是目录当前打开的句柄。目录被删除后重建时句柄仍指向旧目录，因此每次使用前比对 inode，
+// 变化后重新打开。
+type coverRoot struct {
+	mu   sync.Mutex
+	root *os.Root
+	info os.FileInfo // 打开 root 时目录的信息，用于 os.SameFile 比对
+}
+
+// openCoverDir 打开目录并记录其 inode。
+func openCoverDir(path string) (coverDir, error) {
+	root, err := os.OpenRoot(path)
+	if err != nil {
+		return coverDir{}, err
+	}
+	info, err := root.Stat(".")
+	if err != nil {
+		root.Close()
+		return coverDir{}, err
+	}
+	return coverDir{path: path, state: &coverRoot{root: root, info: info}}, nil
+}
+
+// root 返回目录的句柄；路径已指向另一个目录（删除后重建）时重新打开。目录暂不存在时沿用旧句柄，查找自然落空。
+func (dir coverDir) root() *os.Root {
+	s := dir.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info, err := os.Stat(dir.path)
+	if err != nil || os.SameFile(info, s.info) {
+		return s.root
+	}
+	root, err := os.OpenRoot(dir.path)
+	if err != nil {
+		return s.root
+	}
+	_ = s.root.Close()
+	s.root, s.info = root, info
+	return root
+}
+
+// close 关闭目录句柄。
+func (dir coverDir) close() {
+	dir.state.mu.Lock()
+	defer dir.state.mu.Unlock()
+	_ = dir.state.root.Close()
+}
+
+// coverDirs 是封面的查找目录，按优先级从低到高排列：-library-dirs 指定的只读图库在前（靠后的覆盖靠前的），
+// 可写的 covers/ 目录固定在最后，因此同名时下载或上传的封面优先。写入只发生在可写目录。
+type coverDirs []coverDir
+
+// openCoverDirs 依次打开目录，任一目录不存在或不是目录时返回错误。
+func openCoverDirs(paths []string) (coverDirs, error) {
+	dirs := make(coverDirs, 0, len(paths))
+	for _, p := range paths {
+		dir, err := openCoverDir(p)
+		if err != nil {
+			dirs.close()
+			return nil, fmt.Errorf("打开封面目录失败: %w", err)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// close 关闭全部目录句柄。
+func (d coverDirs) close() {
+	for _, dir := range d {
+		dir.close()
+	}
+}
+
+// find 按优先级查找相对路径 rel（系统分隔符）对应的文件，返回其完整路径。
+// 各目录都没有时返回可写目录中的路径且 ok 为 false；经符号链接指向目录之外的文件视为不存在。
+func (d coverDirs) find(rel string) (path string, ok bool) {
+	for i := len(d) - 1; i >= 0; i-- {
+		if info, err := d[i].root().Stat(rel); err == nil && !info.IsDir() {
+			return filepath.Join(d[i].path, rel), true
+		}
+	}
+	return filepath.Join(d[len(d)-1].path, rel), false
+}
+
+// Open 实现 http.FileSystem，按优先级在各目录中解析 /covers/ 下的路径。
+// 任一路径段以点开头时视为不存在：回收站、下载临时文件等隐藏内容不对外提供。
+func (d coverDirs) Open(name string) (http.File, error) {
+	if hasHiddenSegment(name) {
+		return nil, os.ErrNotExist
+	}
+	for i := len(d) - 1; i >= 0; i-- {
+		f, err := http.FS(d[i].root().FS()).Open(name)
+		if err == nil {
+			return f, nil
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// hasHiddenSegment 判断以 / 分隔的路径中是否有以点开头的段。
+func hasHiddenSegment(name string) bool {
+	for seg := range strings.SplitSeq(name, "/") {
+		if strings.HasPrefix(seg, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// scan 合并扫描所有目录中的图片，返回去重后的相对路径（/ 分隔）以及扫描经过的目录的修改时间。
+// recursive 为 true 时包含子目录并跳过隐藏目录；指向目录之外的符号链接被忽略。
+func (d coverDirs) scan(recursive bool) ([]string, dirStamp, error) {
+	seen := make(map[string]bool)
+	files := []string{}
+	dirs := dirStamp{}
+	for _, dir := range d {
+		scan := dir.scanFlat
+		if recursive {
+			scan = dir.scanRecursive
+		}
+		err := scan(dirs, func(rel string) {
+			if !seen[rel] {
+				seen[rel] = true
+				files = append(files, rel)
+			}
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return strings.ToLower(files[i]) < strings.ToLower(files[j])
+	})
+	return files, dirs, nil
+}
+
+// scanFlat 列出目录顶层的图片文件（不含子目录）。目录的修改时间在读取内容之前记入 dirs。
+func (dir coverDir) scanFlat(dirs dirStamp, add func(string)) error {
+	info, _ := os.Stat(dir.path)
+	dirs.record(dir.path, info)
+	entries, err := os.ReadDir(dir.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !isImageName(e.Name()) || !dir.contains(e.Name(), e.Type()) {
+			continue
+		}
+		add(e.Name())
+	}
+	return nil
+}
+
+// scanRecursive 递归列出目录中的图片文件，跳过隐藏目录。经过的每个目录的修改时间在读取其内容之前记入 dirs，
+// 子目录中的增删只改变该子目录的 mtime，因此必须逐个记录。
+func (dir coverDir) scanRecursive(dirs dirStamp, add func(string)) error {
+	return filepath.WalkDir(dir.path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == dir.path {
+				dirs.record(path, nil)
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() {
+			if path != dir.path && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			info, _ := os.Stat(path)
+			dirs.record(path, info)
+			return nil
+		}
+		if !isImageName(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir.path, path)
+		if err != nil {
+			return err
+		}
+		if dir.contains(rel, d.Type()) {
+			add(filepath.ToSlash(rel))
+		}
+		return nil
+	})
+}
+
+// contains 判断目录项是否是目录内的普通文件；符号链接须解析到目录之内。
+func (dir coverDir) contains(rel string, typ fs.FileMode) bool {
+	if typ&fs.ModeSymlink == 0 {
+		return true
+	}
+	info, err := dir.root().Stat(rel)
+	return err == nil && !info.IsDir()
+}
+
+// isImageName 判断文件名是否带有支持的图片扩展名。
+func isImageName(name string) bool {
+	_, ok := imageExts[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// coverPath 返回封面相对路径（/ 分隔）在各目录中实际所在的完整路径。
+func (h *handler) coverPath(rel string) string {
+	path, _ := h.covers.find(filepath.FromSlash(rel))
+	return path
+}
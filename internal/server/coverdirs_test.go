@@ -0,0 +1,89 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCoverDirsOpenHidesDotPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, rel := range []string{"a.png", ".trash/b.png", "sub/.part.png", "sub/c.png"} {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirs, err := openCoverDirs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dirs.close()
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"/a.png", true},
+		{"/sub/c.png", true},
+		{"/.trash/b.png", false},
+		{"/.trash", false},
+		{"/sub/.part.png", false},
+	}
+	for _, tt := range tests {
+		f, err := dirs.Open(tt.name)
+		if err == nil {
+			f.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("Open(%q) err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+		if !tt.ok && !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Open(%q) err = %v, want ErrNotExist", tt.name, err)
+		}
+	}
+}
+
+func TestCoverDirsReopenAfterRecreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "covers")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "old.png"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dirs, err := openCoverDirs([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dirs.close()
+	if _, ok := dirs.find("old.png"); !ok {
+		t.Fatal("find(old.png) before recreate failed")
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dirs.find("old.png"); ok {
+		t.Fatal("find(old.png) succeeded after directory removal")
+	}
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new.png"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := dirs.find("new.png"); !ok {
+		t.Error("find(new.png) after recreate failed")
+	}
+	f, err := dirs.Open("/new.png")
+	if err != nil {
+		t.Fatalf("Open(/new.png) after recreate: %v", err)
+	}
+	f.Close()
+}
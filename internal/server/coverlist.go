@@ -0,0 +1,101 @@
+package server
+
+import (
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// coverListCache 缓存封面目录扫描结果，避免前端频繁轮询时反复 ReadDir。
+// 应用内每次写操作（下载/上传/删除等）完成后递增 generation 使缓存失效；
+// 扫描开始前记录 generation，扫描期间若有写入，结果会以旧 generation 入缓存并在下次请求时重扫，
+// 因此不会返回缺少新文件的旧列表。扫描时还记录经过的每个目录的 mtime（递归模式包含全部子目录），
+// 任一目录的 mtime 变化（应用外的增删）同样会触发重扫。
+type coverListCache struct {
+	gen     atomic.Uint64
+	mu      sync.Mutex
+	seq     uint64                  // 每次重扫后递增，供依赖列表内容的缓存判断是否需要重建
+	entries map[bool]coverListEntry // key: 是否递归
+}
+
+// coverListEntry 是一次扫描的结果快照。
+type coverListEntry struct {
+	gen   uint64
+	seq   uint64
+	dirs  dirStamp
+	files []string
+}
+
+// dirStamp 记录扫描时各目录的修改时间，目录不存在时记为零值。
+type dirStamp map[string]time.Time
+
+// record 记录目录当前的修改时间。
+func (s dirStamp) record(path string, info os.FileInfo) {
+	if info == nil {
+		s[path] = time.Time{}
+		return
+	}
+	s[path] = info.ModTime()
+}
+
+// fresh 判断记录的目录是否都未变化；只 Stat 目录，不读取目录内容。
+func (s dirStamp) fresh() bool {
+	for path, mod := range s {
+		var cur time.Time
+		if info, err := os.Stat(path); err == nil {
+			cur = info.ModTime()
+		}
+		if !cur.Equal(mod) {
+			return false
+		}
+	}
+	return true
+}
+
+// invalidate 递增 generation，使已缓存的列表失效。
+func (c *coverListCache) invalidate() {
+	c.gen.Add(1)
+}
+
+// list 返回封面列表及其扫描序号，缓存有效时直接返回副本，否则调用 scan 重新扫描。
+// 序号不变说明列表来自同一次扫描。
+func (c *coverListCache) list(recursive bool, scan func() ([]string, dirStamp, error)) ([]string, uint64, error) {
+	gen := c.gen.Load()
+
+	c.mu.Lock()
+	e, ok := c.entries[recursive]
+	c.mu.Unlock()
+	if ok && e.gen == gen && e.dirs.fresh() {
+		return slices.Clone(e.files), e.seq, nil
+	}
+
+	files, dirs, err := scan()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[bool]coverListEntry)
+	}
+	c.seq++
+	e = coverListEntry{gen: gen, seq: c.seq, dirs: dirs, files: files}
+	c.entries[recursive] = e
+	c.mu.Unlock()
+	return slices.Clone(files), e.seq, nil
+}
+
+// listCovers 返回封面列表（带缓存）；recursive 为 true 时包含子目录。
+func (h *handler) listCovers(recursive bool) ([]string, error) {
+	files, _, err := h.coverListing(recursive)
+	return files, err
+}
+
+// coverListing 与 listCovers 相同，另外返回扫描序号。
+func (h *handler) coverListing(recursive bool) ([]string, uint64, error) {
+	return h.coverList.list(recursive, func() ([]string, dirStamp, error) {
+		return h.covers.scan(recursive)
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestListCoversSeesSubdirChanges(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	writeTestCover(t, h.coversDir, "sub/a.png")
+
+	files, err := h.listCovers(true)
+	if err != nil || !slices.Equal(files, []string{"sub/a.png"}) {
+		t.Fatalf("listCovers = %v, %v", files, err)
+	}
+
+	// 应用外在已有子目录中新增与删除文件：covers/ 本身的 mtime 不变
+	writeTestCover(t, h.coversDir, "sub/b.png")
+	files, _ = h.listCovers(true)
+	if !slices.Equal(files, []string{"sub/a.png", "sub/b.png"}) {
+		t.Fatalf("after add = %v, want sub/a.png, sub/b.png", files)
+	}
+	if err := os.Remove(filepath.Join(h.coversDir, "sub", "a.png")); err != nil {
+		t.Fatal(err)
+	}
+	files, _ = h.listCovers(true)
+	if !slices.Equal(files, []string{"sub/b.png"}) {
+		t.Fatalf("after remove = %v, want sub/b.png", files)
+	}
+
+	// 新建的嵌套目录改变其父目录的 mtime
+	writeTestCover(t, h.coversDir, "sub/deep/c.png")
+	files, _ = h.listCovers(true)
+	if !slices.Contains(files, "sub/deep/c.png") {
+		t.Fatalf("after nested add = %v, want sub/deep/c.png", files)
+	}
+}
+
+func TestListCoversCached(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	writeTestCover(t, h.coversDir, "a.png")
+
+	_, seq1, err := h.coverListing(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, seq2, _ := h.coverListing(true); seq2 != seq1 {
+		t.Fatalf("unchanged directory rescanned: seq %d -> %d", seq1, seq2)
+	}
+	h.coverList.invalidate()
+	if _, seq3, _ := h.coverListing(true); seq3 == seq1 {
+		t.Fatal("invalidate did not trigger a rescan")
+	}
+}
+
+// setupBenchCovers 在 dirs 个子目录中各写入 perDir 张封面。
+func setupBenchCovers(b *testing.B, dir string, dirs, perDir int) {
+	b.Helper()
+	for i := range dirs {
+		sub := filepath.Join(dir, fmt.Sprintf("d%02d", i))
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		for j := range perDir {
+			if err := os.WriteFile(filepath.Join(sub, fmt.Sprintf("%04d.jpg", j)), nil, 0o644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkListCovers 比较递归列表在缓存命中（只 Stat 目录）与每次重扫时的开销。
+func BenchmarkListCovers(b *testing.B) {
+	dir := b.TempDir()
+	setupBenchCovers(b, dir, 20, 100)
+	covers, err := openCoverDirs([]string{dir})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer covers.close()
+	scan := func() ([]string, dirStamp, error) { return covers.scan(true) }
+
+	b.Run("cached", func(b *testing.B) {
+		var c coverListCache
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, _, err := c.list(true, scan); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("rescan", func(b *testing.B) {
+		var c coverListCache
+		b.ReportAllocs()
+		for b.Loop() {
+			c.invalidate()
+			if _, _, err := c.list(true, scan); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+)
+
+// coverOrderFileName 是封面自定义排序的持久化文件，与 state.json 同目录。
+const coverOrderFileName = "covers-order.json"
+
+// coverOrder 是用户保存的封面显示顺序。列表中已删除的文件在应用时跳过，
+// 尚未排序的新文件按原有的字母顺序追加在末尾。
+type coverOrder struct {
+	path  string
+	mu    sync.RWMutex
+	names []string
+	index map[string]int // 文件名 -> 在 names 中的位置
+}
+
+// loadCoverOrder 读取已保存的顺序；文件缺失或无效时视为未设置。
+func loadCoverOrder(path string) *coverOrder {
+	o := &coverOrder{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("读取封面排序失败", "path", path, "error", err)
+		}
+		return o
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		slog.Warn("封面排序文件无效，已忽略", "path", path, "error", err)
+		return o
+	}
+	o.setLocked(names)
+	return o
+}
+
+// setLocked 去重后替换内存中的顺序，调用方需持有写锁（加载时除外）。
+func (o *coverOrder) setLocked(names []string) {
+	o.names = make([]string, 0, len(names))
+	o.index = make(map[string]int, len(names))
+	for _, name := range names {
+		if _, dup := o.index[name]; dup || name == "" {
+			continue
+		}
+		o.index[name] = len(o.names)
+		o.names = append(o.names, name)
+	}
+}
+
+// save 持久化新顺序，空列表表示恢复默认的字母顺序。
+func (o *coverOrder) save(names []string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.setLocked(names)
+	if len(o.names) == 0 {
+		if err := os.Remove(o.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(o.names, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeChartFile(o.path, append(data, '\n'))
+}
+
+// get 返回已保存的顺序。
+func (o *coverOrder) get() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return append([]string{}, o.names...)
+}
+
+// apply 按保存的顺序重排 files（原地），未排序的文件保持原有相对顺序排在最后。
+func (o *coverOrder) apply(files []string) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	if len(o.index) == 0 {
+		return
+	}
+	rank := func(name string) int {
+		if i, ok := o.index[name]; ok {
+			return i
+		}
+		return len(o.names)
+	}
+	slices.SortStableFunc(files, func(a, b string) int { return cmp.Compare(rank(a), rank(b)) })
+}
+
+// handleCoverOrder 查看或保存封面的自定义排序（GET/POST /api/covers/order）。
+// POST 请求体为 {"files": [...]}，空数组恢复字母顺序。
+func (h *handler) handleCoverOrder(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req struct {
+			Files []string `json:"files"`
+		}
+		if err := readJSON(r, &req); err != nil {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+			return
+		}
+		if err := h.coverOrder.save(req.Files); err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "保存封面排序失败: " + err.Error()})
+			return
+		}
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"files": h.coverOrder.get()})
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// coverSourceOther 是不在数据源子目录中的封面（上传或平铺下载）的来源名。
+const coverSourceOther = "other"
+
+// coverBucket 是一组封面的数量与总字节数。
+type coverBucket struct {
+	Count int   `json:"count"`
+	Bytes int64 `json:"bytes"`
+}
+
+// coverStamp 标识一张封面及其修改时间。
+type coverStamp struct {
+	Name    string    `json:"name"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// coverStats 是封面库的汇总统计。
+type coverStats struct {
+	Total    int                    `json:"total"`
+	Bytes    int64                  `json:"bytes"`
+	ByExt    map[string]coverBucket `json:"byExt"`
+	BySource map[string]coverBucket `json:"bySource"`
+	Oldest   *coverStamp            `json:"oldest,omitempty"`
+	Newest   *coverStamp            `json:"newest,omitempty"`
+}
+
+// add 把一张封面计入统计。
+func (s *coverStats) add(name string, info os.FileInfo) {
+	s.Total++
+	s.Bytes += info.Size()
+
+	ext := strings.ToLower(filepath.Ext(name))
+	b := s.ByExt[ext]
+	b.Count++
+	b.Bytes += info.Size()
+	s.ByExt[ext] = b
+
+	src := coverSource(name)
+	b = s.BySource[src]
+	b.Count++
+	b.Bytes += info.Size()
+	s.BySource[src] = b
+
+	mod := info.ModTime()
+	if s.Oldest == nil || mod.Before(s.Oldest.ModTime) {
+		s.Oldest = &coverStamp{Name: name, ModTime: mod}
+	}
+	if s.Newest == nil || mod.After(s.Newest.ModTime) {
+		s.Newest = &coverStamp{Name: name, ModTime: mod}
+	}
+}
+
+// coverSource 根据 covers/<source>/ 子目录推断封面来源。
+func coverSource(name string) string {
+	dir, _, ok := strings.Cut(name, "/")
+	if ok && (dir == api.SourceBangumi || dir == api.SourceVNDB) {
+		return dir
+	}
+	return coverSourceOther
+}
+
+// handleCoverStats 汇总封面库的数量、体积、扩展名与来源分布（GET /api/covers/stats）。
+// 基于缓存的递归列表，只读取文件元数据，不解码图片。
+func (h *handler) handleCoverStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	names, err := h.listCovers(true)
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	stats := coverStats{
+		ByExt:    make(map[string]coverBucket),
+		BySource: make(map[string]coverBucket),
+	}
+	for _, name := range names {
+		info, err := os.Stat(h.coverPath(name))
+		if err != nil {
+			continue // 列表缓存与磁盘之间被删除的文件直接跳过
+		}
+		stats.add(name, info)
+	}
+	h.writeJSON(w, http.StatusOK, stats)
+}
+
+// handleStats 返回本次运行中各数据源的封面下载数与字节数（GET /api/stats），重启后清零。
+func (h *handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bySource := map[string]api.DownloadStats{
+		api.SourceBangumi: h.bgm.DownloadStats(),
+		api.SourceVNDB:    h.vndb.DownloadStats(),
+		api.SourceAniDB:   h.anidb.DownloadStats(),
+	}
+	var total api.DownloadStats
+	for _, s := range bySource {
+		total.Count += s.Count
+		total.Bytes += s.Bytes
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"downloads": map[string]any{"bySource": bySource, "total": total},
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// coverVersion 是封面库的修改代数：应用内每次新增、删除或替换封面后递增，并记录变化时间。
+// 前端轮询 GET /api/covers/version 比较代数即可判断是否需要重新拉取列表，无需建立 SSE 连接。
+// 代数只在进程内有效，重启后从 0 开始；changedAt 初始为启动时间。零值可直接使用。
+type coverVersion struct {
+	gen       atomic.Uint64
+	changedAt atomic.Int64 // Unix 毫秒
+}
+
+// bump 记录一次封面库变化。
+func (v *coverVersion) bump() {
+	v.changedAt.Store(time.Now().UnixMilli())
+	v.gen.Add(1)
+}
+
+// load 返回当前代数与最近一次变化的时间。
+func (v *coverVersion) load() (uint64, time.Time) {
+	gen := v.gen.Load()
+	return gen, time.UnixMilli(v.changedAt.Load())
+}
+
+// handleCoverVersion 返回封面库的修改代数（GET /api/covers/version）。
+// 响应带 ETag，客户端携带 If-None-Match 轮询时未变化直接返回 304。
+func (h *handler) handleCoverVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	gen, changedAt := h.coverVersion.load()
+	etag := `"` + strconv.FormatUint(gen, 10) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"version":   gen,
+		"changedAt": changedAt.UTC().Format(time.RFC3339Nano),
+	})
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// handleSubjectPersons 返回条目的制作人员（GET /api/subject/persons?id=<n>）。
+// image 与封面 URL 一样可交给 /api/download-cover 保存，用于制作「staff」行。
+func (h *handler) handleSubjectPersons(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少或无效的 id 参数"})
+		return
+	}
+	persons, err := h.bgm.GetSubjectPersons(id)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"id": id, "persons": persons})
+}
+
+// handleSubjectCharacters 返回条目的角色及声优（GET /api/subject/characters?id=<n>）。
+// 角色与声优的 image 均可交给 /api/download-cover 保存。
+func (h *handler) handleSubjectCharacters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少或无效的 id 参数"})
+		return
+	}
+	chars, err := h.bgm.GetSubjectCharacters(id)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"id": id, "characters": chars})
+}
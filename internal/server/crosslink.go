@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// crossLinkSource 是单个数据源的跨源匹配结果；Found 表示存在置信度达标的候选。
+type crossLinkSource struct {
+	Found   bool                 `json:"found"`
+	Matches []api.CrossLinkMatch `json:"matches"`
+	Error   string               `json:"error,omitempty"`
+	Skipped bool                 `json:"skipped,omitempty"` // 数据源不可用，未查询
+}
+
+// handleCrossLink 按标题并发查询所有数据源，报告各源是否存在对应条目（POST /api/crosslink）。
+// 单个数据源失败不影响其他数据源，错误记录在该源的 error 字段中；不可用的数据源跳过并标记 skipped。
+func (h *handler) handleCrossLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Title string `json:"title"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少 title"})
+		return
+	}
+
+	lookups := map[string]func(string) ([]api.CrossLinkMatch, error){
+		api.SourceBangumi: h.bgm.CrossLink,
+		api.SourceVNDB:    h.vndb.CrossLink,
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sources = make(map[string]crossLinkSource, len(lookups))
+	)
+	for source, lookup := range lookups {
+		if reason := h.sourceUnavailable(source); reason != "" {
+			sources[source] = crossLinkSource{Matches: []api.CrossLinkMatch{}, Skipped: true, Error: reason}
+			continue
+		}
+		wg.Go(func() {
+			matches, err := lookup(title)
+			res := crossLinkSource{Found: len(matches) > 0, Matches: matches}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			if res.Matches == nil {
+				res.Matches = []api.CrossLinkMatch{}
+			}
+			mu.Lock()
+			sources[source] = res
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"title":         title,
+		"minConfidence": api.CrossLinkMinConfidence,
+		"sources":       sources,
+	})
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// diagnosticsTimeout 是连通性检测中单个数据源的超时，远短于常规 API 超时，便于快速判断网络问题。
+const diagnosticsTimeout = 5 * time.Second
+
+// diagnosticsSource 是单个数据源的连通性检测结果，LatencyMs 为完整请求耗时。
+type diagnosticsSource struct {
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleDiagnostics 并发检测各数据源的连通性与延迟（GET /api/diagnostics），并附带状态写入队列的落盘情况
+// 与上游请求队列的占用（启用 -upstream-queue 时）。
+// 每次都直接请求上游，不经过缓存；单个数据源失败不影响其他数据源。
+func (h *handler) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// 在检测请求占用名额之前记录队列状态
+	var queue *api.UpstreamQueueStats
+	if h.upstream != nil {
+		stats := h.upstream.Stats()
+		queue = &stats
+	}
+
+	pings := map[string]func(context.Context) error{
+		api.SourceBangumi: h.bgm.Ping,
+		api.SourceVNDB:    h.vndb.Ping,
+	}
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sources = make(map[string]diagnosticsSource, len(pings))
+	)
+	for source, ping := range pings {
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(r.Context(), diagnosticsTimeout)
+			defer cancel()
+			start := time.Now()
+			err := ping(ctx)
+			res := diagnosticsSource{OK: err == nil, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			mu.Lock()
+			sources[source] = res
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	pending, lastFlush := h.states.Stats()
+	state := map[string]any{
+		"pendingCharts":    pending,
+		"autosaveInterval": h.cfg.AutosaveInterval.String(),
+	}
+	if !lastFlush.IsZero() {
+		state["lastFlush"] = lastFlush
+	}
+	resp := map[string]any{"sources": sources, "state": state}
+	if queue != nil {
+		resp["upstreamQueue"] = queue
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// coverAspectTolerance 是宽高比筛选允许的相对误差，容忍裁切与缩放带来的像素级偏差。
+const coverAspectTolerance = 0.02
+
+// coverDims 是封面的像素尺寸。
+type coverDims struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// dimEntry 缓存单个封面的尺寸，文件大小或修改时间变化后重新读取。
+type dimEntry struct {
+	dims    coverDims
+	size    int64
+	modTime time.Time
+}
+
+// dimIndex 是封面尺寸的内存索引，只解析图片头部，按需增量更新。零值可直接使用。
+type dimIndex struct {
+	mu      sync.Mutex
+	entries map[string]dimEntry
+}
+
+// lookup 返回 names 对应的尺寸；无法解析的文件被跳过，已不存在的文件从索引中清除。
+func (d *dimIndex) lookup(pathOf func(string) string, names []string) map[string]coverDims {
+	out := make(map[string]coverDims, len(names))
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.entries == nil {
+		d.entries = make(map[string]dimEntry)
+	}
+
+	live := make(map[string]bool, len(names))
+	for _, name := range names {
+		live[name] = true
+		path := pathOf(name)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if e, ok := d.entries[name]; ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+			out[name] = e.dims
+			continue
+		}
+		w, h, err := imaging.Dimensions(path)
+		if err != nil {
+			continue
+		}
+		e := dimEntry{dims: coverDims{Width: w, Height: h}, size: info.Size(), modTime: info.ModTime()}
+		d.entries[name] = e
+		out[name] = e.dims
+	}
+
+	for name := range d.entries {
+		if !live[name] {
+			delete(d.entries, name)
+		}
+	}
+	return out
+}
+
+// dimFilter 是封面列表的尺寸筛选条件，零值表示不筛选。
+type dimFilter struct {
+	minWidth  int
+	minHeight int
+	aspect    float64 // 宽/高，0 表示不限
+}
+
+// parseDimFilter 解析 minWidth、minHeight 与 aspect 查询参数。
+// aspect 可写作 "3:4"、"3/4" 或小数 "0.75"，按宽/高计算。
+func parseDimFilter(r *http.Request) (dimFilter, error) {
+	q := r.URL.Query()
+	var f dimFilter
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"minWidth", &f.minWidth}, {"minHeight", &f.minHeight}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return dimFilter{}, errors.New(p.name + " 需为非负整数")
+		}
+		*p.dst = n
+	}
+	if v := q.Get("aspect"); v != "" {
+		a, ok := parseAspect(v)
+		if !ok {
+			return dimFilter{}, errors.New("aspect 格式应为 3:4 或 0.75")
+		}
+		f.aspect = a
+	}
+	return f, nil
+}
+
+// parseAspect 解析宽高比，返回宽/高。
+func parseAspect(v string) (float64, bool) {
+	if w, h, ok := strings.Cut(strings.ReplaceAll(v, "/", ":"), ":"); ok {
+		wf, err1 := strconv.ParseFloat(strings.TrimSpace(w), 64)
+		hf, err2 := strconv.ParseFloat(strings.TrimSpace(h), 64)
+		if err1 != nil || err2 != nil || wf <= 0 || hf <= 0 {
+			return 0, false
+		}
+		return wf / hf, true
+	}
+	a, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || a <= 0 || math.IsInf(a, 0) {
+		return 0, false
+	}
+	return a, true
+}
+
+// active 报告是否设置了任一筛选条件。
+func (f dimFilter) active() bool {
+	return f.minWidth > 0 || f.minHeight > 0 || f.aspect > 0
+}
+
+// match 判断尺寸是否满足筛选条件。
+func (f dimFilter) match(d coverDims) bool {
+	if d.Width < f.minWidth || d.Height < f.minHeight || d.Height == 0 {
+		return false
+	}
+	if f.aspect > 0 {
+		actual := float64(d.Width) / float64(d.Height)
+		if math.Abs(actual-f.aspect) > f.aspect*coverAspectTolerance {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+func TestDownloadCoverRefreshInvalidatesThumbnails(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	name := api.CoverBaseName("A", "1") + ".png"
+	writeTestCover(t, h.coversDir, name)
+	thumb := filepath.Join(h.thumbsDir, thumbKey(name)+"_240.jpg")
+	if err := os.MkdirAll(h.thumbsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(thumb, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	img := testPNG(t)
+	stubBangumiHTTP(h, func(r *http.Request) *http.Response {
+		// 忽略 If-Modified-Since 并给出 Last-Modified：上游已更新
+		resp := stubResponse(r, http.StatusOK, "image/png", img)
+		resp.Header.Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		return resp
+	})
+
+	w := do(t, h, http.MethodPost, "/api/download-cover",
+		`{"url":"https://lain.bgm.tv/pic/1.png","filename":"`+api.CoverBaseName("A", "1")+`"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Filename  string `json:"filename"`
+		Reused    bool   `json:"reused"`
+		Refreshed bool   `json:"refreshed"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Filename != name || resp.Reused || !resp.Refreshed {
+		t.Fatalf("response = %+v, want refreshed %s", resp, name)
+	}
+	if got, _ := os.ReadFile(filepath.Join(h.coversDir, name)); string(got) != img {
+		t.Fatal("cover not overwritten")
+	}
+	if exists(thumb) {
+		t.Fatal("stale thumbnail kept after refresh")
+	}
+	if got := coverAddedEvents(h); len(got) != 1 || got[0] != name {
+		t.Fatalf("cover_added events = %v, want [%s]", got, name)
+	}
+	if st := h.bgm.DownloadStats(); st.Count != 1 {
+		t.Fatalf("download count = %d, want 1", st.Count)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// downloadedKey 生成已下载条目集合的键。
+func downloadedKey(source, id string) string {
+	return source + ":" + id
+}
+
+// downloadedSet 缓存已下载条目集合，避免每次搜索都读取全部旁路元数据。
+// 集合随递归封面列表一起失效：coverAdded、删除等写操作或目录变化使列表重扫后，下一次请求重新读取。
+type downloadedSet struct {
+	mu    sync.Mutex
+	valid bool
+	seq   uint64 // 建立集合时封面列表的扫描序号
+	set   map[string]bool
+}
+
+// downloadedSubjects 返回已下载条目的 source:id 集合（带缓存），由 covers 目录（含子目录）下各封面的旁路元数据得出。
+// 没有元数据或元数据中没有 ID 的封面无从对应条目，不计入。返回的集合在调用方之间共享，只能读取。
+func (h *handler) downloadedSubjects() map[string]bool {
+	names, seq, err := h.coverListing(true)
+	if err != nil {
+		slog.Warn("扫描封面目录失败，不排除已下载条目", "error", err)
+		return nil
+	}
+
+	d := &h.downloaded
+	d.mu.Lock()
+	if d.valid && d.seq == seq {
+		defer d.mu.Unlock()
+		return d.set
+	}
+	d.mu.Unlock()
+
+	out := make(map[string]bool, len(names))
+	for _, name := range names {
+		meta, err := api.ReadCoverMeta(h.coverPath(name))
+		if err != nil || meta.ID == "" {
+			continue
+		}
+		out[downloadedKey(meta.Source, meta.ID)] = true
+	}
+
+	d.mu.Lock()
+	d.valid, d.seq, d.set = true, seq, out
+	d.mu.Unlock()
+	return out
+}
+
+// excludeDownloadedSearch 从 Bangumi 搜索结果中剔除封面已下载的条目。
+func excludeDownloadedSearch(results []api.SearchResult, downloaded map[string]bool) []api.SearchResult {
+	out := results[:0]
+	for _, r := range results {
+		if !downloaded[downloadedKey(api.SourceBangumi, strconv.Itoa(r.ID))] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// excludeDownloadedBrowse 从 Bangumi 浏览结果（含按季度分组的副本）中剔除封面已下载的条目。
+// 剔除的条数计入 Excluded 并从 Total 中扣除；Total 不会小于已翻过的条数加本页条数。
+func excludeDownloadedBrowse(resp *api.BrowseResponse, downloaded map[string]bool) {
+	keep := func(items []api.BrowseResult) []api.BrowseResult {
+		out := items[:0]
+		for _, r := range items {
+			if !downloaded[downloadedKey(api.SourceBangumi, strconv.Itoa(r.ID))] {
+				out = append(out, r)
+			}
+		}
+		return out
+	}
+	before := len(resp.Results)
+	resp.Results = keep(resp.Results)
+	removed := before - len(resp.Results)
+	resp.Excluded += removed
+	resp.Total = max(resp.Total-removed, resp.Offset+len(resp.Results))
+	seasons := resp.Seasons[:0]
+	for _, g := range resp.Seasons {
+		if g.Items = keep(g.Items); len(g.Items) > 0 {
+			seasons = append(seasons, g)
+		}
+	}
+	resp.Seasons = seasons
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// writeTestMeta 覆盖封面的旁路元数据，使其对应 Bangumi 条目 id。
+func writeTestMeta(t *testing.T, imgPath, id string) {
+	t.Helper()
+	data := `{"source":"` + api.SourceBangumi + `","id":"` + id + `"}`
+	if err := os.WriteFile(api.CoverMetaPath(imgPath), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBrowseExcludeDownloadedAdjustsTotal(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	writeTestMeta(t, writeTestCover(t, h.coversDir, "one.png"), "1")
+	stubBangumi(h, func(*http.Request) string {
+		return `{"total":10,"data":[
+			{"id":1,"name":"a","summary":"s","rating":{"rank":1}},
+			{"id":2,"name":"b","summary":"s","rating":{"rank":2}},
+			{"id":3,"name":"c","summary":"s","rating":{"rank":3}}]}`
+	})
+
+	w := do(t, h, http.MethodPost, "/api/browse", `{"tags":["x"],"limit":3,"excludeDownloaded":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp api.BrowseResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].ID != 2 || resp.Results[1].ID != 3 {
+		t.Fatalf("results = %+v, want ids 2, 3", resp.Results)
+	}
+	if resp.Total != 9 || resp.Excluded != 1 {
+		t.Fatalf("total = %d, excluded = %d, want 9, 1", resp.Total, resp.Excluded)
+	}
+}
+
+func TestExcludeDownloadedBrowseTotalFloor(t *testing.T) {
+	resp := &api.BrowseResponse{
+		Results: []api.BrowseResult{{ID: 1}, {ID: 2}},
+		Total:   21, Offset: 20, Limit: 2,
+	}
+	excludeDownloadedBrowse(resp, map[string]bool{downloadedKey(api.SourceBangumi, "1"): true})
+	if resp.Total != 21 || resp.Excluded != 1 {
+		t.Fatalf("total = %d, excluded = %d, want 21, 1", resp.Total, resp.Excluded)
+	}
+}
+
+func TestDownloadedSubjectsCached(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	path := writeTestCover(t, h.coversDir, "one.png")
+	writeTestMeta(t, path, "1")
+
+	if got := h.downloadedSubjects(); !got["bgm:1"] {
+		t.Fatalf("downloaded = %v, want bgm:1", got)
+	}
+	// 只改写元数据内容不会改变目录 mtime，缓存应继续生效
+	writeTestMeta(t, path, "2")
+	if got := h.downloadedSubjects(); !got["bgm:1"] || got["bgm:2"] {
+		t.Fatalf("downloaded = %v, want cached bgm:1", got)
+	}
+	h.coverAdded("one.png")
+	if got := h.downloadedSubjects(); got["bgm:1"] || !got["bgm:2"] {
+		t.Fatalf("downloaded after coverAdded = %v, want bgm:2", got)
+	}
+}
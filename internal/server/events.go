@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 事件流参数。
+const (
+	eventHistorySize   = 256              // 保留用于断线重放的最近事件数
+	eventSubscriberBuf = 64               // 每个订阅者的发送缓冲
+	eventHeartbeat     = 25 * time.Second // 心跳间隔，防止代理断开空闲连接
+	eventRetryMillis   = 3000             // 建议客户端的重连间隔
+)
+
+// 事件类型。
+const (
+	eventCoverAdded   = "cover_added"
+	eventCoverDeleted = "cover_deleted"
+	eventStateSaved   = "state_saved"
+	eventResync       = "resync" // 重放窗口不足时通知客户端全量刷新
+)
+
+// event 是一条广播事件，ID 单调递增，对应 SSE 的 id 字段。
+type event struct {
+	ID   uint64
+	Type string
+	Data []byte
+}
+
+// eventHub 是进程内的发布/订阅中心。
+// 发布方从不阻塞：订阅者缓冲写满时直接断开该订阅者，客户端重连后凭 Last-Event-ID 补齐错过的事件。
+type eventHub struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []event // 环形缓冲，按 ID 升序
+	subs    map[chan event]struct{}
+	closed  bool
+}
+
+// newEventHub 创建事件中心。
+func newEventHub() *eventHub {
+	return &eventHub{
+		nextID: 1,
+		subs:   make(map[chan event]struct{}),
+	}
+}
+
+// publish 广播一条事件；data 序列化为 JSON。
+func (hub *eventHub) publish(typ string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		slog.Warn("事件序列化失败", "type", typ, "error", err)
+		return
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		return
+	}
+
+	ev := event{ID: hub.nextID, Type: typ, Data: payload}
+	hub.nextID++
+	hub.history = append(hub.history, ev)
+	if len(hub.history) > eventHistorySize {
+		hub.history = hub.history[len(hub.history)-eventHistorySize:]
+	}
+
+	for ch := range hub.subs {
+		select {
+		case ch <- ev:
+		default:
+			// 慢订阅者：断开而不是阻塞发布方
+			delete(hub.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribe 注册订阅者并返回 lastID 之后仍在重放窗口内的事件。
+// gap 为 true 表示部分事件已被挤出窗口，客户端需要全量刷新。
+func (hub *eventHub) subscribe(lastID uint64) (ch chan event, backlog []event, gap bool) {
+	ch = make(chan event, eventSubscriberBuf)
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if hub.closed {
+		close(ch)
+		return ch, nil, false
+	}
+	hub.subs[ch] = struct{}{}
+
+	if lastID == 0 {
+		return ch, nil, false
+	}
+	if lastID >= hub.nextID {
+		// 服务重启后 ID 重新计数，客户端持有的 ID 已无意义
+		return ch, nil, true
+	}
+	for _, ev := range hub.history {
+		if ev.ID > lastID {
+			backlog = append(backlog, ev)
+		}
+	}
+	oldest := hub.nextID
+	if len(hub.history) > 0 {
+		oldest = hub.history[0].ID
+	}
+	return ch, backlog, lastID+1 < oldest
+}
+
+// unsubscribe 注销订阅者；已被断开的订阅者忽略。
+func (hub *eventHub) unsubscribe(ch chan event) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if _, ok := hub.subs[ch]; ok {
+		delete(hub.subs, ch)
+		close(ch)
+	}
+}
+
+// close 断开全部订阅者并拒绝后续发布，用于优雅退出时结束长连接。
+func (hub *eventHub) close() {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.closed = true
+	for ch := range hub.subs {
+		delete(hub.subs, ch)
+		close(ch)
+	}
+}
+
+// handleEvents 以 SSE 推送封面库与状态变化（GET /api/events）。
+// 断线重连时浏览器会携带 Last-Event-ID，服务端补发错过的事件；无法补齐时先发送 resync。
+func (h *handler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	ch, backlog, gap := h.events.subscribe(lastID)
+	defer h.events.unsubscribe(ch)
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", eventRetryMillis)
+	if gap {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventResync)
+	}
+	for _, ev := range backlog {
+		writeEvent(w, ev)
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return // 被判定为慢订阅者或服务退出，客户端将自动重连
+			}
+			writeEvent(w, ev)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent 按 SSE 格式写出一条事件。
+func writeEvent(w http.ResponseWriter, ev event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// exifCacheSize 是最多缓存的 EXIF 结果数，超出时整体清空。
+const exifCacheSize = 512
+
+// coverEXIF 是 GET /api/cover/exif 的响应。Width/Height 是图片实际尺寸，
+// EXIF 为 false 时（非 JPEG 或没有 EXIF 段）其余拍摄信息字段省略。
+type coverEXIF struct {
+	File        string `json:"file"`
+	EXIF        bool   `json:"exif"`
+	Message     string `json:"message,omitempty"`
+	CaptureTime string `json:"captureTime,omitempty"`
+	Make        string `json:"make,omitempty"`
+	Model       string `json:"model,omitempty"`
+	Software    string `json:"software,omitempty"`
+	Orientation int    `json:"orientation,omitempty"`
+	Width       int    `json:"width,omitempty"`
+	Height      int    `json:"height,omitempty"`
+}
+
+// exifKey 标识一次 EXIF 读取；文件大小或修改时间变化后自然失效。
+type exifKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// exifCache 缓存 EXIF 读取结果。零值可直接使用。
+type exifCache struct {
+	mu      sync.Mutex
+	entries map[exifKey]coverEXIF
+}
+
+// get 返回缓存的结果。
+func (c *exifCache) get(k exifKey) (coverEXIF, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[k]
+	return v, ok
+}
+
+// put 写入结果；条目过多时清空重来。
+func (c *exifCache) put(k exifKey, v coverEXIF) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= exifCacheSize {
+		c.entries = make(map[exifKey]coverEXIF)
+	}
+	c.entries[k] = v
+}
+
+// handleCoverEXIF 返回封面的 EXIF 拍摄信息（GET /api/cover/exif?file=xxx）：拍摄时间、设备与尺寸。
+// 非 JPEG 或没有 EXIF 的图片返回 exif=false 与说明，仍附带图片尺寸。结果按文件名与修改时间缓存。
+func (h *handler) handleCoverEXIF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := r.URL.Query().Get("file")
+	rel, ok := coverRelPath(file)
+	if !ok || !isImageName(rel) {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "无效的封面文件名"})
+		return
+	}
+
+	path, _ := h.covers.find(rel)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "封面不存在"})
+			return
+		}
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	key := exifKey{path: path, size: info.Size(), modTime: info.ModTime()}
+	result, ok := h.exif.get(key)
+	if !ok {
+		width, height, err := imaging.Dimensions(path)
+		if err != nil {
+			h.writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		result = coverEXIF{Width: width, Height: height}
+		x, err := imaging.ReadEXIF(path)
+		switch {
+		case errors.Is(err, imaging.ErrNoEXIF):
+			result.Message = err.Error()
+		case err != nil:
+			result.Message = "EXIF 解析失败: " + err.Error()
+		default:
+			result.EXIF = true
+			result.CaptureTime = x.CaptureTime
+			result.Make, result.Model, result.Software = x.Make, x.Model, x.Software
+			result.Orientation = x.Orientation
+		}
+		h.exif.put(key, result)
+	}
+
+	result.File = file
+	h.writeJSON(w, http.StatusOK, result)
+}
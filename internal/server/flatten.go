@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// pngName 把文件名的扩展名替换为 .png。
+func pngName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".png"
+}
+
+// replaceCover 用 data 替换可写封面目录下的封面（rel 为 / 分隔的相对路径），返回替换后的相对路径。
+// 扩展名不变时原地覆盖；需要改为 ext 时另存为同名的新扩展名文件（重名时加序号），旁路元数据随之改名，原文件删除。
+func (h *handler) replaceCover(rel string, data []byte, ext string) (string, error) {
+	oldPath := filepath.Join(h.coversDir, filepath.FromSlash(rel))
+	h.removeThumbnails(filepath.FromSlash(rel))
+	if strings.EqualFold(filepath.Ext(oldPath), ext) {
+		return rel, api.WriteCoverFile(oldPath, data)
+	}
+
+	dir := filepath.Dir(oldPath)
+	base := filepath.Base(oldPath)
+	name := api.UniqueFilename(dir, strings.TrimSuffix(base, filepath.Ext(base))+ext)
+	newPath := filepath.Join(dir, name)
+	if err := api.WriteCoverFile(newPath, data); err != nil {
+		return "", err
+	}
+	if err := os.Rename(api.CoverMetaPath(oldPath), api.CoverMetaPath(newPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	if err := os.Remove(oldPath); err != nil {
+		return "", err
+	}
+	return path.Join(path.Dir(rel), name), nil
+}
+
+// flattenCover 把可写封面目录下已保存的动图替换为第一帧的静态 PNG。
+// 返回新的相对路径与文件大小；静态图片原样保留，flattened 为 false。
+func (h *handler) flattenCover(rel string) (newRel string, size int, flattened bool, err error) {
+	data, err := os.ReadFile(filepath.Join(h.coversDir, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", 0, false, err
+	}
+	out, flattened, err := imaging.Flatten(data)
+	if err != nil || !flattened {
+		return rel, len(data), false, err
+	}
+	newRel, err = h.replaceCover(rel, out, ".png")
+	if err != nil {
+		return "", 0, false, err
+	}
+	return newRel, len(out), true, nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+	"github.com/Aytrw/otaku-chart-maker/internal/config"
+)
+
+// newTestHandler 在临时目录中创建 handler，edit 可在创建前调整配置。测试结束时自动关闭。
+func newTestHandler(t *testing.T, edit func(*config.Config)) (*handler, string) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.Default()
+	if edit != nil {
+		edit(&cfg)
+	}
+	frontend := fstest.MapFS{"index.html": {Data: []byte("<html></html>")}}
+	h, _, err := NewHandler(dir, frontend, &cfg)
+	if err != nil {
+		t.Fatalf("NewHandler: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h.(*handler), dir
+}
+
+// do 向 handler 发送请求并返回响应。
+func do(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// roundTripFunc 把函数适配为 http.RoundTripper，用于在测试中替换上游。
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// stubResponse 构造上游响应。
+func stubResponse(r *http.Request, code int, contentType, body string) *http.Response {
+	return &http.Response{
+		StatusCode:    code,
+		Header:        http.Header{"Content-Type": {contentType}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// stubBangumiHTTP 把 h 的 Bangumi 客户端换成由 respond 应答的版本。
+func stubBangumiHTTP(h *handler, respond func(r *http.Request) *http.Response) {
+	h.bgm = api.NewClient(h.coversDir, api.WithCache(api.NewMemoryCache(10)),
+		api.WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return respond(r), nil
+		})))
+}
+
+// stubBangumi 与 stubBangumiHTTP 相同，respond 返回 200 的 JSON 响应体。
+func stubBangumi(h *handler, respond func(r *http.Request) string) {
+	stubBangumiHTTP(h, func(r *http.Request) *http.Response {
+		return stubResponse(r, http.StatusOK, "application/json", respond(r))
+	})
+}
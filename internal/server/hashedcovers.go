@@ -0,0 +1,138 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hashedCoversPrefix 是内容寻址封面 URL 的前缀：/covers-h/<sha256>/<封面相对路径>。
+// 文件内容变化后哈希随之改变，旧 URL 返回 404，因此可以让浏览器永久缓存。
+// 不放在 /covers/ 之下，以免遮住 covers 中名为 h 的子目录。
+const hashedCoversPrefix = "/covers-h/"
+
+// contentHashCacheSize 是最多缓存的封面哈希数，满后每加入一条随机淘汰一条。
+const contentHashCacheSize = 8192
+
+// sha256HexPattern 校验 URL 中的 SHA-256 十六进制摘要。
+var sha256HexPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// contentHashEntry 缓存单个封面的内容哈希，文件大小或修改时间变化后重新计算。
+type contentHashEntry struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// contentHashCache 是封面内容哈希的内存索引。零值可直接使用。
+type contentHashCache struct {
+	mu      sync.Mutex
+	entries map[string]contentHashEntry
+}
+
+// sum 返回 path 的 SHA-256 十六进制摘要，缓存有效时不重新读取文件。
+func (c *contentHashCache) sum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	e, ok := c.entries[path]
+	c.mu.Unlock()
+	if ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+		return e.sum, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	e = contentHashEntry{size: info.Size(), modTime: info.ModTime(), sum: hex.EncodeToString(h.Sum(nil))}
+
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]contentHashEntry)
+	}
+	if _, ok := c.entries[path]; !ok && len(c.entries) >= contentHashCacheSize {
+		for victim := range c.entries { // map 遍历起点随机，相当于随机淘汰一条
+			delete(c.entries, victim)
+			break
+		}
+	}
+	c.entries[path] = e
+	c.mu.Unlock()
+	return e.sum, nil
+}
+
+// hashedCoverURL 返回封面的内容寻址 URL；name 是以 / 分隔的相对路径，逐段转义。
+func hashedCoverURL(sum, name string) string {
+	segs := strings.Split(name, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.TrimPrefix(hashedCoversPrefix, "/") + sum + "/" + strings.Join(segs, "/")
+}
+
+// hashedCoverURLs 计算 names 的内容寻址 URL，无法读取的文件被跳过。
+func (h *handler) hashedCoverURLs(names []string) map[string]string {
+	out := make(map[string]string, len(names))
+	for _, name := range names {
+		if sum, err := h.hashes.sum(h.coverPath(name)); err == nil {
+			out[name] = hashedCoverURL(sum, name)
+		}
+	}
+	return out
+}
+
+// handleHashedCover 按内容哈希提供封面（GET /covers-h/<sha256>/<name>）。
+// 哈希与文件当前内容一致时返回图片并声明一年的 immutable 缓存，否则返回 404。
+func (h *handler) handleHashedCover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sum, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, hashedCoversPrefix), "/")
+	if !ok || !sha256HexPattern.MatchString(sum) {
+		http.NotFound(w, r)
+		return
+	}
+	rel, ok := coverRelPath(name)
+	if !ok || !isImageName(rel) {
+		http.NotFound(w, r)
+		return
+	}
+	path, _ := h.covers.find(rel)
+	actual, err := h.hashes.sum(path)
+	if err != nil || actual != sum {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", `"`+sum+`"`)
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashedCoversDoNotShadowSubdir(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	path := writeTestCover(t, h.coversDir, "h/a.png")
+
+	if w := do(t, h, http.MethodGet, "/covers/h/a.png", ""); w.Code != http.StatusOK || w.Body.String() != "img" {
+		t.Fatalf("GET /covers/h/a.png = %d %q", w.Code, w.Body)
+	}
+
+	sum, err := h.hashes.sum(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "/" + hashedCoverURL(sum, "h/a.png")
+	w := do(t, h, http.MethodGet, url, "")
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") == "" {
+		t.Fatalf("GET %s = %d, Cache-Control %q", url, w.Code, w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestContentHashCacheEvictsOne(t *testing.T) {
+	var c contentHashCache
+	c.entries = make(map[string]contentHashEntry, contentHashCacheSize)
+	for i := range contentHashCacheSize {
+		c.entries[fmt.Sprintf("fake-%d", i)] = contentHashEntry{}
+	}
+	path := filepath.Join(t.TempDir(), "a.png")
+	if err := os.WriteFile(path, []byte("img"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.sum(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.entries) != contentHashCacheSize {
+		t.Fatalf("entries = %d, want %d", len(c.entries), contentHashCacheSize)
+	}
+	if _, ok := c.entries[path]; !ok {
+		t.Fatal("new entry not cached")
+	}
+	// 已缓存的路径再次计算时不淘汰其他条目
+	if err := os.WriteFile(path, []byte("changed"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.sum(path); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.entries) != contentHashCacheSize {
+		t.Fatalf("entries after update = %d, want %d", len(c.entries), contentHashCacheSize)
+	}
+}
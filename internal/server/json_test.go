@@ -0,0 +1,54 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// discardWriter 是丢弃响应体的 http.ResponseWriter，基准测试中不计入记录器的缓冲。
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardWriter) WriteHeader(int)             {}
+
+// benchBrowseResponse 构造一页带简介的浏览结果，体积与 limit=100 的真实响应相当。
+func benchBrowseResponse(n int) *api.BrowseResponse {
+	resp := &api.BrowseResponse{Total: 5000, Limit: n}
+	for i := range n {
+		resp.Results = append(resp.Results, api.BrowseResult{
+			ID:        i + 1,
+			Name:      fmt.Sprintf("Subject %d", i+1),
+			NameCN:    fmt.Sprintf("条目 %d", i+1),
+			Cover:     fmt.Sprintf("https://lain.bgm.tv/pic/cover/l/%02d/%02d/%d.jpg", i%100, i%97, i+1),
+			TypeLabel: "动画",
+			Score:     7.5,
+			Rank:      i + 1,
+			Summary:   strings.Repeat("简介", 150),
+			Date:      "2020-01-01",
+		})
+	}
+	return resp
+}
+
+// BenchmarkWriteBrowseJSON 比较先 json.Marshal 再写出（writeJSON）与直接编码到连接（streamJSON）的分配。
+func BenchmarkWriteBrowseJSON(b *testing.B) {
+	h := &handler{}
+	resp := benchBrowseResponse(100)
+	b.Run("writeJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			h.writeJSON(&discardWriter{header: http.Header{}}, http.StatusOK, resp)
+		}
+	})
+	b.Run("streamJSON", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			h.streamJSON(&discardWriter{header: http.Header{}}, http.StatusOK, resp)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// chartArrayFields 是图表状态中与 cells 逐格对应的数组字段。
+var chartArrayFields = []string{"crops", "subjectIDs", "swapOffsets"}
+
+// chartReport 是单个图表的维护结果。
+type chartReport struct {
+	Name        string   `json:"name"`
+	Reformatted bool     `json:"reformatted"` // 内容按 -state-indent 重新格式化后有变化（预览时表示将会变化）
+	Problems    []string `json:"problems,omitempty"`
+	Error       string   `json:"error,omitempty"` // 无法读取或解析，未做任何改动
+}
+
+// maintenanceReport 是 POST /api/maintenance 的响应。
+type maintenanceReport struct {
+	Confirm bool          `json:"confirm"`
+	Charts  []chartReport `json:"charts"`
+	// Orphans 是没有被任何图表 cells 引用的封面；PruneSkipped 非空时说明未清理的原因。
+	Orphans      []string `json:"orphans"`
+	Pruned       []string `json:"pruned"`
+	PruneSkipped string   `json:"pruneSkipped,omitempty"`
+}
+
+// validateChartState 按前端保存的结构检查图表状态：顶层为对象，cells 为字符串或 null 组成的数组，
+// crops 等逐格字段为与 cells 等长的数组，_meta 格式正确，cells 引用的封面存在。
+func validateChartState(state any, covers map[string]bool) []string {
+	obj, ok := state.(map[string]any)
+	if !ok {
+		return []string{"顶层应为对象"}
+	}
+	var problems []string
+	if err := validateStateMeta(obj); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	cells, ok := obj["cells"].([]any)
+	if _, present := obj["cells"]; present && !ok {
+		return append(problems, "cells 应为数组")
+	}
+	for i, cell := range cells {
+		switch v := cell.(type) {
+		case nil:
+		case string:
+			if name, ok := coverNameFromURL(v); ok && !covers[name] {
+				problems = append(problems, fmt.Sprintf("cells[%d] 引用的封面不存在: %s", i, name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("cells[%d] 应为字符串或 null", i))
+		}
+	}
+	for _, field := range chartArrayFields {
+		v, present := obj[field]
+		if !present || v == nil {
+			continue
+		}
+		arr, ok := v.([]any)
+		if !ok {
+			problems = append(problems, field+" 应为数组")
+			continue
+		}
+		if len(arr) != len(cells) {
+			problems = append(problems, fmt.Sprintf("%s 长度 %d 与 cells 长度 %d 不一致", field, len(arr), len(cells)))
+		}
+	}
+	return problems
+}
+
+// maintainChart 在编辑锁内检查并（confirm 时）重新格式化单个图表，把引用到的封面计入 refs。
+func (h *handler) maintainChart(name string, confirm bool, covers map[string]bool, refs map[string]bool) chartReport {
+	report := chartReport{Name: name}
+	path, err := h.chartPath(name)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	h.editMu.Lock()
+	defer h.editMu.Unlock()
+
+	data, err := h.readChart(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			report.Error = err.Error()
+		}
+		return report
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return report
+	}
+	var state any
+	if err := json.Unmarshal(data, &state); err != nil {
+		report.Error = "不是合法 JSON: " + err.Error()
+		return report
+	}
+
+	report.Problems = validateChartState(state, covers)
+	if obj, ok := state.(map[string]any); ok {
+		cells, _ := obj["cells"].([]any)
+		for _, cell := range cells {
+			if s, ok := cell.(string); ok {
+				if cover, ok := coverNameFromURL(s); ok {
+					refs[cover] = true
+				}
+			}
+		}
+	}
+
+	formatted, err := h.marshalState(state)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Reformatted = !bytes.Equal(formatted, data)
+	if confirm && report.Reformatted {
+		h.states.Enqueue(path, formatted)
+		h.publishSnapshot(path, formatted)
+	}
+	return report
+}
+
+// handleMaintenance 对全部图表执行维护（POST /api/maintenance）：按 -state-indent 重新格式化、
+// 校验结构并报告问题；pruneOrphans 为 true 时还把未被任何图表引用的封面连同旁路元数据移入回收站。
+// 不带 confirm 时只预览，不写入任何文件。有图表无法解析时无从判断其引用，跳过清理封面。
+func (h *handler) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Confirm      bool `json:"confirm"`
+		PruneOrphans bool `json:"pruneOrphans"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+
+	names, err := h.chartNames()
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	files, err := h.listCovers(true)
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	covers := make(map[string]bool, len(files))
+	for _, f := range files {
+		covers[f] = true
+	}
+
+	report := maintenanceReport{Confirm: req.Confirm, Charts: []chartReport{}, Orphans: []string{}, Pruned: []string{}}
+	refs := map[string]bool{}
+	unreadable := false
+	for _, name := range names {
+		cr := h.maintainChart(name, req.Confirm, covers, refs)
+		if cr.Error != "" {
+			unreadable = true
+		}
+		if cr.Reformatted && req.Confirm {
+			h.events.publish(eventStateSaved, map[string]string{"chart": name})
+		}
+		report.Charts = append(report.Charts, cr)
+	}
+
+	for _, f := range files {
+		if !refs[f] {
+			report.Orphans = append(report.Orphans, f)
+		}
+	}
+	sort.Strings(report.Orphans)
+
+	switch {
+	case !req.PruneOrphans:
+	case unreadable:
+		report.PruneSkipped = "存在无法解析的图表，无法确定封面引用"
+	case req.Confirm:
+		for _, name := range report.Orphans {
+			rel := filepath.FromSlash(name)
+			if _, err := h.trash.move(h.coversDir, rel); err != nil {
+				continue // 只清理主 covers 目录，其他封面目录中的文件保持不变
+			}
+			h.removeThumbnails(rel)
+			report.Pruned = append(report.Pruned, name)
+		}
+		if len(report.Pruned) > 0 {
+			h.coverList.invalidate()
+			h.coverVersion.bump()
+			h.events.publish(eventCoverDeleted, map[string][]string{"filenames": report.Pruned})
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+func TestMaintenancePruneMovesSidecar(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	path := writeTestCover(t, h.coversDir, "orphan.png")
+
+	w := do(t, h, http.MethodPost, "/api/maintenance", `{"pruneOrphans":true,"confirm":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	if exists(path) || exists(api.CoverMetaPath(path)) {
+		t.Fatal("orphan cover or sidecar left in covers/")
+	}
+	items := h.trash.list()
+	if len(items) != 1 || items[0].Filename != "orphan.png" {
+		t.Fatalf("trash = %+v, want orphan.png", items)
+	}
+	trashed := filepath.Join(h.trash.dir, filepath.FromSlash(items[0].ID))
+	if _, err := os.Stat(api.CoverMetaPath(trashed)); err != nil {
+		t.Fatalf("sidecar not moved to trash: %v", err)
+	}
+}
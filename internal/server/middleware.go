@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// statusRecorder 记录处理器写出的状态码与字节数，供请求日志使用。
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap 让 http.ResponseController 能访问底层 ResponseWriter（如 Flush）。
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// logRequests 为每个请求输出一条结构化日志；封面静态文件请求量大，降为 Debug 级别。
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if (strings.HasPrefix(r.URL.Path, "/covers/") || strings.HasPrefix(r.URL.Path, hashedCoversPrefix)) && rec.status < 400 {
+			level = slog.LevelDebug
+		}
+		slog.Log(r.Context(), level, "request",
+			"request_id", requestIDFrom(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"bytes", rec.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+// readOnlyPOSTPaths 是只读模式下仍允许 POST 的路径：它们只查询上游或渲染导出，不写入本地数据。
+var readOnlyPOSTPaths = map[string]bool{
+	"/api/search":             true,
+	"/api/search-unified":     true,
+	"/api/browse":             true,
+	"/api/query":              true,
+	"/api/crosslink":          true,
+	"/api/subjects":           true,
+	"/api/recommend":          true, // autoDownload 由处理器单独拒绝
+	"/api/recommend/validate": true,
+	"/api/vndb/search":        true,
+	"/api/vndb/similar":       true,
+	"/api/anidb/search":       true,
+	"/api/render/pdf":         true,
+	"/api/render/pdf/stream":  true,
+}
+
+// readOnly 在只读模式下拒绝所有写操作：除 readOnlyPOSTPaths 外只放行 GET/HEAD，
+// 协同编辑连接虽以 GET 建立但会写入状态，同样拒绝。
+func (h *handler) readOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed := false
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			allowed = r.URL.Path != "/ws/state"
+		case http.MethodPost:
+			allowed = readOnlyPOSTPaths[r.URL.Path]
+		}
+		if !allowed {
+			h.writeJSON(w, http.StatusForbidden, map[string]string{"error": errReadOnly})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// errReadOnly 是只读模式下拒绝写操作的提示。
+const errReadOnly = "服务以只读模式运行，不允许修改"
+
+// authExemptPaths 是启用认证后仍可匿名访问的路径，供健康检查探针使用。
+var authExemptPaths = map[string]bool{
+	"/api/health": true,
+}
+
+// basicAuth 要求除 authExemptPaths 外的所有请求携带正确的 HTTP Basic Auth 凭据。
+// 比较前先对两侧做 SHA-256，使比较耗时与凭据长度无关。
+func basicAuth(next http.Handler, user, pass string) http.Handler {
+	wantUser := sha256.Sum256([]byte(user))
+	wantPass := sha256.Sum256([]byte(pass))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authExemptPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u, p, ok := r.BasicAuth()
+		gotUser := sha256.Sum256([]byte(u))
+		gotPass := sha256.Sum256([]byte(p))
+		userOK := subtle.ConstantTimeCompare(gotUser[:], wantUser[:])
+		passOK := subtle.ConstantTimeCompare(gotPass[:], wantPass[:])
+		if !ok || userOK&passOK != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="otaku-chart-maker", charset="UTF-8"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"image"
+	"net/http"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// phashEntry 缓存单个封面的感知哈希，文件大小或修改时间变化后重新计算。
+type phashEntry struct {
+	hash    uint64
+	size    int64
+	modTime time.Time
+}
+
+// phashIndex 是封面感知哈希的内存索引，按需增量计算。
+type phashIndex struct {
+	mu      sync.Mutex
+	entries map[string]phashEntry
+}
+
+// newPhashIndex 创建空索引。
+func newPhashIndex() *phashIndex {
+	return &phashIndex{entries: make(map[string]phashEntry)}
+}
+
+// hashes 返回 names 对应的哈希，缺失或过期的条目会重新解码计算；无法解码的文件被跳过。
+func (p *phashIndex) hashes(pathOf func(string) string, names []string) map[string]uint64 {
+	out := make(map[string]uint64, len(names))
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	live := make(map[string]bool, len(names))
+	for _, name := range names {
+		live[name] = true
+		path := pathOf(name)
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if e, ok := p.entries[name]; ok && e.size == info.Size() && e.modTime.Equal(info.ModTime()) {
+			out[name] = e.hash
+			continue
+		}
+		img, err := imaging.Load(path)
+		if err != nil {
+			continue
+		}
+		e := phashEntry{hash: imaging.DHash(img), size: info.Size(), modTime: info.ModTime()}
+		p.entries[name] = e
+		out[name] = e.hash
+	}
+
+	// 清理已不存在的文件，避免索引无限增长
+	for name := range p.entries {
+		if !live[name] {
+			delete(p.entries, name)
+		}
+	}
+	return out
+}
+
+// rebuild 丢弃缓存，用最多 workers 个协程重新计算 names 的哈希并整体替换索引，
+// 返回新的哈希表与无法解码而跳过的文件数。解码在锁外进行，重建期间的查询仍使用旧索引。
+func (p *phashIndex) rebuild(pathOf func(string) string, names []string, workers int) (map[string]uint64, int) {
+	entries := make(map[string]phashEntry, len(names))
+	var (
+		mu      sync.Mutex
+		skipped int
+		wg      sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for range max(workers, 1) {
+		wg.Go(func() {
+			for name := range jobs {
+				path := pathOf(name)
+				info, err := os.Stat(path)
+				var img image.Image
+				if err == nil {
+					img, err = imaging.Load(path)
+				}
+				mu.Lock()
+				if err != nil {
+					skipped++
+				} else {
+					entries[name] = phashEntry{hash: imaging.DHash(img), size: info.Size(), modTime: info.ModTime()}
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	for _, name := range names {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+
+	out := make(map[string]uint64, len(entries))
+	for name, e := range entries {
+		out[name] = e.hash
+	}
+	p.mu.Lock()
+	p.entries = entries
+	p.mu.Unlock()
+	return out, skipped
+}
+
+// clusters 将汉明距离不超过 threshold 的封面并查集聚类，只返回含两个及以上成员的簇。
+func clusters(hashes map[string]uint64, threshold int) [][]string {
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parent := make([]int, len(names))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := 0; i < len(names); i++ {
+		for j := i + 1; j < len(names); j++ {
+			if imaging.Distance(hashes[names[i]], hashes[names[j]]) <= threshold {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := map[int][]string{}
+	for i, name := range names {
+		root := find(i)
+		groups[root] = append(groups[root], name)
+	}
+	result := [][]string{}
+	for i := range names {
+		if g := groups[i]; len(g) > 1 {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// similarCovers 返回与 name 近似重复的其他封面；未启用感知哈希时返回 nil。
+func (h *handler) similarCovers(name string) []string {
+	if h.phash == nil {
+		return nil
+	}
+	names, err := h.listCovers(true)
+	if err != nil {
+		return nil
+	}
+	hashes := h.phash.hashes(h.coverPath, names)
+	target, ok := hashes[name]
+	if !ok {
+		return nil
+	}
+
+	var similar []string
+	for _, other := range names {
+		if other == name {
+			continue
+		}
+		if hash, ok := hashes[other]; ok && imaging.Distance(target, hash) <= h.cfg.PhashThreshold {
+			similar = append(similar, other)
+		}
+	}
+	return similar
+}
+
+// handleCoverDuplicates 返回视觉近似的封面簇（GET /api/covers/duplicates?threshold=N）。
+// 需以 -phash 启动；首次调用会解码全部封面，之后按修改时间增量更新。
+func (h *handler) handleCoverDuplicates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.phash == nil {
+		h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "未启用感知哈希去重（启动参数 -phash）"})
+		return
+	}
+
+	threshold := h.cfg.PhashThreshold
+	if v := r.URL.Query().Get("threshold"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 64 {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "threshold 需为 0~64 的整数"})
+			return
+		}
+		threshold = n
+	}
+
+	names, err := h.listCovers(true)
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	hashes := h.phash.hashes(h.coverPath, names)
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"threshold": threshold,
+		"clusters":  clusters(hashes, threshold),
+	})
+}
+
+// handleCoverReindex 重新扫描 covers 目录并重建感知哈希索引（POST /api/covers/reindex），
+// 用于修复在应用之外增删、替换封面文件后过期的索引。需以 -phash 启动。
+// 返回 indexed（成功计算哈希的文件数）、skipped（无法解码的文件数）、
+// duplicates（属于某个近似重复簇的文件数）与 clusters（簇数）。
+func (h *handler) handleCoverReindex(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.phash == nil {
+		h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "未启用感知哈希去重（启动参数 -phash）"})
+		return
+	}
+
+	// 目录外的改动不一定更新目录修改时间，强制重新扫描
+	h.coverList.invalidate()
+	names, err := h.listCovers(true)
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	hashes, skipped := h.phash.rebuild(h.coverPath, names, min(runtime.NumCPU(), 8))
+
+	groups := clusters(hashes, h.cfg.PhashThreshold)
+	duplicates := 0
+	for _, g := range groups {
+		duplicates += len(g)
+	}
+	h.writeJSON(w, http.StatusOK, map[string]int{
+		"indexed":    len(hashes),
+		"skipped":    skipped,
+		"duplicates": duplicates,
+		"clusters":   len(groups),
+	})
+}
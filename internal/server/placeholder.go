@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"net/http"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/render"
+)
+
+// 占位图参数。
+const (
+	placeholderDefaultW = 300
+	placeholderDefaultH = 400
+	placeholderMinSide  = 32
+	placeholderMaxSide  = 1024
+	placeholderMaxText  = 200 // 文字最多字符数，更长的截断
+	placeholderCache    = 256 // 最多缓存的占位图数，超出时整体清空
+)
+
+// placeholderKey 标识一张占位图。
+type placeholderKey struct {
+	text string
+	w, h int
+}
+
+// placeholderCacheStore 缓存编码后的占位图，同一标题的格子反复请求时无需重新绘制。零值可直接使用。
+type placeholderCacheStore struct {
+	mu      sync.Mutex
+	entries map[placeholderKey][]byte
+}
+
+// get 返回缓存的 PNG。
+func (c *placeholderCacheStore) get(k placeholderKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries[k]
+	return v, ok
+}
+
+// put 写入 PNG；条目过多时清空重来。
+func (c *placeholderCacheStore) put(k placeholderKey, v []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil || len(c.entries) >= placeholderCache {
+		c.entries = make(map[placeholderKey][]byte)
+	}
+	c.entries[k] = v
+}
+
+// parsePlaceholderSide 解析宽或高，缺省时使用 def。
+func parsePlaceholderSide(v string, def int) (int, bool) {
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < placeholderMinSide || n > placeholderMaxSide {
+		return 0, false
+	}
+	return n, true
+}
+
+// handlePlaceholder 生成无封面条目的占位图（GET /api/placeholder?text=标题&w=300&h=400），输出 PNG。
+// 标题使用渲染字体绘制，结果按参数缓存。
+func (h *handler) handlePlaceholder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	width, okW := parsePlaceholderSide(q.Get("w"), placeholderDefaultW)
+	height, okH := parsePlaceholderSide(q.Get("h"), placeholderDefaultH)
+	if !okW || !okH {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("w 与 h 须在 %d-%d 之间", placeholderMinSide, placeholderMaxSide),
+		})
+		return
+	}
+	text := q.Get("text")
+	if utf8.RuneCountInString(text) > placeholderMaxText {
+		text = string([]rune(text)[:placeholderMaxText])
+	}
+
+	key := placeholderKey{text: text, w: width, h: height}
+	data, ok := h.placeholders.get(key)
+	if !ok {
+		img, err := render.Placeholder(text, width, height, h.font)
+		if err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		data = buf.Bytes()
+		h.placeholders.put(key, data)
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
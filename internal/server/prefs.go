@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// prefsFileName 是服务端用户偏好的持久化文件，与 state.json 同目录。
+const prefsFileName = "prefs.json"
+
+// 已知的偏好键。浏览/搜索请求省略对应字段时以偏好为默认值。
+const (
+	prefBrowseSort   = "browseSort"   // 浏览排序（rank/score/heat/match）
+	prefBrowseLimit  = "browseLimit"  // 浏览每页条数（1~-max-browse-limit）
+	prefSearchType   = "searchType"   // 关键词搜索的 Bangumi 条目类型（1/2/4）
+	prefVNDBPageSize = "vndbPageSize" // VNDB 搜索每页条数（1~-vndb-max-results）
+	prefLanguage     = "language"     // 界面语言，仅供前端使用（zh/ja/en）
+)
+
+// prefLanguages 是 language 偏好允许的取值。
+var prefLanguages = map[string]bool{"zh": true, "ja": true, "en": true}
+
+// prefSearchTypes 是 searchType 偏好允许的取值：关键词搜索只支持书籍、动画与游戏。
+var prefSearchTypes = map[int]bool{1: true, 2: true, 4: true}
+
+// prefsStore 保存服务端的用户偏好，使设置跨设备共享。值只有字符串与整数两种。
+type prefsStore struct {
+	path   string
+	mu     sync.RWMutex
+	values map[string]any
+}
+
+// loadPrefs 读取已保存的偏好；文件缺失或无效时视为空，无效的单项丢弃。
+func loadPrefs(path string, validate func(key string, v any) (any, error)) *prefsStore {
+	p := &prefsStore{path: path, values: make(map[string]any)}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("读取偏好设置失败", "path", path, "error", err)
+		}
+		return p
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		slog.Warn("偏好设置文件无效，已忽略", "path", path, "error", err)
+		return p
+	}
+	for k, v := range raw {
+		if nv, err := validate(k, v); err == nil {
+			p.values[k] = nv
+		} else {
+			slog.Warn("忽略无效的偏好设置", "key", k, "error", err)
+		}
+	}
+	return p
+}
+
+// get 返回全部偏好的副本。
+func (p *prefsStore) get() map[string]any {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return maps.Clone(p.values)
+}
+
+// str 返回字符串偏好，未设置时返回空串。
+func (p *prefsStore) str(key string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s, _ := p.values[key].(string)
+	return s
+}
+
+// int 返回整数偏好，未设置时返回 0。
+func (p *prefsStore) int(key string) int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	n, _ := p.values[key].(int)
+	return n
+}
+
+// update 合并已校验的修改（值为 nil 表示删除该项）并持久化。
+func (p *prefsStore) update(changes map[string]any) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	next := maps.Clone(p.values)
+	for k, v := range changes {
+		if v == nil {
+			delete(next, k)
+		} else {
+			next[k] = v
+		}
+	}
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeChartFile(p.path, append(data, '\n')); err != nil {
+		return err
+	}
+	p.values = next
+	return nil
+}
+
+// validatePref 校验单项偏好并规范化取值（JSON 数字转为 int）。
+func (h *handler) validatePref(key string, v any) (any, error) {
+	intIn := func(lo, hi int) (any, error) {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) || int(f) < lo || int(f) > hi {
+			return nil, fmt.Errorf("%s 应为 %d~%d 的整数", key, lo, hi)
+		}
+		return int(f), nil
+	}
+	switch key {
+	case prefBrowseSort:
+		if s, ok := v.(string); ok && api.IsValidSort(s) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%s 应为 rank/score/heat/match 之一", key)
+	case prefBrowseLimit:
+		return intIn(1, h.cfg.MaxBrowseLimit)
+	case prefSearchType:
+		n, err := intIn(1, 4)
+		if err != nil || !prefSearchTypes[n.(int)] {
+			return nil, fmt.Errorf("%s 应为 1（书籍）、2（动画）或 4（游戏）", key)
+		}
+		return n, nil
+	case prefVNDBPageSize:
+		return intIn(1, h.cfg.VNDBMaxResults)
+	case prefLanguage:
+		if s, ok := v.(string); ok && prefLanguages[s] {
+			return s, nil
+		}
+		return nil, fmt.Errorf("%s 应为 zh/ja/en 之一", key)
+	}
+	return nil, fmt.Errorf("未知的偏好项: %s", key)
+}
+
+// handlePrefs 查看或修改服务端偏好（GET/POST /api/prefs）。
+// POST 请求体为部分键值，只修改给出的项，值为 null 时删除该项；任一项无效时整体拒绝。
+func (h *handler) handlePrefs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req map[string]any
+		if err := readJSON(r, &req); err != nil {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+			return
+		}
+		changes := make(map[string]any, len(req))
+		for k, v := range req {
+			if !isKnownPref(k) {
+				h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "未知的偏好项: " + k})
+				return
+			}
+			if v == nil {
+				changes[k] = nil
+				continue
+			}
+			nv, err := h.validatePref(k, v)
+			if err != nil {
+				h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			changes[k] = nv
+		}
+		if err := h.prefs.update(changes); err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "保存偏好设置失败: " + err.Error()})
+			return
+		}
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"prefs": h.prefs.get()})
+}
+
+// isKnownPref 报告 key 是否为已知的偏好项。
+func isKnownPref(key string) bool {
+	switch key {
+	case prefBrowseSort, prefBrowseLimit, prefSearchType, prefVNDBPageSize, prefLanguage:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,17 @@
+package server
+
+import "testing"
+
+func TestValidatePrefSearchType(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	for _, v := range []float64{1, 2, 4} {
+		if got, err := h.validatePref(prefSearchType, v); err != nil || got != int(v) {
+			t.Errorf("searchType %v = %v, %v", v, got, err)
+		}
+	}
+	for _, v := range []any{0.0, 3.0, 5.0, 6.0, 2.5, "2"} {
+		if _, err := h.validatePref(prefSearchType, v); err == nil {
+			t.Errorf("searchType %v accepted", v)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// prettyJSONIndent 是调试输出 JSON 时的缩进。
+const prettyJSONIndent = "  "
+
+// prettyWriter 标记本次请求的 JSON 响应需要缩进输出，由 writeJSON 等辅助函数识别。
+type prettyWriter struct {
+	http.ResponseWriter
+}
+
+// Unwrap 让 http.ResponseController 与 WebSocket 握手能访问底层 ResponseWriter。
+func (p *prettyWriter) Unwrap() http.ResponseWriter {
+	return p.ResponseWriter
+}
+
+// prettyJSON 在启用 -pretty 或请求带 ?pretty=1 时把 JSON 响应改为缩进输出，便于手工调试 API。
+// 需直接包在 mux 外层，处理器拿到的才是 prettyWriter。
+func prettyJSON(next http.Handler, always bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if always || queryBool(r, "pretty") {
+			w = &prettyWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// wantsPretty 报告 w 是否要求缩进输出 JSON。
+func wantsPretty(w http.ResponseWriter) bool {
+	_, ok := w.(*prettyWriter)
+	return ok
+}
+
+// indentJSON 返回 data 的缩进形式；data 不是合法 JSON 时原样返回。
+func indentJSON(data []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", prettyJSONIndent); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+func TestQueryExcludeTags(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	stubBangumi(h, func(*http.Request) string {
+		return `{"total":10,"data":[
+			{"id":1,"name":"a","summary":"s","rating":{"rank":1},"tags":[{"name":"Horror"}]},
+			{"id":2,"name":"b","summary":"s","rating":{"rank":2},"tags":[{"name":"school"}]},
+			{"id":3,"name":"c","summary":"s","rating":{"rank":3}}]}`
+	})
+
+	w := do(t, h, http.MethodPost, "/api/query", `{"tags":["school"],"excludeTags":["horror"],"limit":3}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var res api.QueryResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Backend != "v0" {
+		t.Fatalf("backend = %q, want v0", res.Backend)
+	}
+	if len(res.Results) != 2 || res.Results[0].ID != "2" || res.Results[1].ID != "3" {
+		t.Fatalf("results = %+v, want ids 2, 3", res.Results)
+	}
+	if res.Total != 9 || res.Excluded != 1 {
+		t.Fatalf("total = %d, excluded = %d, want 9, 1", res.Total, res.Excluded)
+	}
+}
+
+func TestQueryRejectsConflictingTags(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	w := do(t, h, http.MethodPost, "/api/query", `{"tags":["School"],"excludeTags":["school"]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// testPNG 返回一张可解码的 PNG。
+func testPNG(t *testing.T) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// coverAddedEvents 返回已广播的 cover_added 事件中的文件名。
+func coverAddedEvents(h *handler) []string {
+	h.events.mu.Lock()
+	defer h.events.mu.Unlock()
+	var names []string
+	for _, ev := range h.events.history {
+		if ev.Type != eventCoverAdded {
+			continue
+		}
+		var data struct{ Filename string }
+		_ = json.Unmarshal(ev.Data, &data)
+		names = append(names, data.Filename)
+	}
+	return names
+}
+
+func TestRecommendAutoDownloadSkipsReusedCovers(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	writeTestCover(t, h.coversDir, api.CoverBaseName("A", "1")+".png")
+	img := testPNG(t)
+	stubBangumiHTTP(h, func(r *http.Request) *http.Response {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".png"):
+			if r.Header.Get("If-Modified-Since") != "" {
+				return stubResponse(r, http.StatusNotModified, "image/png", "")
+			}
+			return stubResponse(r, http.StatusOK, "image/png", img)
+		default:
+			return stubResponse(r, http.StatusOK, "application/json", `{"total":2,"data":[
+				{"id":1,"name":"A","summary":"s","rating":{"rank":1},"images":{"common":"https://lain.bgm.tv/pic/1.png"}},
+				{"id":2,"name":"B","summary":"s","rating":{"rank":2},"images":{"common":"https://lain.bgm.tv/pic/2.png"}}]}`)
+		}
+	})
+
+	w := do(t, h, http.MethodPost, "/api/recommend",
+		`{"cells":[{"label":"a","tags":["x"]},{"label":"b","tags":["x"]}],"autoDownload":true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp api.RecommendResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 2 {
+		t.Fatalf("results = %+v", resp.Results)
+	}
+	var added []string
+	for _, res := range resp.Results {
+		if res.Item == nil || res.Path == "" {
+			t.Fatalf("cell %q not downloaded: %+v", res.Label, res)
+		}
+		if reused := res.Item.ID == 1; res.Reused != reused {
+			t.Errorf("cell %q reused = %v, want %v", res.Label, res.Reused, reused)
+		}
+		if !res.Reused {
+			added = append(added, res.Filename)
+		}
+	}
+	if got := coverAddedEvents(h); len(got) != 1 || got[0] != added[0] {
+		t.Fatalf("cover_added events = %v, want %v", got, added)
+	}
+}
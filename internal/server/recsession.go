@@ -0,0 +1,94 @@
+package server
+
+import (
+	"regexp"
+	"sync"
+	"time"
+)
+
+// 推荐会话参数。
+const (
+	recSessionTTL    = 30 * time.Minute // 会话无活动超过此时长后过期
+	recSessionMax    = 1000             // 同时保留的会话数上限，超出时淘汰最久未用的
+	recSessionMaxIDs = 5000             // 单个会话记录的条目 ID 上限
+)
+
+// recSessionIDPattern 限制客户端提供的会话 ID 字符集与长度。
+var recSessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// recSession 是一个推荐会话已返回过的条目 ID。
+type recSession struct {
+	ids  map[int]bool
+	last time.Time
+}
+
+// recSessionStore 按客户端提供的会话 ID 累积智能推荐返回过的条目，
+// 同一会话内后续的"换一个"自动排除已推荐过的作品。零值可直接使用。
+type recSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*recSession
+}
+
+// excluded 返回会话已记录的 ID，并刷新会话的活动时间；会话不存在时返回 nil。
+func (s *recSessionStore) excluded(id string) []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.expireLocked(now)
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil
+	}
+	sess.last = now
+	out := make([]int, 0, len(sess.ids))
+	for v := range sess.ids {
+		out = append(out, v)
+	}
+	return out
+}
+
+// add 把本次推荐返回的 ID 计入会话，按需创建会话；达到 ID 上限后不再记录新 ID。
+func (s *recSessionStore) add(id string, ids []int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	sess, ok := s.sessions[id]
+	if !ok {
+		if s.sessions == nil {
+			s.sessions = make(map[string]*recSession)
+		}
+		if len(s.sessions) >= recSessionMax {
+			s.evictOldestLocked()
+		}
+		sess = &recSession{ids: make(map[int]bool)}
+		s.sessions[id] = sess
+	}
+	sess.last = now
+	for _, v := range ids {
+		if len(sess.ids) >= recSessionMaxIDs {
+			break
+		}
+		sess.ids[v] = true
+	}
+}
+
+// expireLocked 删除无活动超过 recSessionTTL 的会话，调用方需持有 s.mu。
+func (s *recSessionStore) expireLocked(now time.Time) {
+	for id, sess := range s.sessions {
+		if now.Sub(sess.last) > recSessionTTL {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+// evictOldestLocked 淘汰最久未用的会话，调用方需持有 s.mu。
+func (s *recSessionStore) evictOldestLocked() {
+	var oldest string
+	var oldestAt time.Time
+	for id, sess := range s.sessions {
+		if oldest == "" || sess.last.Before(oldestAt) {
+			oldest, oldestAt = id, sess.last
+		}
+	}
+	delete(s.sessions, oldest)
+}
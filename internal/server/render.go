@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/render"
+)
+
+// renderRequest 是服务端渲染接口的请求体。
+// cover 可以是 state 中的 "covers/..." URL，也可以是 covers 目录下的相对文件名。
+// font 为 "latin" 时强制使用内置 Latin 字体，否则使用启动时选定的字体。
+// cellAspect 与 fit 控制封面区域比例与填充方式，使海报与方形图标都能按前端布局输出。
+type renderRequest struct {
+	Title      string  `json:"title"`
+	Columns    int     `json:"columns"`
+	Font       string  `json:"font"`
+	CellAspect float64 `json:"cellAspect"` // 封面区域宽高比（宽/高），如 0.75 为海报、1 为方形；0 使用默认 3:4
+	Fit        string  `json:"fit"`        // cover 裁切铺满，contain（默认）完整显示并留白
+	Cells      []struct {
+		Label string `json:"label"`
+		Cover string `json:"cover"`
+	} `json:"cells"`
+}
+
+// loadRenderFont 加载 -font 指定的字体；未指定时探测系统 CJK 字体，再退到内嵌子集字体，均失败则回退 Latin 字体。
+func loadRenderFont(path string) *render.Font {
+	if path != "" {
+		font, err := render.LoadFont(path)
+		if err == nil {
+			return font
+		}
+		slog.Warn("加载渲染字体失败，回退到内置 Latin 字体", "font", path, "error", err)
+		return render.LatinFont()
+	}
+	if font := render.DetectCJKFont(); font != nil {
+		return font
+	}
+	slog.Debug("未找到系统或内嵌的 CJK 字体，渲染使用内置 Latin 字体")
+	return render.LatinFont()
+}
+
+// toChart 将请求映射为渲染用的图表，封面引用解析为 covers 目录内的绝对路径。
+func (h *handler) toChart(req renderRequest) render.Chart {
+	chart := render.Chart{Title: req.Title, Columns: req.Columns, CellAspect: req.CellAspect, Fit: req.Fit}
+	for _, c := range req.Cells {
+		cell := render.Cell{Label: c.Label}
+		if path, ok := h.resolveCoverPath(c.Cover); ok {
+			cell.ImagePath = path
+		}
+		chart.Cells = append(chart.Cells, cell)
+	}
+	return chart
+}
+
+// resolveCoverPath 把封面引用解析为封面目录内的文件路径，拒绝逃逸到目录之外的路径。
+// 文件存在于多个目录时取优先级最高的；都不存在时返回可写目录中的路径。
+func (h *handler) resolveCoverPath(ref string) (string, bool) {
+	rel, ok := coverRelPath(ref)
+	if !ok {
+		return "", false
+	}
+	path, _ := h.covers.find(rel)
+	return path, true
+}
+
+// coverRelPath 把封面引用（covers/ URL 或相对路径）解析为规范的相对路径（系统分隔符）。
+func coverRelPath(ref string) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return "", false
+	}
+	name := ref
+	if parsed, ok := coverNameFromURL(ref); ok {
+		name = parsed
+	}
+
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return clean, true
+}
+
+// handleRenderPDF 将网格排版为 A4 PDF 返回（POST /api/render/pdf）。
+// 图表参数不合法（见 render.Chart.Validate）时返回 400，排版过程中的其他失败返回 500。
+func (h *handler) handleRenderPDF(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req renderRequest
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+
+	chart := h.toChart(req)
+	if err := chart.Validate(); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	font := h.font
+	if strings.EqualFold(req.Font, "latin") {
+		font = render.LatinFont()
+	}
+
+	var buf bytes.Buffer
+	res, err := render.PDF(&buf, chart, font)
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	setRenderHeaders(w, res)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="chart.pdf"`)
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// setRenderHeaders 通过响应头报告渲染使用的字体与画布尺寸（mm）：
+// X-Render-Canvas 为页面宽x高，X-Render-Cell 为封面区域宽x高，X-Render-Pages 为页数。
+func setRenderHeaders(w http.ResponseWriter, res render.Result) {
+	w.Header().Set("X-Render-Font", res.Font)
+	w.Header().Set("X-Render-Canvas", fmt.Sprintf("%gx%g", res.PageWidth, res.PageHeight))
+	w.Header().Set("X-Render-Cell", fmt.Sprintf("%gx%g", res.CellWidth, res.CoverHeight))
+	w.Header().Set("X-Render-Pages", strconv.Itoa(res.Pages))
+}
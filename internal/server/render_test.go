@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRenderPDFStatus(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"valid", `{"cells":[{"label":"a"}]}`, http.StatusOK},
+		{"no cells", `{"cells":[]}`, http.StatusBadRequest},
+		{"too many columns", `{"columns":13,"cells":[{"label":"a"}]}`, http.StatusBadRequest},
+		{"bad fit", `{"fit":"stretch","cells":[{"label":"a"}]}`, http.StatusBadRequest},
+		{"bad json", `{`, http.StatusBadRequest},
+		{"too many cells", `{"cells":[` + strings.Repeat(`{"label":"a"},`, 500) + `{"label":"a"}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(t, h, http.MethodPost, "/api/render/pdf", tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestRenderPDFStreamRejectsTooManyCells(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	body := `{"cells":[` + strings.Repeat(`{},`, 500) + `{}]}`
+	rec := do(t, h, http.MethodPost, "/api/render/pdf/stream", body)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+	}
+}
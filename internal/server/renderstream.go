@@ -0,0 +1,244 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/render"
+)
+
+// renderResultTTL 是渐进渲染产物在临时目录中的保留时长，过期后下载链接返回 404。
+const renderResultTTL = 10 * time.Minute
+
+// renderDirPrefix 是渲染临时目录名的前缀，位于系统临时目录下。
+const renderDirPrefix = "otaku-chart-render-"
+
+// renderResultPrefix 是渲染产物下载地址的前缀：/api/render/result/<id>。
+const renderResultPrefix = "/api/render/result/"
+
+// renderResultIDPattern 校验下载地址中的产物 ID。
+var renderResultIDPattern = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+// renderResult 是一份已生成的渲染产物。
+type renderResult struct {
+	path     string
+	filename string // 下载时的文件名
+	result   render.Result
+}
+
+// renderResultStore 把渐进渲染的产物保存在系统临时目录中，每份在 renderResultTTL 后删除。零值可直接使用。
+type renderResultStore struct {
+	mu      sync.Mutex
+	dir     string // 首次保存时创建
+	results map[string]renderResult
+	closed  bool
+	ttl     time.Duration // 产物保留时长，0 表示 renderResultTTL
+}
+
+// lifetime 返回产物的保留时长。
+func (s *renderResultStore) lifetime() time.Duration {
+	return cmp.Or(s.ttl, renderResultTTL)
+}
+
+// save 写入产物并返回 ID，到期自动删除。
+func (s *renderResultStore) save(data []byte, filename string, result render.Result) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return "", fmt.Errorf("服务正在退出")
+	}
+	if s.dir == "" {
+		sweepStaleRenderDirs(os.TempDir(), time.Now().Add(-renderResultTTL))
+		dir, err := os.MkdirTemp("", renderDirPrefix)
+		if err != nil {
+			return "", fmt.Errorf("创建渲染临时目录失败: %w", err)
+		}
+		s.dir = dir
+		s.results = make(map[string]renderResult)
+	}
+	// 长时间没有产物时目录可能已被另一个实例当作遗留目录清理，写入前确保存在
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return "", fmt.Errorf("创建渲染临时目录失败: %w", err)
+	}
+
+	id := newRequestID()
+	path := filepath.Join(s.dir, id+filepath.Ext(filename))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("保存渲染结果失败: %w", err)
+	}
+	s.results[id] = renderResult{path: path, filename: filename, result: result}
+	time.AfterFunc(s.lifetime(), func() { s.remove(id) })
+	return id, nil
+}
+
+// sweepStaleRenderDirs 删除 tmp 下修改时间早于 before 的渲染临时目录。进程被强制结束时来不及执行 close，
+// 遗留的目录在下次首次保存产物时清理；目录中的产物最迟在 renderResultTTL 后删除并更新目录时间，
+// 超过该时长未修改的目录不会再有有效产物。
+func sweepStaleRenderDirs(tmp string, before time.Time) {
+	matches, err := filepath.Glob(filepath.Join(tmp, renderDirPrefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, dir := range matches {
+		info, err := os.Lstat(dir)
+		if err != nil || !info.IsDir() || info.ModTime().After(before) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("删除遗留的渲染临时目录失败", "dir", dir, "error", err)
+			continue
+		}
+		slog.Debug("已删除遗留的渲染临时目录", "dir", dir)
+	}
+}
+
+// get 返回未过期的产物。
+func (s *renderResultStore) get(id string) (renderResult, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.results[id]
+	return res, ok
+}
+
+// remove 删除一份产物。
+func (s *renderResultStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.results[id]
+	if !ok {
+		return
+	}
+	delete(s.results, id)
+	if err := os.Remove(res.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("删除渲染临时文件失败", "path", res.path, "error", err)
+	}
+}
+
+// close 删除临时目录及其中全部产物，之后不再接受新的产物。
+func (s *renderResultStore) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	clear(s.results)
+	if s.dir != "" {
+		_ = os.RemoveAll(s.dir)
+	}
+}
+
+// writeSSE 按 SSE 格式写出一条 JSON 数据的事件并立即刷新。
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, name string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, b)
+	return rc.Flush()
+}
+
+// handleRenderPDFStream 渐进渲染 PDF（POST /api/render/pdf/stream），请求体与 /api/render/pdf 相同。
+// 响应为 SSE：每合成一个格子推送 progress（done/total/message），完成后推送 done，
+// 其中 url 指向临时保存的 PDF，renderResultTTL 内有效，canvas 为画布尺寸；失败时推送 error。
+func (h *handler) handleRenderPDFStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req renderRequest
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	chart := h.toChart(req)
+	if err := chart.Validate(); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	font := h.font
+	if strings.EqualFold(req.Font, "latin") {
+		font = render.LatinFont()
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// 客户端断开后继续完成渲染，只是不再推送进度
+	var buf bytes.Buffer
+	result, err := render.PDFWithProgress(&buf, chart, font, func(done, total int) {
+		if r.Context().Err() != nil {
+			return
+		}
+		_ = writeSSE(w, rc, "progress", map[string]any{
+			"done":    done,
+			"total":   total,
+			"message": fmt.Sprintf("rendering cell %d/%d", done, total),
+		})
+	})
+	if err != nil {
+		_ = writeSSE(w, rc, "error", map[string]string{"error": err.Error()})
+		return
+	}
+
+	id, err := h.renders.save(buf.Bytes(), "chart.pdf", result)
+	if err != nil {
+		_ = writeSSE(w, rc, "error", map[string]string{"error": err.Error()})
+		return
+	}
+	_ = writeSSE(w, rc, "done", map[string]any{
+		"url":       strings.TrimPrefix(renderResultPrefix, "/") + id,
+		"font":      result.Font,
+		"canvas":    result,
+		"size":      buf.Len(),
+		"expiresAt": time.Now().Add(h.renders.lifetime()).UTC().Format(time.RFC3339),
+	})
+}
+
+// handleRenderResult 下载渐进渲染的产物（GET /api/render/result/<id>），过期或不存在时返回 404。
+func (h *handler) handleRenderResult(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, renderResultPrefix)
+	if !renderResultIDPattern.MatchString(id) {
+		http.NotFound(w, r)
+		return
+	}
+	res, ok := h.renders.get(id)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(res.path)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	setRenderHeaders(w, res.result)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", res.filename))
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeContent(w, r, res.filename, info.ModTime(), f)
+}
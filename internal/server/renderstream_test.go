@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/render"
+)
+
+// sseEvent 是解析出的一条 SSE 事件。
+type sseEvent struct {
+	name string
+	data string
+}
+
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var cur sseEvent
+	sc := bufio.NewScanner(strings.NewReader(body))
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			cur.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.data = strings.TrimPrefix(line, "data: ")
+		case line == "" && cur.name != "":
+			events = append(events, cur)
+			cur = sseEvent{}
+		}
+	}
+	return events
+}
+
+func TestRenderPDFStreamSequence(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	h.renders.ttl = 100 * time.Millisecond
+
+	rec := do(t, h, http.MethodPost, "/api/render/pdf/stream", `{"cells":[{"label":"a"},{"label":"b"},{"label":"c"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	events := parseSSE(t, rec.Body.String())
+	if len(events) != 4 {
+		t.Fatalf("got %d events, want 3 progress + done: %+v", len(events), events)
+	}
+	for i, ev := range events[:3] {
+		var p struct{ Done, Total int }
+		if ev.name != "progress" || json.Unmarshal([]byte(ev.data), &p) != nil || p.Done != i+1 || p.Total != 3 {
+			t.Fatalf("event %d = %+v, want progress %d/3", i, ev, i+1)
+		}
+	}
+	var done struct {
+		URL string `json:"url"`
+	}
+	if events[3].name != "done" || json.Unmarshal([]byte(events[3].data), &done) != nil {
+		t.Fatalf("last event = %+v, want done", events[3])
+	}
+	if !strings.HasPrefix("/"+done.URL, renderResultPrefix) {
+		t.Fatalf("done url = %q", done.URL)
+	}
+
+	res := do(t, h, http.MethodGet, "/"+done.URL, "")
+	if res.Code != http.StatusOK || !strings.HasPrefix(res.Body.String(), "%PDF") {
+		t.Fatalf("fetch result: status %d, body %.20q", res.Code, res.Body)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if do(t, h, http.MethodGet, "/"+done.URL, "").Code == http.StatusNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("result still served after ttl")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRenderResultStoreSweepsStaleDirs(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	old := time.Now().Add(-2 * renderResultTTL)
+	mkdir := func(name string, mod time.Time) string {
+		dir := filepath.Join(tmp, name)
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+	stale := mkdir(renderDirPrefix+"stale", old)
+	fresh := mkdir(renderDirPrefix+"fresh", time.Now())
+	other := mkdir("unrelated", old)
+
+	var s renderResultStore
+	defer s.close()
+	if _, err := s.save([]byte("x"), "chart.pdf", render.Result{}); err != nil {
+		t.Fatal(err)
+	}
+	if exists(stale) {
+		t.Error("stale render dir survived the sweep")
+	}
+	if !exists(fresh) || !exists(other) {
+		t.Error("sweep removed a fresh or unrelated dir")
+	}
+}
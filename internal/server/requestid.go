@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"maps"
+	"net/http"
+	"regexp"
+)
+
+// requestIDHeader 是携带请求 ID 的请求/响应头。
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern 限制沿用的上游代理请求 ID 的字符集与长度，不符合时重新生成。
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestIDKey 是请求 ID 在 context 中的键。
+type requestIDKey struct{}
+
+// newRequestID 生成 12 位十六进制的短请求 ID。
+func newRequestID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// requestIDFrom 返回 context 中的请求 ID，没有时返回空串。
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID 为每个请求分配 ID：写入 context 与 X-Request-ID 响应头，供日志与错误响应关联。
+// 请求已带合法的 X-Request-ID（如反向代理生成）时沿用。需包在 logRequests 外层。
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// logAPIError 连同请求 ID 记录上游调用失败。5xx 表示上游或本服务异常，记为 Warn；
+// 4xx（参数错误、条目不存在、被限流）是正常的业务结果，记为 Debug，避免缺失封面等常见情况刷屏。
+func logAPIError(w http.ResponseWriter, code int, err error) {
+	level := slog.LevelDebug
+	if code >= 500 {
+		level = slog.LevelWarn
+	}
+	slog.Log(context.Background(), level, "上游请求失败", "request_id", w.Header().Get(requestIDHeader), "status", code, "error", err)
+}
+
+// withErrorRequestID 为错误响应的 {"error": ...} 对象附加 requestId（取自响应头），其他响应原样返回。
+// 返回副本，不修改调用方的映射。
+func withErrorRequestID(w http.ResponseWriter, code int, data any) any {
+	id := w.Header().Get(requestIDHeader)
+	if code < 400 || id == "" {
+		return data
+	}
+	switch m := data.(type) {
+	case map[string]string:
+		if _, ok := m["error"]; ok {
+			m = maps.Clone(m)
+			m["requestId"] = id
+			return m
+		}
+	case map[string]any:
+		if _, ok := m["error"]; ok {
+			m = maps.Clone(m)
+			m["requestId"] = id
+			return m
+		}
+	}
+	return data
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+func TestAPIErrorLogLevel(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	tests := []struct {
+		err  error
+		code int
+		want string
+	}{
+		{api.ErrBadRequest, 400, "level=DEBUG"},
+		{api.ErrNotFound, 404, "level=DEBUG"},
+		{api.ErrOverloaded, 503, "level=WARN"},
+		{errors.New("boom"), 502, "level=WARN"},
+	}
+	for _, tt := range tests {
+		buf.Reset()
+		rec := httptest.NewRecorder()
+		rec.Header().Set(requestIDHeader, "abc")
+		code, _ := apiErrorStatus(rec, tt.err)
+		if code != tt.code {
+			t.Errorf("%v: status = %d, want %d", tt.err, code, tt.code)
+		}
+		line := buf.String()
+		if !strings.Contains(line, tt.want) || !strings.Contains(line, "request_id=abc") {
+			t.Errorf("%v: log = %q, want %s with request_id", tt.err, line, tt.want)
+		}
+	}
+}
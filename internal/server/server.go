@@ -1,24 +1,40 @@
 package server
 
 import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	"sort"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Aytrw/otaku-chart-maker/internal/api"
+	"github.com/Aytrw/otaku-chart-maker/internal/config"
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+	"github.com/Aytrw/otaku-chart-maker/internal/render"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
 	stateFileName = "state.json"
 	coversDirName = "covers"
+	chartsDirName = "charts"
+	thumbsDirName = "thumbs"
+
+	anidbTitlesFileName = "anidb-titles.xml.gz" // AniDB 标题列表的本地副本，与 covers/ 平级，不对外提供
 )
 
 // imageExts 定义 /api/covers 可返回的图片后缀。
@@ -31,33 +47,116 @@ var imageExts = map[string]struct{}{
 	".gif":  {},
 }
 
+// Handler 是服务入口；Close 会落盘尚未写入的状态并停止后台任务。
+// CloseStreams 断开 SSE 等长连接，应在 http.Server 关闭时调用，否则 Shutdown 会等待其超时。
+type Handler interface {
+	http.Handler
+	CloseStreams()
+	Close() error
+}
+
 // handler 聚合前端文件、状态文件、API 客户端和路由分发所需资源。
 type handler struct {
-	frontend  fs.FS
-	coversDir string
-	stateFile string
-	bgm       *api.Client
-	vndb      *api.VNDBClient
-	mux       *http.ServeMux
-	stateMu   sync.RWMutex
+	frontend     fs.FS
+	coversDir    string    // 可写封面目录，下载与上传都写入这里
+	covers       coverDirs // 封面查找目录（图库目录 + coversDir），读取与列表合并全部目录
+	stateFile    string
+	bgmTokenFile string // Bangumi Access Token 的保存位置
+	chartsDir    string
+	thumbsDir    string
+	bgm          *api.Client
+	upstream     *api.UpstreamLimiter // 上游并发请求限制，未启用时为 nil
+	vndb         *api.VNDBClient
+	prefs        *prefsStore // 服务端保存的用户偏好，作为浏览/搜索请求的默认值
+	anidb        *api.AniDBClient
+	mux          *http.ServeMux
+	root         http.Handler // mux 外层包裹中间件后的入口
+	stateMu      sync.RWMutex
+	editMu       sync.Mutex     // 串行化状态的读-改-写（整体保存与增量补丁）
+	states       *stateWriter   // 合并高频保存的状态写入队列
+	events       *eventHub      // 封面库与状态变化的广播中心
+	collab       *collabHub     // 图表协同编辑连接
+	backups      *backupStore   // 图表落盘前的带时间戳备份
+	archiver     *coverArchiver // 定时 zip 归档，未启用时为 nil
+	trash        *trashStore    // 删除封面的回收站
+	coverList    coverListCache
+	downloaded   downloadedSet
+	coverVersion coverVersion          // 封面库修改代数，供前端轮询判断是否需要刷新
+	dims         dimIndex              // 封面尺寸索引，供列表按尺寸筛选
+	colors       colorCache            // 封面主色提取结果
+	exif         exifCache             // 封面 EXIF 读取结果
+	hashes       contentHashCache      // 封面内容哈希，用于内容寻址 URL
+	recSessions  recSessionStore       // 智能推荐会话已返回的条目
+	placeholders placeholderCacheStore // 无封面条目的占位图
+	renders      renderResultStore     // 渐进渲染的临时产物
+	coverOrder   *coverOrder           // 用户保存的封面显示顺序
+	cfg          *config.Config
+	font         *render.Font // 服务端渲染标签字体
+	phash        *phashIndex  // 感知哈希索引，未启用时为 nil
+
+	thumbGroup singleflight.Group // 合并同一缩略图的并发生成
+	pregen     *thumbPregen       // 缩略图后台预生成，未启用时为 nil
 }
 
 // NewHandler 初始化目录、状态文件和路由，并返回封面数量用于启动信息。
-func NewHandler(execDir string, frontend fs.FS) (http.Handler, int, error) {
+func NewHandler(execDir string, frontend fs.FS, cfg *config.Config) (Handler, int, error) {
 	if frontend == nil {
 		return nil, 0, errors.New("frontend 文件系统不能为空")
 	}
+	if cfg == nil {
+		def := config.Default()
+		cfg = &def
+	}
+
+	api.SetCoverModes(cfg.CoversFileMode, cfg.CoversDirMode)
+	upstream := api.NewUpstreamLimiter(cfg.UpstreamQueue)
+	opts := []api.Option{
+		api.WithUpstreamLimiter(upstream),
+		api.WithUserAgent(cfg.UserAgent),
+		api.WithAPITimeout(cfg.APITimeout),
+		api.WithDownloadTimeout(cfg.DownloadTimeout),
+		api.WithSourceSubdirs(cfg.CoverSubdirs),
+		api.WithVerifyImages(cfg.VerifyImages),
+		api.WithCoverHosts(cfg.CoverHosts),
+		api.WithAnyCoverHost(cfg.AllowAnyHost),
+		api.WithBrowseLimit(cfg.MaxBrowseLimit),
+		api.WithVNDBMaxResults(cfg.VNDBMaxResults),
+		api.WithCacheIgnoreFields(cfg.CacheIgnoreFields...),
+		api.WithFilenameMaxLen(cfg.FilenameMaxLength),
+		api.WithConnPool(cfg.MaxIdleConnsPerHost, cfg.MaxConnsPerHost),
+	}
+	if cfg.FilenameTemplate != "" {
+		tmpl, err := api.ParseFilenameTemplate(cfg.FilenameTemplate)
+		if err != nil {
+			return nil, 0, fmt.Errorf("无效的 -filename-template: %w", err)
+		}
+		opts = append(opts, api.WithFilenameTemplate(tmpl))
+	}
 
 	h := &handler{
-		frontend:  frontend,
-		coversDir: filepath.Join(execDir, coversDirName),
-		stateFile: filepath.Join(execDir, stateFileName),
-		mux:       http.NewServeMux(),
+		frontend:     frontend,
+		coversDir:    filepath.Join(execDir, coversDirName),
+		stateFile:    filepath.Join(execDir, stateFileName),
+		chartsDir:    filepath.Join(execDir, chartsDirName),
+		thumbsDir:    filepath.Join(execDir, thumbsDirName),
+		bgmTokenFile: filepath.Join(execDir, bgmTokenFileName),
+		mux:          http.NewServeMux(),
+		upstream:     upstream,
+		cfg:          cfg,
+	}
+	h.font = loadRenderFont(cfg.FontPath)
+	if cfg.Phash {
+		h.phash = newPhashIndex()
 	}
 
-	if err := os.MkdirAll(h.coversDir, 0o755); err != nil {
+	if err := api.MkdirCovers(h.coversDir); err != nil {
 		return nil, 0, err
 	}
+	covers, err := openCoverDirs(append(slices.Clone(cfg.LibraryDirs), h.coversDir))
+	if err != nil {
+		return nil, 0, err
+	}
+	h.covers = covers
 
 	if _, err := os.Stat(h.stateFile); errors.Is(err, os.ErrNotExist) {
 		if writeErr := os.WriteFile(h.stateFile, []byte("{}\n"), 0o644); writeErr != nil {
@@ -65,53 +164,183 @@ func NewHandler(execDir string, frontend fs.FS) (http.Handler, int, error) {
 		}
 	}
 
-	h.bgm = api.NewClient(h.coversDir)
-	h.vndb = api.NewVNDBClient(h.coversDir, "")
+	// 内存缓存由各客户端各自创建，保持各自的容量上限；其余后端两个客户端共用一份
+	if cfg.CacheBackend != api.CacheBackendMemory {
+		cache, err := api.NewCache(cfg.CacheBackend, filepath.Join(execDir, api.CacheDirName))
+		if err != nil {
+			return nil, 0, err
+		}
+		opts = append(opts, api.WithCache(cache))
+	}
+
+	bgmOpts := append(slices.Clone(opts), api.WithBangumiToken(loadBangumiToken(h.bgmTokenFile)))
+	h.bgm = api.NewClient(h.coversDir, bgmOpts...)
+	h.vndb = api.NewVNDBClient(h.coversDir, "", opts...)
+	anidbOpts := append(slices.Clone(opts), api.WithAniDBTitlesFile(filepath.Join(execDir, anidbTitlesFileName)))
+	h.anidb = api.NewAniDBClient(h.coversDir, cfg.AniDBClient, cfg.AniDBClientVer, anidbOpts...)
+	h.backups = newBackupStore(filepath.Join(execDir, backupsDirName))
+	h.coverOrder = loadCoverOrder(filepath.Join(execDir, coverOrderFileName))
+	h.prefs = loadPrefs(filepath.Join(execDir, prefsFileName), h.validatePref)
+	h.trash = newTrashStore(filepath.Join(h.coversDir, trashDirName), cfg.TrashDays)
+	h.states = newStateWriter(h, cfg.AutosaveInterval)
+	h.events = newEventHub()
+	h.coverVersion.changedAt.Store(time.Now().UnixMilli())
+	h.collab = newCollabHub()
 	h.routes()
+	h.root = prettyJSON(h.mux, cfg.PrettyJSON)
+	if cfg.ReadOnly {
+		h.root = h.readOnly(h.root)
+	}
+	if cfg.AuthEnabled() {
+		h.root = basicAuth(h.root, cfg.AuthUser, cfg.AuthPass)
+	}
+	h.root = withRequestID(logRequests(h.root))
 
-	files, err := h.coverFileNames()
+	if cfg.Prefetch && h.sourceUnavailable(api.SourceBangumi) == "" {
+		go h.prefetch()
+	}
+	if cfg.PregenThumbs {
+		h.pregen = newThumbPregen(h)
+	}
+
+	files, err := h.listCovers(cfg.CoverSubdirs)
 	if err != nil {
 		return nil, 0, err
 	}
+	// 首次扫描成功后再启动归档，扫描失败返回错误时不会留下后台任务
+	if cfg.ArchiveInterval > 0 {
+		h.archiver = newCoverArchiver(h, filepath.Join(execDir, backupsDirName), cfg.ArchiveInterval, cfg.ArchiveKeep)
+	}
 
 	return h, len(files), nil
 }
 
-// ServeHTTP 将请求转交给内部 mux。
+// prefetch 在后台预热常用浏览查询，不阻塞服务就绪。
+func (h *handler) prefetch() {
+	start := time.Now()
+	ok, total := h.bgm.Prefetch()
+	slog.Info("浏览缓存预热完成", "ok", ok, "total", total, "duration_ms", time.Since(start).Milliseconds())
+}
+
+// CloseStreams 断开所有事件流订阅者与协同编辑连接。
+func (h *handler) CloseStreams() {
+	h.events.close()
+	h.collab.close()
+}
+
+// Close 落盘所有排队中的状态写入并停止备份清理。
+func (h *handler) Close() error {
+	h.states.Close()
+	h.backups.close()
+	h.trash.close()
+	h.renders.close()
+	if h.pregen != nil {
+		h.pregen.close()
+	}
+	if h.archiver != nil {
+		h.archiver.close()
+	}
+	h.covers.close()
+	return nil
+}
+
+// ServeHTTP 将请求经中间件转交给内部 mux。
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h.mux.ServeHTTP(w, r)
+	h.root.ServeHTTP(w, r)
 }
 
 // routes 注册所有 HTTP 路由。
 func (h *handler) routes() {
 	h.mux.HandleFunc("/", h.handleIndex)
-	h.mux.Handle("/covers/", http.StripPrefix("/covers/", http.FileServer(http.Dir(h.coversDir))))
+	h.mux.Handle("/covers/", http.StripPrefix("/covers/", http.FileServer(h.covers)))
+	h.mux.HandleFunc(hashedCoversPrefix, h.handleHashedCover)
 	h.mux.HandleFunc("/api/state", h.handleState)
+	h.mux.HandleFunc("/api/state/backup-policy", h.handleBackupPolicy)
+	h.mux.HandleFunc("/api/state/from-template", h.handleStateFromTemplate)
+	h.mux.HandleFunc("/api/templates", h.handleTemplates)
+	h.mux.HandleFunc("/api/events", h.handleEvents)
+	h.mux.HandleFunc("/ws/state", h.handleStateWS)
+	h.mux.HandleFunc("/api/charts", h.handleCharts)
+	h.mux.HandleFunc("/api/charts/clone", h.handleCloneChart)
+	h.mux.HandleFunc("/api/maintenance", h.handleMaintenance)
 	h.mux.HandleFunc("/api/covers", h.handleCovers)
-	h.mux.HandleFunc("/api/search", h.handleSearch)
-	h.mux.HandleFunc("/api/browse", h.handleBrowse)
-	h.mux.HandleFunc("/api/recommend", h.handleRecommend)
+	h.mux.HandleFunc("/api/covers/duplicates", h.handleCoverDuplicates)
+	h.mux.HandleFunc("/api/covers/reindex", h.handleCoverReindex)
+	h.mux.HandleFunc("/api/covers/stats", h.handleCoverStats)
+	h.mux.HandleFunc("/api/covers/version", h.handleCoverVersion)
+	h.mux.HandleFunc("/api/covers/order", h.handleCoverOrder)
+	h.mux.HandleFunc("/api/covers/trash", h.handleTrash)
+	h.mux.HandleFunc("/api/covers/trash/restore", h.handleTrashRestore)
+	h.mux.HandleFunc("/api/thumb", h.handleThumb)
+	h.mux.HandleFunc("/api/search", h.requireSource(api.SourceBangumi, h.handleSearch))
+	h.mux.HandleFunc("/api/search-unified", h.handleUnifiedSearch)
+	h.mux.HandleFunc("/api/sources", h.handleSources)
+	h.mux.HandleFunc("/api/config", h.handleConfig)
+	h.mux.HandleFunc("/api/prefs", h.handlePrefs)
+	h.mux.HandleFunc("/api/browse", h.requireSource(api.SourceBangumi, h.handleBrowse))
+	h.mux.HandleFunc("/api/query", h.handleQuery)
+	h.mux.HandleFunc("/api/crosslink", h.handleCrossLink)
+	h.mux.HandleFunc("/api/diagnostics", h.handleDiagnostics)
+	h.mux.HandleFunc("/api/stats", h.handleStats)
+	h.mux.HandleFunc("/api/health", h.handleHealth)
+	h.mux.HandleFunc("/api/subjects", h.requireSource(api.SourceBangumi, h.handleSubjects))
+	h.mux.HandleFunc("/api/bangumi/token", h.requireSource(api.SourceBangumi, h.handleBangumiToken))
+	h.mux.HandleFunc("/api/calendar", h.requireSource(api.SourceBangumi, h.handleCalendar))
+	h.mux.HandleFunc("/api/trending", h.requireSource(api.SourceBangumi, h.handleTrending))
+	h.mux.HandleFunc("/api/subject/episodes", h.requireSource(api.SourceBangumi, h.handleEpisodes))
+	h.mux.HandleFunc("/api/subject/raw", h.requireSource(api.SourceBangumi, h.handleSubjectRaw))
+	h.mux.HandleFunc("/api/subject/rating", h.requireSource(api.SourceBangumi, h.handleSubjectRating))
+	h.mux.HandleFunc("/api/subject/persons", h.requireSource(api.SourceBangumi, h.handleSubjectPersons))
+	h.mux.HandleFunc("/api/subject/characters", h.requireSource(api.SourceBangumi, h.handleSubjectCharacters))
+	h.mux.HandleFunc("/api/recommend", h.requireSource(api.SourceBangumi, h.handleRecommend))
+	h.mux.HandleFunc("/api/recommend/validate", h.handleRecommendValidate)
 	h.mux.HandleFunc("/api/download-cover", h.handleDownloadCover)
+	h.mux.HandleFunc("/api/cell/from-subject", h.handleCellFromSubject)
+	h.mux.HandleFunc("/api/cover/redownload", h.handleRedownloadCover)
+	h.mux.HandleFunc("/api/cover/colors", h.handleCoverColors)
+	h.mux.HandleFunc("/api/cover/exif", h.handleCoverEXIF)
+	h.mux.HandleFunc("/api/placeholder", h.handlePlaceholder)
 	h.mux.HandleFunc("/api/upload-cover", h.handleUploadCover)
 	h.mux.HandleFunc("/api/delete-cover", h.handleDeleteCover)
-	h.mux.HandleFunc("/api/vndb/search", h.handleVNDBSearch)
+	h.mux.HandleFunc("/api/vndb/search", h.requireSource(api.SourceVNDB, h.handleVNDBSearch))
+	h.mux.HandleFunc("/api/vndb/similar", h.requireSource(api.SourceVNDB, h.handleVNDBSimilar))
+	h.mux.HandleFunc("/api/anidb/search", h.requireSource(api.SourceAniDB, h.handleAniDBSearch))
+	h.mux.HandleFunc("/api/anidb/anime", h.requireSource(api.SourceAniDB, h.handleAniDBAnime))
+	h.mux.HandleFunc("/api/render/pdf", h.handleRenderPDF)
+	h.mux.HandleFunc("/api/render/pdf/stream", h.handleRenderPDFStream)
+	h.mux.HandleFunc(renderResultPrefix, h.handleRenderResult)
 }
 
-// handleIndex 返回前端首页内容。
+// handleIndex 提供前端静态文件：存在的文件按扩展名设置 Content-Type 直接返回，
+// 其余无扩展名的路径视为前端路由，回退到 index.html。/api/ 与 /covers/ 由各自的路由优先处理。
 func (h *handler) handleIndex(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
-
-	if r.URL.Path != "/" {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
 		http.NotFound(w, r)
 		return
 	}
 
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name != "" && name != "index.html" {
+		if info, err := fs.Stat(h.frontend, name); err == nil && !info.IsDir() {
+			w.Header().Set("Cache-Control", "no-cache")
+			http.ServeFileFS(w, r, h.frontend, name)
+			return
+		}
+		// 缺失的静态资源直接 404，避免把首页 HTML 当作脚本或样式返回
+		if path.Ext(name) != "" {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
 	b, err := fs.ReadFile(h.frontend, "index.html")
 	if err != nil {
-		http.Error(w, "index.html not found", http.StatusInternalServerError)
+		slog.Error("读取前端首页失败", "error", err)
+		http.Error(w, "前端文件缺失（index.html），API 仍可正常使用", http.StatusServiceUnavailable)
 		return
 	}
 
@@ -120,39 +349,211 @@ func (h *handler) handleIndex(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(b)
 }
 
-// handleState 统一处理状态读取和写入。
+// handleHealth 返回服务存活状态与是否只读（GET /api/health），启用认证时同样可匿名访问，供健康检查使用。
+func (h *handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "readOnly": h.cfg.ReadOnly})
+}
+
+// handleState 统一处理状态读取、整体写入（POST）和增量补丁（PATCH）。chart 参数指定命名图表，缺省为 state.json。
 func (h *handler) handleState(w http.ResponseWriter, r *http.Request) {
+	path, err := h.chartPath(r.URL.Query().Get("chart"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		h.loadState(w)
+		h.loadState(w, r, path)
 	case http.MethodPost:
-		h.saveState(w, r)
+		h.saveState(w, r, path)
+	case http.MethodPatch:
+		h.patchState(w, r, path)
 	default:
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 // handleCovers 返回 covers 目录下的图片文件名列表。
+// recursive=1 或启用 -cover-subdirs 时递归子目录，返回以 / 分隔的相对路径。
+// minWidth、minHeight、aspect（如 3:4）按像素尺寸筛选；X-Total-Count 为筛选后的数量。
+// 保存过自定义排序（/api/covers/order）时按该顺序返回，其余文件按字母顺序排在最后。
+// 默认返回裸数组以兼容旧前端；Accept 含 coversV2MIME 或带 v=2 时返回 coverListV2 对象。
 func (h *handler) handleCovers(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	files, err := h.coverFileNames()
+	filter, err := parseDimFilter(r)
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	recursive := queryBool(r, "recursive") || h.cfg.CoverSubdirs
+	files, err := h.listCovers(recursive)
 	if err != nil {
 		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
-	h.writeJSON(w, http.StatusOK, files)
+	// 设置了尺寸条件时只保留满足条件的封面，无法解析尺寸的文件一并排除
+	var dims map[string]coverDims
+	if filter.active() {
+		dims = h.dims.lookup(h.coverPath, files)
+		files = slices.DeleteFunc(files, func(name string) bool {
+			d, ok := dims[name]
+			return !ok || !filter.match(d)
+		})
+	}
+	h.coverOrder.apply(files)
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(files)))
+	w.Header().Set("Vary", "Accept")
+	respond := func(list any) {
+		if !wantsCoversV2(r) {
+			h.streamJSON(w, http.StatusOK, list)
+			return
+		}
+		v2 := coverListV2{
+			Version:   2,
+			Files:     list,
+			Total:     len(files),
+			Recursive: recursive,
+		}
+		if queryBool(r, "hashed") {
+			v2.HashedURLs = h.hashedCoverURLs(files)
+		}
+		h.streamJSON(w, http.StatusOK, v2)
+	}
+
+	if !queryBool(r, "usage") {
+		respond(files)
+		return
+	}
+
+	// usage=1 时附带每个封面在当前网格中的引用次数；状态变化频繁，每次请求重新统计
+	chart, err := h.chartPath(r.URL.Query().Get("chart"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	refs, err := h.stateCoverRefs(chart)
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	entries := make([]coverEntry, 0, len(files))
+	for _, name := range files {
+		e := coverEntry{Name: name, Uses: refs[name], Used: refs[name] > 0}
+		if d, ok := dims[name]; ok {
+			e.Width, e.Height = d.Width, d.Height
+		}
+		entries = append(entries, e)
+	}
+	respond(entries)
+}
+
+// coversV2MIME 是请求对象形式封面列表的媒体类型。
+const coversV2MIME = "application/vnd.ocm.v2+json"
+
+// coverListV2 是对象形式的封面列表，便于后续追加字段而不破坏客户端。
+// Files 为文件名数组，usage=1 时为 coverEntry 数组；hashed=1 时 HashedURLs 给出各封面的内容寻址 URL。
+type coverListV2 struct {
+	Version    int               `json:"version"`
+	Files      any               `json:"files"`
+	Total      int               `json:"total"`
+	Recursive  bool              `json:"recursive"`
+	HashedURLs map[string]string `json:"hashedURLs,omitempty"`
+}
+
+// wantsCoversV2 判断客户端是否请求对象形式的封面列表。
+func wantsCoversV2(r *http.Request) bool {
+	return r.URL.Query().Get("v") == "2" || acceptsMIME(r.Header.Get("Accept"), coversV2MIME)
+}
+
+// acceptsMIME 判断 Accept 头是否显式接受指定类型（按字面比较，不展开通配符；q=0 视为拒绝）。
+func acceptsMIME(accept, mime string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), mime) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// coverEntry 是带使用情况的封面列表条目。
+// 按尺寸筛选时附带宽高。
+type coverEntry struct {
+	Name   string `json:"name"`
+	Uses   int    `json:"uses"`
+	Used   bool   `json:"used"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
 }
 
-// loadState 读取 state.json，文件缺失或空内容时返回空对象。
-func (h *handler) loadState(w http.ResponseWriter) {
-	h.stateMu.RLock()
-	b, err := os.ReadFile(h.stateFile)
-	h.stateMu.RUnlock()
+// stateCoverRefs 统计图表状态中 cells 引用的封面文件名及次数。
+// 格子中保存的是 "covers/" + encodeURIComponent(name) 形式的 URL。
+func (h *handler) stateCoverRefs(path string) (map[string]int, error) {
+	b, err := h.readChart(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]int{}, nil
+		}
+		return nil, err
+	}
+
+	var state struct {
+		Cells []*string `json:"cells"`
+	}
+	if len(strings.TrimSpace(string(b))) > 0 {
+		// 状态结构不符合预期时按无引用处理，不影响列表本身
+		_ = json.Unmarshal(b, &state)
+	}
+
+	refs := make(map[string]int)
+	for _, cell := range state.Cells {
+		if cell == nil {
+			continue
+		}
+		if name, ok := coverNameFromURL(*cell); ok {
+			refs[name]++
+		}
+	}
+	return refs, nil
+}
+
+// coverNameFromURL 从格子 URL 中解析出 covers 目录下的相对文件名。
+func coverNameFromURL(u string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimPrefix(u, "/"), coversDirName+"/")
+	if !ok || rest == "" {
+		return "", false
+	}
+	name, err := url.PathUnescape(rest)
+	if err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// loadState 读取图表状态文件，文件缺失或空内容时返回空对象。
+// 响应带 ETag（内容哈希）与 Last-Modified，客户端携带 If-None-Match / If-Modified-Since 且内容未变时返回 304。
+// Last-Modified 只精确到秒，同一秒内的多次保存需依赖 ETag 区分。
+func (h *handler) loadState(w http.ResponseWriter, r *http.Request, path string) {
+	modTime := h.chartModTime(path)
+	b, err := h.readChart(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			h.writeJSON(w, http.StatusOK, map[string]any{})
@@ -174,11 +575,16 @@ func (h *handler) loadState(w http.ResponseWriter) {
 		return
 	}
 
-	h.writeJSONRaw(w, http.StatusOK, b)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(b)))
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(b))
 }
 
-// saveState 接收 JSON 请求体并格式化写入 state.json。
-func (h *handler) saveState(w http.ResponseWriter, r *http.Request) {
+// saveState 接收 JSON 请求体并格式化后放入写入队列，立即返回 202。
+// 队列会合并短时间内的多次保存，只落盘最新内容。
+// 请求体可带 _meta 块保存条目元数据快照（见 stateMetaKey），格式不正确时返回 400。
+func (h *handler) saveState(w http.ResponseWriter, r *http.Request, path string) {
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "读取请求体失败"})
@@ -190,104 +596,299 @@ func (h *handler) saveState(w http.ResponseWriter, r *http.Request) {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "请求体不是合法 JSON"})
 		return
 	}
+	if err := validateStateMeta(anyJSON); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
-	formatted, err := json.MarshalIndent(anyJSON, "", "  ")
+	formatted, err := h.marshalState(anyJSON)
 	if err != nil {
 		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "JSON 序列化失败"})
 		return
 	}
-	formatted = append(formatted, '\n')
 
-	h.stateMu.Lock()
-	writeErr := os.WriteFile(h.stateFile, formatted, 0o644)
-	h.stateMu.Unlock()
+	h.editMu.Lock()
+	h.states.Enqueue(path, formatted)
+	h.publishSnapshot(path, formatted)
+	h.editMu.Unlock()
+	h.events.publish(eventStateSaved, map[string]string{"chart": chartDisplayName(r.URL.Query().Get("chart"))})
+	h.writeJSON(w, http.StatusAccepted, map[string]bool{"ok": true})
+}
 
-	if writeErr != nil {
-		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": writeErr.Error()})
+// handleSearch 处理关键词搜索请求（POST /api/search）。
+// keywords 给出多个关键词时合并各自的结果并按 ID 去重，结果按关键词分组排列（见 api.Client.SearchMulti）。
+// excludeDownloaded 为 true 时剔除封面已下载的条目（依据封面旁路元数据中的 source 与 id）。
+// 省略 type 时取服务端偏好 searchType，未设置时搜索动画。
+func (h *handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	h.writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
-}
+	var req struct {
+		Keyword  string   `json:"keyword"`
+		Keywords []string `json:"keywords"` // 多个关键词合并搜索，与 keyword 同时给出时 keyword 排在最前
+		Type     int      `json:"type"`
 
-// coverFileNames 扫描 covers 目录并返回图片文件名（不含子目录）。
-func (h *handler) coverFileNames() ([]string, error) {
-	entries, err := os.ReadDir(h.coversDir)
-	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			return []string{}, nil
-		}
-		return nil, err
+		ExcludeDownloaded bool `json:"excludeDownloaded"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	if req.Type == 0 {
+		req.Type = cmp.Or(h.prefs.int(prefSearchType), 2) // 默认搜索动画
 	}
 
-	files := make([]string, 0, len(entries))
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		ext := strings.ToLower(filepath.Ext(e.Name()))
-		if _, ok := imageExts[ext]; ok {
-			files = append(files, e.Name())
-		}
+	var (
+		results []api.SearchResult
+		err     error
+	)
+	if len(req.Keywords) > 0 {
+		results, err = h.bgm.SearchMulti(append([]string{req.Keyword}, req.Keywords...), req.Type)
+	} else {
+		results, err = h.bgm.Search(req.Keyword, req.Type)
+	}
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	if req.ExcludeDownloaded {
+		results = excludeDownloadedSearch(results, h.downloadedSubjects())
 	}
 
-	sort.Slice(files, func(i, j int) bool {
-		return strings.ToLower(files[i]) < strings.ToLower(files[j])
-	})
-	return files, nil
+	h.writeJSON(w, http.StatusOK, map[string]any{"results": results})
 }
 
-// handleSearch 处理关键词搜索请求（POST /api/search）。
-func (h *handler) handleSearch(w http.ResponseWriter, r *http.Request) {
+// handleBrowse 处理标签浏览请求（POST /api/browse）。
+// excludeDownloaded 为 true 时剔除封面已下载的条目。过滤在本页结果上进行，因此一页可能少于 limit 条；
+// 翻页仍按上游偏移 offset+limit 前进，excluded 为本页剔除的条数，total 已扣除这部分。
+// fields 为 minimal 时每条结果只返回 id、name、cover，减小列表视图的响应体积。
+// 省略 sort 或 limit 时取服务端偏好 browseSort / browseLimit。
+func (h *handler) handleBrowse(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	var req struct {
-		Keyword string `json:"keyword"`
-		Type    int    `json:"type"`
+		api.BrowseRequest
+		ExcludeDownloaded bool   `json:"excludeDownloaded"`
+		Fields            string `json:"fields"` // full（默认）或 minimal
 	}
 	if err := readJSON(r, &req); err != nil {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
 		return
 	}
-	if req.Type == 0 {
-		req.Type = 2 // 默认搜索动画
+	switch req.Fields {
+	case "", browseFieldsFull, browseFieldsMinimal:
+	default:
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "无效的 fields: " + req.Fields + "（可选 full/minimal）"})
+		return
+	}
+	if req.Sort == "" {
+		req.Sort = h.prefs.str(prefBrowseSort)
+	}
+	if req.Limit == 0 {
+		req.Limit = h.prefs.int(prefBrowseLimit)
 	}
 
-	results, err := h.bgm.Search(req.Keyword, req.Type)
+	resp, err := h.bgm.Browse(req.BrowseRequest)
 	if err != nil {
 		h.writeAPIError(w, err)
 		return
 	}
+	if req.ExcludeDownloaded {
+		excludeDownloadedBrowse(resp, h.downloadedSubjects())
+	}
 
-	h.writeJSON(w, http.StatusOK, map[string]any{"results": results})
+	h.streamJSON(w, http.StatusOK, trimBrowseResponse(resp, req.Fields))
 }
 
-// handleBrowse 处理标签浏览请求（POST /api/browse）。
-func (h *handler) handleBrowse(w http.ResponseWriter, r *http.Request) {
+// handleQuery 处理结构化检索请求（POST /api/query），按 source 路由到 Bangumi 或 VNDB，
+// 返回统一卡片格式。Bangumi 的 excludeTags 在本地过滤，一页可能少于 limit 条，excluded 与 total 的含义见 api.QueryResult。
+func (h *handler) handleQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var q api.Query
+	if err := readJSON(r, &q); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	if err := q.Normalize(); err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	if !h.checkSource(w, q.Source) {
+		return
+	}
+
+	var (
+		result *api.QueryResult
+		err    error
+	)
+	if q.Source == api.SourceVNDB {
+		result, err = h.vndb.Query(q)
+	} else {
+		result, err = h.bgm.Query(q)
+	}
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.streamJSON(w, http.StatusOK, result)
+}
+
+// handleSubjects 批量获取条目详情（POST /api/subjects），用于从保存的 ID 恢复图表。
+func (h *handler) handleSubjects(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req api.BrowseRequest
+	var req struct {
+		IDs []int `json:"ids"`
+		api.BatchOptions
+	}
 	if err := readJSON(r, &req); err != nil {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
 		return
 	}
+	if len(req.IDs) == 0 {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少条目 ID"})
+		return
+	}
 
-	resp, err := h.bgm.Browse(req)
+	result, err := h.bgm.GetSubjectsBatch(req.IDs, req.BatchOptions)
 	if err != nil {
 		h.writeAPIError(w, err)
 		return
 	}
+	h.writeJSON(w, http.StatusOK, result)
+}
 
-	h.writeJSON(w, http.StatusOK, resp)
+// handleCalendar 返回 Bangumi 每日放送表（GET /api/calendar）。
+func (h *handler) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days, err := h.bgm.GetCalendar()
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"days": days})
+}
+
+// handleTrending 返回近期热门条目（GET /api/trending?type=anime），type 缺省为 anime，格式与 /api/query 的卡片一致。
+// manga/novel 在书籍热门中按子类型筛选，数量可能少于其他类型。
+func (h *handler) handleTrending(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	typ := r.URL.Query().Get("type")
+	if typ == "" {
+		typ = "anime"
+	}
+	st, ok := api.TypeMap[typ]
+	if !ok {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "不支持的类型: " + typ})
+		return
+	}
+	cards, err := h.bgm.GetTrending(st.TypeID)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	if st.MetaTag != "" {
+		cards = slices.DeleteFunc(cards, func(c api.Card) bool { return c.TypeLabel != st.MetaTag })
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"type": typ, "results": cards})
+}
+
+// handleSubjectRaw 原样转发 Bangumi v0 条目 JSON（GET /api/subject/raw?id=<n>）。
+// 常用字段请使用 /api/subjects 的结构化结果，此接口供前端读取未建模的字段。
+func (h *handler) handleSubjectRaw(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少或无效的 id 参数"})
+		return
+	}
+	data, err := h.bgm.GetSubjectRaw(id)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSONRaw(w, http.StatusOK, data)
+}
+
+// handleSubjectRating 返回条目的评分分布（GET /api/subject/rating?id=<n>）：1~10 分各自的人数与总人数。
+// 与条目详情共用上游缓存。
+func (h *handler) handleSubjectRating(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少或无效的 id 参数"})
+		return
+	}
+	rating, err := h.bgm.GetSubjectRating(id)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, rating)
+}
+
+// handleEpisodes 分页返回条目的章节列表（GET /api/subject/episodes?id=<n>&type=0&offset=0&limit=100）。
+// type 缺省时不限章节类型。
+func (h *handler) handleEpisodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	id, err := strconv.Atoi(q.Get("id"))
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少或无效的 id 参数"})
+		return
+	}
+	epType := -1
+	if v := q.Get("type"); v != "" {
+		if epType, err = strconv.Atoi(v); err != nil || epType < 0 {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "无效的 type 参数"})
+			return
+		}
+	}
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	page, err := h.bgm.GetEpisodes(id, epType, offset, limit)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, page)
 }
 
 // handleVNDBSearch 处理 VNDB 关键词搜索请求（POST /api/vndb/search）。
+// limit 缺省时取偏好 vndbPageSize 或 -vndb-page-size，超过 -vndb-max-results 时截断；响应的 limit 是实际每页条数，供前端分页。
 func (h *handler) handleVNDBSearch(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -295,9 +896,10 @@ func (h *handler) handleVNDBSearch(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Keyword string `json:"keyword"`
-		Page    int    `json:"page"`
-		Limit   int    `json:"limit"`
+		Keyword     string   `json:"keyword"`
+		Page        int      `json:"page"`
+		Limit       int      `json:"limit"`
+		ExtraFields []string `json:"extraFields"`
 	}
 	if err := readJSON(r, &req); err != nil {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
@@ -306,69 +908,182 @@ func (h *handler) handleVNDBSearch(w http.ResponseWriter, r *http.Request) {
 	if req.Page <= 0 {
 		req.Page = 1
 	}
-	if req.Limit <= 0 || req.Limit > 100 {
-		req.Limit = 20
+	if req.Limit <= 0 {
+		req.Limit = cmp.Or(h.prefs.int(prefVNDBPageSize), h.cfg.VNDBPageSize)
 	}
+	req.Limit = min(req.Limit, h.cfg.VNDBMaxResults)
 
-	resp, err := h.vndb.SearchVN(req.Keyword, req.Page, req.Limit)
+	resp, err := h.vndb.SearchVN(req.Keyword, req.Page, req.Limit, req.ExtraFields...)
 	if err != nil {
 		h.writeAPIError(w, err)
 		return
 	}
 
-	// 将 VNDB 结果映射为前端通用的卡片格式
-	type card struct {
-		ID     string  `json:"id"`
-		Name   string  `json:"name"`
-		NameCN string  `json:"name_cn"`
-		Cover  string  `json:"cover"`
-		Score  float64 `json:"score"`
-		Source string  `json:"source"`
+	cards := vndbCards(resp.Results)
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"results": cards,
+		"total":   resp.Count,
+		"more":    resp.More,
+		"page":    req.Page,
+		"limit":   req.Limit,
+	})
+}
+
+// handleVNDBSimilar 返回与指定 VN 共享标签的相似作品（GET /api/vndb/similar?id=v17）。
+func (h *handler) handleVNDBSimilar(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	cards := make([]card, 0, len(resp.Results))
-	for _, vn := range resp.Results {
-		cards = append(cards, card{
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少 id 参数"})
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	resp, err := h.vndb.SimilarVN(id, limit)
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"results": vndbCards(resp.Results)})
+}
+
+// vndbCard 是 VNDB 结果映射后的前端通用卡片格式。
+type vndbCard struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	NameCN string  `json:"name_cn"`
+	Cover  string  `json:"cover"`
+	Score  float64 `json:"score"`
+	Source string  `json:"source"`
+
+	Extra map[string]json.RawMessage `json:"extra,omitempty"`
+}
+
+// vndbCards 将 VNDB 结果映射为前端卡片列表。
+func vndbCards(results []api.VNDBVN) []vndbCard {
+	cards := make([]vndbCard, 0, len(results))
+	for _, vn := range results {
+		cards = append(cards, vndbCard{
 			ID:     vn.ID,
 			Name:   vn.Title,
 			NameCN: vn.Alttitle,
 			Cover:  vn.Image.BestURL(),
 			Score:  vn.Rating / 10,
 			Source: "vndb",
+			Extra:  vn.Extra,
 		})
 	}
-
-	h.writeJSON(w, http.StatusOK, map[string]any{
-		"results": cards,
-		"total":   resp.Count,
-		"more":    resp.More,
-	})
+	return cards
 }
 
 // handleRecommend 处理批量推荐请求（POST /api/recommend）。
+// 带 sessionID 时，服务端记录本会话推荐过的条目，后续请求自动与 excludeIDs 合并排除；
+// 会话无活动 30 分钟后过期。
 func (h *handler) handleRecommend(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	var req api.RecommendRequest
+	var req struct {
+		api.RecommendRequest
+		SessionID string `json:"sessionID"`
+	}
 	if err := readJSON(r, &req); err != nil {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
 		return
 	}
+	if req.SessionID != "" {
+		if !recSessionIDPattern.MatchString(req.SessionID) {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "sessionID 只能包含字母、数字、下划线和连字符，最长 64 个字符"})
+			return
+		}
+		req.ExcludeIDs = append(req.ExcludeIDs, h.recSessions.excluded(req.SessionID)...)
+	}
+	if req.AutoDownload && h.cfg.ReadOnly {
+		h.writeJSON(w, http.StatusForbidden, map[string]string{"error": errReadOnly})
+		return
+	}
 
-	resp, err := h.bgm.Recommend(req)
+	resp, err := h.bgm.Recommend(req.RecommendRequest)
 	if err != nil {
 		h.writeAPIError(w, err)
 		return
 	}
+	if req.SessionID != "" {
+		var picked []int
+		for _, res := range resp.Results {
+			if res.Item != nil {
+				picked = append(picked, res.Item.ID)
+			}
+		}
+		h.recSessions.add(req.SessionID, picked)
+	}
+	for _, res := range resp.Results {
+		if res.Path != "" {
+			h.coverDownloaded(&api.DownloadResult{Filename: res.Filename, Reused: res.Reused, Refreshed: res.Refreshed})
+		}
+	}
 
 	h.writeJSON(w, http.StatusOK, resp)
 }
 
+// handleRecommendValidate 校验推荐请求的格子参数（POST /api/recommend/validate），不访问上游 API。
+// 返回 valid 与存在问题的格子列表 cells，校验规则与 /api/recommend 相同。
+func (h *handler) handleRecommendValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req api.RecommendRequest
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+
+	problems := api.ValidateRecommend(req)
+	if problems == nil {
+		problems = []api.RecommendCellProblem{}
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"valid": len(problems) == 0,
+		"cells": problems,
+	})
+}
+
+// coverAdded 在封面写入 covers 目录后刷新列表缓存与修改代数、广播事件，并按需排队预生成缩略图。
+// name 是相对 covers 目录、以 / 分隔的路径。
+func (h *handler) coverAdded(name string) {
+	h.coverList.invalidate()
+	h.coverVersion.bump()
+	h.events.publish(eventCoverAdded, map[string]string{"filename": name, "path": "covers/" + name})
+	if h.pregen != nil {
+		h.pregen.enqueue(name)
+	}
+}
+
+// coverDownloaded 按下载结果刷新缓存并广播事件：新文件按 coverAdded 处理；
+// 已有封面在上游更新、原地覆盖时与重新下载一样先删除旧缩略图；直接复用已有文件时没有变化，什么也不做。
+func (h *handler) coverDownloaded(result *api.DownloadResult) {
+	switch {
+	case result.Reused:
+		return
+	case result.Refreshed:
+		h.removeThumbnails(filepath.FromSlash(result.Filename))
+	}
+	h.coverAdded(result.Filename)
+}
+
 // handleDownloadCover 处理封面下载请求（POST /api/download-cover）。
 // source 字段可选，值为 "vndb" 时使用 VNDB 客户端下载，否则默认 Bangumi。
+// flatten 为 true 时动图（GIF/APNG）只保留第一帧并另存为 PNG，响应中 flattened 表示是否发生了转换。
+// upscale 为 true 时短边小于 upscaleMin 的封面用 CatmullRom 放大（最多 3 倍），响应给出放大前后的尺寸。
 func (h *handler) handleDownloadCover(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -376,26 +1091,158 @@ func (h *handler) handleDownloadCover(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		URL      string `json:"url"`
-		Filename string `json:"filename"`
-		Source   string `json:"source"`
+		URL        string `json:"url"`
+		Filename   string `json:"filename"`
+		Source     string `json:"source"`
+		ID         string `json:"id"`         // 可选，供 -filename-template 使用
+		Title      string `json:"title"`      // 可选，同上
+		Flatten    bool   `json:"flatten"`    // 动图只保留第一帧，另存为静态 PNG
+		Upscale    bool   `json:"upscale"`    // 短边过小的封面放大到 upscaleMin
+		UpscaleMin int    `json:"upscaleMin"` // 可选，默认 upscaleDefaultMin
 	}
 	if err := readJSON(r, &req); err != nil {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
 		return
 	}
+	if req.UpscaleMin == 0 {
+		req.UpscaleMin = upscaleDefaultMin
+	}
+	if req.Upscale && (req.UpscaleMin < upscaleMinLimit || req.UpscaleMin > upscaleMaxLimit) {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("upscaleMin 须在 %d-%d 之间", upscaleMinLimit, upscaleMaxLimit),
+		})
+		return
+	}
 
 	var result *api.DownloadResult
 	var err error
-	if req.Source == "vndb" {
-		result, err = h.vndb.DownloadCover(req.URL, req.Filename)
-	} else {
-		result, err = h.bgm.DownloadCover(req.URL, req.Filename)
+	info := api.CoverInfo{ID: req.ID, Title: req.Title}
+	source := api.SourceBangumi
+	if req.Source == api.SourceVNDB || req.Source == api.SourceAniDB {
+		source = req.Source
+	}
+	if !h.checkSource(w, source) {
+		return
+	}
+	switch source {
+	case api.SourceVNDB:
+		result, err = h.vndb.DownloadCover(req.URL, req.Filename, info)
+	case api.SourceAniDB:
+		result, err = h.anidb.DownloadCover(req.URL, req.Filename, info)
+	default:
+		result, err = h.bgm.DownloadCover(req.URL, req.Filename, info)
+	}
+	if err != nil {
+		h.writeAPIError(w, err)
+		return
+	}
+	flattened := false
+	if req.Flatten {
+		name, size, ok, err := h.flattenCover(result.Filename)
+		if err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "转换静态封面失败: " + err.Error()})
+			return
+		}
+		if ok {
+			flattened = true
+			result = &api.DownloadResult{Filename: name, Path: "covers/" + name, Size: size}
+		}
+	}
+	var upscaled *upscaleResult
+	if req.Upscale {
+		res, err := h.upscaleCover(result.Filename, req.UpscaleMin)
+		if err != nil {
+			h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "放大封面失败: " + err.Error()})
+			return
+		}
+		if res.Upscaled {
+			result = &api.DownloadResult{Filename: res.Filename, Path: "covers/" + res.Filename, Size: res.Size}
+		}
+		upscaled = &res
+	}
+	h.coverDownloaded(result)
+
+	resp := map[string]any{
+		"ok":        true,
+		"filename":  result.Filename,
+		"path":      result.Path,
+		"size":      result.Size,
+		"reused":    result.Reused,
+		"refreshed": result.Refreshed,
+		"flattened": flattened,
+	}
+	if upscaled != nil {
+		resp["upscaled"] = upscaled.Upscaled
+		resp["dimensions"] = map[string]coverDims{"original": upscaled.Original, "final": upscaled.Final}
+	}
+	if similar := h.similarCovers(result.Filename); len(similar) > 0 {
+		resp["similar"] = similar
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// handleRedownloadCover 按封面旁路元数据重新下载被误删的封面，恢复为原文件名（POST /api/cover/redownload）。
+func (h *handler) handleRedownloadCover(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Filename string `json:"filename"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	path, ok := h.resolveCoverPath(req.Filename)
+	if _, isImage := imageExts[strings.ToLower(filepath.Ext(path))]; !ok || !isImage {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "无效的封面文件名"})
+		return
+	}
+
+	meta, err := api.ReadCoverMeta(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "没有该封面的元数据，无法重新下载"})
+			return
+		}
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		h.writeJSON(w, http.StatusConflict, map[string]string{"error": "封面文件已存在"})
+		return
+	}
+
+	rel, err := filepath.Rel(h.coversDir, path)
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if slices.ContainsFunc(knownSources, func(s sourceInfo) bool { return s.ID == meta.Source }) {
+		if !h.checkSource(w, meta.Source) {
+			return
+		}
+	}
+	var result *api.DownloadResult
+	switch meta.Source {
+	case api.SourceVNDB:
+		result, err = h.vndb.RestoreCover(rel, meta.URL)
+	case api.SourceAniDB:
+		result, err = h.anidb.RestoreCover(rel, meta.URL)
+	case api.SourceBangumi:
+		result, err = h.bgm.RestoreCover(rel, meta.URL)
+	default:
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "未知的封面来源: " + meta.Source})
+		return
 	}
 	if err != nil {
 		h.writeAPIError(w, err)
 		return
 	}
+	h.removeThumbnails(rel)
+	h.coverAdded(result.Filename)
 
 	h.writeJSON(w, http.StatusOK, map[string]any{
 		"ok":       true,
@@ -406,6 +1253,7 @@ func (h *handler) handleDownloadCover(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleUploadCover 接收前端上传的图片文件并保存到 covers 目录。
+// 表单字段 flatten 为 true 时动图只保留第一帧并另存为 PNG。
 func (h *handler) handleUploadCover(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -437,25 +1285,45 @@ func (h *handler) handleUploadCover(w http.ResponseWriter, r *http.Request) {
 		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "读取文件失败"})
 		return
 	}
+	name := header.Filename
+	flattened := false
+	if v := strings.ToLower(r.FormValue("flatten")); v == "1" || v == "true" || v == "yes" {
+		data, flattened, err = imaging.Flatten(data)
+		if err != nil {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if flattened {
+			name = pngName(name)
+		}
+	}
 
-	filename := header.Filename
-	_ = os.MkdirAll(h.coversDir, 0o755)
-	filename = api.UniqueFilename(h.coversDir, filename)
+	if err := api.EnsureCoversDir(h.coversDir); err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	filename := api.UniqueFilename(h.coversDir, name)
 	savePath := filepath.Join(h.coversDir, filename)
-	if err := os.WriteFile(savePath, data, 0o644); err != nil {
+	if err := api.WriteCoverFile(savePath, data); err != nil {
 		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "保存文件失败"})
 		return
 	}
-
-	h.writeJSON(w, http.StatusOK, map[string]any{
-		"ok":       true,
-		"filename": filename,
-		"path":     "covers/" + filename,
-		"size":     len(data),
-	})
+	h.coverAdded(filename)
+
+	resp := map[string]any{
+		"ok":        true,
+		"filename":  filename,
+		"path":      "covers/" + filename,
+		"size":      len(data),
+		"flattened": flattened,
+	}
+	if similar := h.similarCovers(filename); len(similar) > 0 {
+		resp["similar"] = similar
+	}
+	h.writeJSON(w, http.StatusOK, resp)
 }
 
-// handleDeleteCover 删除 covers 目录下的封面文件，支持单个或批量。
+// handleDeleteCover 把 covers 目录下的封面移入回收站，支持单个或批量；permanent 为 true 时直接删除。
 func (h *handler) handleDeleteCover(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
@@ -465,6 +1333,7 @@ func (h *handler) handleDeleteCover(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		Filename  string   `json:"filename"`
 		Filenames []string `json:"filenames"`
+		Permanent bool     `json:"permanent"`
 	}
 	if err := readJSON(r, &req); err != nil {
 		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
@@ -481,28 +1350,60 @@ func (h *handler) handleDeleteCover(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleted := 0
+	var deleted, trashed []string
 	var firstErr string
 	for _, name := range names {
-		clean := filepath.Base(name)
-		if clean != name || clean == "." || clean == ".." {
+		// 允许 covers/<source>/ 下的相对路径，拒绝非规范路径和逃逸
+		clean := filepath.Clean(filepath.FromSlash(name))
+		if clean != filepath.FromSlash(name) || clean == "." || !filepath.IsLocal(clean) {
 			continue
 		}
-		if err := os.Remove(filepath.Join(h.coversDir, clean)); err != nil {
+		var (
+			id  string
+			err error
+		)
+		if req.Permanent {
+			err = removeCover(filepath.Join(h.coversDir, clean))
+		} else {
+			id, err = h.trash.move(h.coversDir, clean)
+		}
+		if err != nil {
 			if !errors.Is(err, os.ErrNotExist) && firstErr == "" {
 				firstErr = err.Error()
 			}
 			continue
 		}
-		deleted++
+		h.removeThumbnails(clean)
+		deleted = append(deleted, filepath.ToSlash(clean))
+		if id != "" {
+			trashed = append(trashed, id)
+		}
+	}
+	if len(deleted) > 0 {
+		h.coverList.invalidate()
+		h.coverVersion.bump()
+		h.events.publish(eventCoverDeleted, map[string][]string{"filenames": deleted})
 	}
 
-	if deleted == 0 && firstErr != "" {
+	if len(deleted) == 0 && firstErr != "" {
 		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "删除失败: " + firstErr})
 		return
 	}
 
-	h.writeJSON(w, http.StatusOK, map[string]any{"ok": true, "deleted": deleted})
+	resp := map[string]any{"ok": true, "deleted": len(deleted)}
+	if !req.Permanent {
+		resp["trashed"] = trashed
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// queryBool 判断查询参数是否为真值（1/true/yes）。
+func queryBool(r *http.Request, name string) bool {
+	switch strings.ToLower(r.URL.Query().Get(name)) {
+	case "1", "true", "yes":
+		return true
+	}
+	return false
 }
 
 // readJSON 从请求体解析 JSON 到目标结构。
@@ -517,18 +1418,52 @@ func readJSON(r *http.Request, v any) error {
 	return json.Unmarshal(body, v)
 }
 
-// writeAPIError 将业务错误映射为合适的 HTTP 状态码。
+// writeAPIError 将业务错误映射为合适的 HTTP 状态码：参数错误 400、不存在 404、上游限流 429、
+// 上游故障或本地上游请求队列已满 503，其余上游错误 502。上游给出 Retry-After 时原样转发。
 func (h *handler) writeAPIError(w http.ResponseWriter, err error) {
-	if api.IsBadRequest(err) {
-		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
-		return
+	code, msg := apiErrorStatus(w, err)
+	h.writeJSON(w, code, map[string]string{"error": msg})
+}
+
+// apiErrorStatus 按错误分类返回状态码与错误信息，限流与上游故障时顺带设置 Retry-After。
+// 失败会连同请求 ID 记录日志（见 logAPIError），便于按用户反馈的 requestId 排查。
+func apiErrorStatus(w http.ResponseWriter, err error) (int, string) {
+	code, msg := classifyAPIError(w, err)
+	logAPIError(w, code, err)
+	return code, msg
+}
+
+// classifyAPIError 把错误映射为状态码与错误信息。
+func classifyAPIError(w http.ResponseWriter, err error) (int, string) {
+	switch {
+	case api.IsBadRequest(err):
+		return http.StatusBadRequest, err.Error()
+	case api.IsNotFound(err):
+		return http.StatusNotFound, err.Error()
+	case api.IsOverloaded(err):
+		setRetryAfter(w, time.Second)
+		return http.StatusServiceUnavailable, api.ErrOverloaded.Error()
+	case api.IsRateLimited(err):
+		setRetryAfter(w, api.RetryAfter(err))
+		return http.StatusTooManyRequests, err.Error()
+	case api.IsUpstreamDown(err):
+		setRetryAfter(w, api.RetryAfter(err))
+		return http.StatusServiceUnavailable, err.Error()
+	}
+	return http.StatusBadGateway, err.Error()
+}
+
+// setRetryAfter 按秒（向上取整）设置 Retry-After 头，d 为 0 时不设置。
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	if d > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((d+time.Second-1)/time.Second)))
 	}
-	h.writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
 }
 
 // writeJSON 将结构体或映射编码后输出为 JSON 响应。
+// 错误响应附带本次请求的 requestId，便于与日志对应。
 func (h *handler) writeJSON(w http.ResponseWriter, code int, data any) {
-	b, err := json.Marshal(data)
+	b, err := json.Marshal(withErrorRequestID(w, code, data))
 	if err != nil {
 		http.Error(w, "JSON 编码失败", http.StatusInternalServerError)
 		return
@@ -536,12 +1471,32 @@ func (h *handler) writeJSON(w http.ResponseWriter, code int, data any) {
 	h.writeJSONRawWithCode(w, code, b)
 }
 
+// streamJSON 将大体积响应直接编码到连接上，省去 json.Marshal 返回的整块副本与 Content-Length 预计算。
+// 状态码在编码前写出，之后无法再改为错误响应；编码失败只能记录日志并中断输出。
+// 仅用于由已知结构体组成、不会编码失败的热点响应，错误响应仍走 writeJSON。
+func (h *handler) streamJSON(w http.ResponseWriter, code int, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	if wantsPretty(w) {
+		enc.SetIndent("", prettyJSONIndent)
+	}
+	if err := enc.Encode(data); err != nil {
+		slog.Warn("流式输出 JSON 失败", "error", err)
+	}
+}
+
 // writeJSONRaw 直接输出已编码的 JSON 字节。
 func (h *handler) writeJSONRaw(w http.ResponseWriter, code int, data []byte) {
 	h.writeJSONRawWithCode(w, code, data)
 }
 
+// writeJSONRawWithCode 输出 JSON 字节；请求要求缩进时先重新缩进，Content-Length 按实际输出计算。
 func (h *handler) writeJSONRawWithCode(w http.ResponseWriter, code int, data []byte) {
+	if wantsPretty(w) {
+		data = indentJSON(data)
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// sourceInfo 描述一个数据源及其是否可用；不可用时 Reason 说明原因。
+type sourceInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// knownSources 是服务支持的数据源，顺序即 /api/sources 的返回顺序。
+var knownSources = []sourceInfo{
+	{ID: api.SourceBangumi, Name: "Bangumi"},
+	{ID: api.SourceVNDB, Name: "VNDB"},
+	{ID: api.SourceAniDB, Name: "AniDB"},
+}
+
+// 数据源不可用的说明。
+const (
+	errSourceDisabled    = "该数据源已通过 -disable-sources 关闭"
+	errAniDBUnconfigured = "未设置 AniDB 客户端注册参数（-anidb-client / -anidb-clientver）"
+)
+
+// sourceUnavailable 返回数据源不可用的原因，可用时返回空串。
+// 缺少必要配置的数据源同样视为不可用，聚合类接口跳过它们，显式指定时返回 400。
+func (h *handler) sourceUnavailable(source string) string {
+	if slices.Contains(h.cfg.DisabledSources, source) {
+		return errSourceDisabled
+	}
+	if source == api.SourceAniDB && !h.anidb.Configured() {
+		return errAniDBUnconfigured
+	}
+	return ""
+}
+
+// sources 返回全部数据源及其可用状态。
+func (h *handler) sources() []sourceInfo {
+	out := slices.Clone(knownSources)
+	for i := range out {
+		out[i].Reason = h.sourceUnavailable(out[i].ID)
+		out[i].Available = out[i].Reason == ""
+	}
+	return out
+}
+
+// checkSource 在数据源不可用时写出 400 并返回 false。
+func (h *handler) checkSource(w http.ResponseWriter, source string) bool {
+	if reason := h.sourceUnavailable(source); reason != "" {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "数据源 " + source + " 不可用: " + reason})
+		return false
+	}
+	return true
+}
+
+// requireSource 包装只访问单个数据源的处理器，数据源不可用时直接返回 400。
+func (h *handler) requireSource(source string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.checkSource(w, source) {
+			next(w, r)
+		}
+	}
+}
+
+// handleSources 列出数据源及其可用状态（GET /api/sources）。
+func (h *handler) handleSources(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"sources": h.sources()})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+)
+
+// stateMetaKey 是图表状态中保存条目元数据快照的保留键。
+// 其值是以封面文件名（covers 目录下的相对路径）为键的对象，记录保存时条目的标题、评分等信息，
+// 使重新打开图表时即便上游条目已变更也能显示当时的内容。其余键原样保存，不做任何改动。
+const stateMetaKey = "_meta"
+
+// maxStateMetaEntries 是 _meta 块允许的最大条目数。
+const maxStateMetaEntries = 10000
+
+// stateMetaStringFields 是 _meta 条目中取值必须为字符串的已知字段，未列出的字段不做检查。
+var stateMetaStringFields = []string{"source", "title", "titleCn", "date", "type", "url"}
+
+// errInvalidStateMeta 表示图表状态中的 _meta 块结构不合法。
+var errInvalidStateMeta = errors.New("_meta 格式不正确")
+
+// validateStateMeta 校验图表状态中的 _meta 块：必须是对象，键为非空的封面文件名，
+// 值为对象；id 为数字或字符串，score 为数字，其余已知字段为字符串。状态中没有 _meta 时直接通过。
+func validateStateMeta(state any) error {
+	obj, ok := state.(map[string]any)
+	if !ok {
+		return nil
+	}
+	raw, ok := obj[stateMetaKey]
+	if !ok {
+		return nil
+	}
+	meta, ok := raw.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%w: 应为对象", errInvalidStateMeta)
+	}
+	if len(meta) > maxStateMetaEntries {
+		return fmt.Errorf("%w: 条目数超过上限 %d", errInvalidStateMeta, maxStateMetaEntries)
+	}
+	for name, v := range meta {
+		if name == "" {
+			return fmt.Errorf("%w: 封面文件名不能为空", errInvalidStateMeta)
+		}
+		entry, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: %s 应为对象", errInvalidStateMeta, name)
+		}
+		if id, ok := entry["id"]; ok {
+			switch id.(type) {
+			case float64, string:
+			default:
+				return fmt.Errorf("%w: %s.id 应为数字或字符串", errInvalidStateMeta, name)
+			}
+		}
+		if score, ok := entry["score"]; ok && score != nil {
+			if _, ok := score.(float64); !ok {
+				return fmt.Errorf("%w: %s.score 应为数字", errInvalidStateMeta, name)
+			}
+		}
+		for _, field := range stateMetaStringFields {
+			if fv, ok := entry[field]; ok {
+				if _, ok := fv.(string); !ok {
+					return fmt.Errorf("%w: %s.%s 应为字符串", errInvalidStateMeta, name, field)
+				}
+			}
+		}
+	}
+	return nil
+}
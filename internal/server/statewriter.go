@@ -0,0 +1,182 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// stateFlushInterval 是两次状态落盘之间的最小间隔。
+const stateFlushInterval = 200 * time.Millisecond
+
+// stateWriter 合并高频的状态保存：每个文件只保留最新内容，由单个 goroutine 节流落盘。
+// 拖拽编辑时前端每秒可能保存多次，合并后磁盘写入次数大幅减少。
+type stateWriter struct {
+	h         *handler
+	mu        sync.Mutex              // 保护 pending 与 seq
+	pending   map[string]pendingState // 文件路径 -> 待写入的最新内容
+	modified  map[string]time.Time    // 文件路径 -> 最近一次排队保存的时间，供 Last-Modified 使用
+	seq       uint64
+	flushMu   sync.Mutex // 串行化落盘，保证 Flush 返回时数据已写入
+	notify    chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	interval  time.Duration
+	autosave  time.Duration // 兜底落盘周期，0 表示只在有新内容时落盘
+	lastFlush time.Time     // 最近一次实际写盘的时间，受 mu 保护
+}
+
+// pendingState 是排队中的状态内容，seq 用于判断落盘期间是否又有新内容。
+type pendingState struct {
+	data []byte
+	seq  uint64
+}
+
+// newStateWriter 创建并启动状态写入队列；autosave 大于 0 时，即使没有新的保存也按此周期落盘排队内容。
+func newStateWriter(h *handler, autosave time.Duration) *stateWriter {
+	w := &stateWriter{
+		h:        h,
+		pending:  make(map[string]pendingState),
+		modified: make(map[string]time.Time),
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		interval: stateFlushInterval,
+		autosave: autosave,
+	}
+	go w.run()
+	return w
+}
+
+// Enqueue 记录 path 的最新内容并唤醒写入协程，覆盖尚未落盘的旧内容。
+func (w *stateWriter) Enqueue(path string, data []byte) {
+	w.mu.Lock()
+	w.seq++
+	w.pending[path] = pendingState{data: data, seq: w.seq}
+	w.modified[path] = time.Now()
+	w.mu.Unlock()
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Pending 返回 path 尚未落盘的内容，供读取时保证读到最新状态。
+func (w *stateWriter) Pending(path string) ([]byte, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p, ok := w.pending[path]
+	return p.data, ok
+}
+
+// Modified 返回本次运行中 path 最近一次排队保存的时间。
+func (w *stateWriter) Modified(path string) (time.Time, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t, ok := w.modified[path]
+	return t, ok
+}
+
+// Stats 返回尚未落盘的图表数与最近一次写盘时间（本次运行未写过时为零值）。
+func (w *stateWriter) Stats() (pending int, lastFlush time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending), w.lastFlush
+}
+
+// Flush 立即写入所有待落盘的内容。
+func (w *stateWriter) Flush() {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	batch := make(map[string]pendingState, len(w.pending))
+	for path, p := range w.pending {
+		batch[path] = p
+	}
+	w.mu.Unlock()
+
+	for path, p := range batch {
+		w.h.stateMu.Lock()
+		w.h.backups.backup(w.h.chartNameOf(path), path)
+		err := writeChartFile(path, p.data)
+		w.h.stateMu.Unlock()
+		if err != nil {
+			slog.Error("写入状态失败", "path", path, "error", err)
+		}
+
+		// 写入完成后才移出队列，期间读取仍能拿到最新内容；若已有更新的内容则保留
+		w.mu.Lock()
+		if cur, ok := w.pending[path]; ok && cur.seq == p.seq {
+			delete(w.pending, path)
+		}
+		if err == nil {
+			w.lastFlush = time.Now()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// Close 停止写入协程并落盘剩余内容。
+func (w *stateWriter) Close() {
+	select {
+	case <-w.done:
+	default:
+		close(w.done)
+	}
+	<-w.stopped
+}
+
+// run 等待新内容后落盘，两次落盘之间至少间隔 interval。
+// 另按 autosave 周期兜底落盘，防止进程被强杀时丢失仍在队列中的内容。
+func (w *stateWriter) run() {
+	defer close(w.stopped)
+	var autosave <-chan time.Time
+	if w.autosave > 0 {
+		t := time.NewTicker(w.autosave)
+		defer t.Stop()
+		autosave = t.C
+	}
+	for {
+		select {
+		case <-w.notify:
+		case <-autosave:
+		case <-w.done:
+			w.Flush()
+			return
+		}
+		w.Flush()
+
+		select {
+		case <-time.After(w.interval):
+		case <-w.done:
+			w.Flush()
+			return
+		}
+	}
+}
+
+// chartModTime 返回图表最近的修改时间：本次运行中保存过的取排队时间（落盘可能尚未发生），否则取文件 mtime。
+func (h *handler) chartModTime(path string) time.Time {
+	if t, ok := h.states.Modified(path); ok {
+		return t
+	}
+	h.stateMu.RLock()
+	defer h.stateMu.RUnlock()
+	if info, err := os.Stat(path); err == nil {
+		return info.ModTime()
+	}
+	return time.Time{}
+}
+
+// readChart 读取图表内容，优先返回写入队列中尚未落盘的最新内容。
+func (h *handler) readChart(path string) ([]byte, error) {
+	if data, ok := h.states.Pending(path); ok {
+		return data, nil
+	}
+	h.stateMu.RLock()
+	defer h.stateMu.RUnlock()
+	return os.ReadFile(path)
+}
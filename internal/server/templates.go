@@ -0,0 +1,147 @@
+package server
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// templateFS 内置的图表模板，每个模板一个 JSON 文件。
+//
+//go:embed templates/*.json
+var templateFS embed.FS
+
+// chartTemplate 描述一种图表结构：网格尺寸、每格标签，以及填充时建议使用的标签与条目类型。
+type chartTemplate struct {
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Columns     int      `json:"columns"`
+	Rows        int      `json:"rows"`
+	Labels      []string `json:"labels"`
+	Tags        []string `json:"tags,omitempty"`  // 建议的浏览标签
+	Types       []string `json:"types,omitempty"` // 建议的条目类型：anime/manga/novel/game
+}
+
+// validate 校验模板的网格尺寸、标签数量与条目类型。
+func (t chartTemplate) validate() error {
+	if !chartNamePattern.MatchString(t.Name) {
+		return fmt.Errorf("无效的模板名: %q", t.Name)
+	}
+	if t.Columns <= 0 || t.Rows <= 0 {
+		return fmt.Errorf("模板 %s 的网格尺寸无效", t.Name)
+	}
+	if len(t.Labels) != t.Columns*t.Rows {
+		return fmt.Errorf("模板 %s 的标签数 %d 与网格 %dx%d 不符", t.Name, len(t.Labels), t.Columns, t.Rows)
+	}
+	for _, typ := range t.Types {
+		if _, ok := api.TypeMap[typ]; !ok {
+			return fmt.Errorf("模板 %s 的条目类型无效: %s", t.Name, typ)
+		}
+	}
+	return nil
+}
+
+// chartTemplates 解析内置模板并按名称排序，只在首次使用时执行。
+var chartTemplates = sync.OnceValues(func() ([]chartTemplate, error) {
+	files, err := fs.Glob(templateFS, "templates/*.json")
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]chartTemplate, 0, len(files))
+	for _, name := range files {
+		data, err := templateFS.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var t chartTemplate
+		if err := json.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("解析模板 %s 失败: %w", name, err)
+		}
+		if err := t.validate(); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	slices.SortFunc(templates, func(a, b chartTemplate) int { return strings.Compare(a.Name, b.Name) })
+	return templates, nil
+})
+
+// handleTemplates 返回内置图表模板列表（GET /api/templates）。
+func (h *handler) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templates, err := chartTemplates()
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{"templates": templates})
+}
+
+// handleStateFromTemplate 用模板初始化图表（POST /api/state/from-template?name=xxx&chart=yyy）。
+// 图表中已有封面时需带 overwrite=1，否则返回 409，避免误覆盖正在编辑的内容。
+func (h *handler) handleStateFromTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	templates, err := chartTemplates()
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	name := r.URL.Query().Get("name")
+	i := slices.IndexFunc(templates, func(t chartTemplate) bool { return t.Name == name })
+	if i < 0 {
+		h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "模板不存在: " + name})
+		return
+	}
+	tmpl := templates[i]
+
+	chart := r.URL.Query().Get("chart")
+	path, err := h.chartPath(chart)
+	if err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	n := tmpl.Columns * tmpl.Rows
+	state, err := h.marshalState(map[string]any{
+		"cells":       make([]any, n),
+		"crops":       make([]any, n),
+		"subjectIDs":  make([]any, n),
+		"swapOffsets": make([]any, n),
+		"template":    tmpl,
+	})
+	if err != nil {
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "JSON 序列化失败"})
+		return
+	}
+
+	h.editMu.Lock()
+	// 无法解析的旧状态同样视为非空，需要显式确认
+	refs, err := h.stateCoverRefs(path)
+	if (err != nil || len(refs) > 0) && !queryBool(r, "overwrite") {
+		h.editMu.Unlock()
+		h.writeJSON(w, http.StatusConflict, map[string]string{"error": "图表中已有封面，确认覆盖请带 overwrite=1"})
+		return
+	}
+	h.states.Enqueue(path, state)
+	h.publishSnapshot(path, state)
+	h.editMu.Unlock()
+
+	h.events.publish(eventStateSaved, map[string]string{"chart": chartDisplayName(chart)})
+	h.writeJSONRaw(w, http.StatusOK, state)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// thumbPregenQueue 是待预生成缩略图的队列长度；运行中入队时队列写满则跳过，由按需生成兜底。
+const thumbPregenQueue = 256
+
+// thumbPregen 在后台预先生成默认宽度的缩略图，使首次加载网格时无需等待缩放。
+// 启动时遍历封面库，之后每新增一张封面入队一次；已是最新的缩略图由 thumbnail 直接跳过。
+type thumbPregen struct {
+	h     *handler
+	queue chan string // 封面相对 covers 目录的路径
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// newThumbPregen 启动有界的工作协程池，并在后台把现有封面全部入队。
+func newThumbPregen(h *handler) *thumbPregen {
+	p := &thumbPregen{
+		h:     h,
+		queue: make(chan string, thumbPregenQueue),
+		done:  make(chan struct{}),
+	}
+	workers := min(max(runtime.NumCPU()/2, 1), 4)
+	for range workers {
+		p.wg.Go(p.work)
+	}
+	p.wg.Go(p.seed)
+	return p
+}
+
+// seed 将启动时已有的封面逐个入队；队列满时等待而不是丢弃。
+func (p *thumbPregen) seed() {
+	start := time.Now()
+	files, err := p.h.listCovers(true)
+	if err != nil {
+		slog.Warn("列出封面失败，跳过缩略图预生成", "error", err)
+		return
+	}
+	for _, rel := range files {
+		select {
+		case p.queue <- rel:
+		case <-p.done:
+			return
+		}
+	}
+	slog.Debug("缩略图预生成任务已全部入队", "count", len(files), "duration_ms", time.Since(start).Milliseconds())
+}
+
+// enqueue 为新增的封面排队生成缩略图，从不阻塞请求处理。
+func (p *thumbPregen) enqueue(rel string) {
+	select {
+	case <-p.done:
+	case p.queue <- rel:
+	default:
+		slog.Debug("缩略图预生成队列已满，跳过", "file", rel)
+	}
+}
+
+// work 逐个生成缩略图，直到 close。
+func (p *thumbPregen) work() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case rel := <-p.queue:
+			if _, err := p.h.thumbnail(filepath.FromSlash(rel), thumbDefaultWidth); err != nil {
+				slog.Debug("预生成缩略图失败", "file", rel, "error", err)
+			}
+		}
+	}
+}
+
+// close 停止预生成，等待进行中的缩略图写完。
+func (p *thumbPregen) close() {
+	close(p.done)
+	p.wg.Wait()
+}
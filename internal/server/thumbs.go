@@ -0,0 +1,133 @@
+package server
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// 缩略图尺寸与编码参数。
+const (
+	thumbDefaultWidth = 240
+	thumbMinWidth     = 32
+	thumbMaxWidth     = 1024
+	thumbJPEGQuality  = 80
+)
+
+// thumbMIME 是缩略图的编码。缩略图统一输出 JPEG：项目只使用纯 Go 依赖，
+// golang.org/x/image/webp 仅支持解码，没有可用的 WebP 编码器。
+const thumbMIME = "image/jpeg"
+
+// thumbPath 返回封面在指定宽度下的缩略图缓存路径。
+// 文件名取封面相对路径的哈希，同一封面的各尺寸共享前缀，便于整体清理。
+func (h *handler) thumbPath(rel string, width int) string {
+	return filepath.Join(h.thumbsDir, fmt.Sprintf("%s_%d.jpg", thumbKey(rel), width))
+}
+
+// thumbKey 将封面相对路径映射为缩略图文件名前缀。
+func thumbKey(rel string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(filepath.ToSlash(rel))))
+}
+
+// thumbnail 返回封面缩略图路径；缓存不存在或早于原图时重新生成。
+// rel 为封面相对路径，原图按优先级在各封面目录中查找；并发请求同一缩略图时只生成一次。
+func (h *handler) thumbnail(rel string, width int) (string, error) {
+	src, _ := h.covers.find(rel)
+	dst := h.thumbPath(rel, width)
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dst); err == nil && !info.ModTime().Before(srcInfo.ModTime()) {
+		return dst, nil
+	}
+
+	_, err, _ = h.thumbGroup.Do(dst, func() (any, error) {
+		return nil, writeThumbnail(src, dst, width)
+	})
+	if err != nil {
+		return "", err
+	}
+	return dst, nil
+}
+
+// writeThumbnail 解码原图、缩放并编码到临时文件，完成后原子替换目标文件。
+func writeThumbnail(src, dst string, width int) error {
+	img, err := imaging.Load(src)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".thumb-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := jpeg.Encode(tmp, imaging.Resize(img, width), &jpeg.Options{Quality: thumbJPEGQuality}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("编码缩略图失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// removeThumbnails 删除封面的全部缩略图缓存。
+func (h *handler) removeThumbnails(rel string) {
+	matches, _ := filepath.Glob(filepath.Join(h.thumbsDir, thumbKey(rel)+"_*"))
+	for _, m := range matches {
+		_ = os.Remove(m)
+	}
+}
+
+// handleThumb 返回封面缩略图（GET /api/thumb?name=xxx&w=240）。
+// 缩略图为 JPEG（见 thumbMIME），缓存在磁盘上，原图更新后自动重建。
+func (h *handler) handleThumb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rel, ok := coverRelPath(r.URL.Query().Get("name"))
+	if !ok {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "无效的封面名称"})
+		return
+	}
+	width := thumbDefaultWidth
+	if v := r.URL.Query().Get("w"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < thumbMinWidth || n > thumbMaxWidth {
+			h.writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("w 须在 %d-%d 之间", thumbMinWidth, thumbMaxWidth),
+			})
+			return
+		}
+		width = n
+	}
+
+	path, err := h.thumbnail(rel, width)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			h.writeJSON(w, http.StatusNotFound, map[string]string{"error": "封面不存在"})
+			return
+		}
+		h.writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", thumbMIME)
+	http.ServeFile(w, r, path)
+}
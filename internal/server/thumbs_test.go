@@ -0,0 +1,52 @@
+package server
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestPNG 在 dir 下写入一张 w×h 的纯色 PNG。
+func writeTestPNG(t *testing.T, dir, name string, w, h int) string {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{200, 40, 40, 255})
+		}
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHandleThumbServesJPEG(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	writeTestPNG(t, h.coversDir, "a.png", 64, 96)
+
+	for _, accept := range []string{"", "image/webp", "image/avif,image/webp,image/*,*/*;q=0.8"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/thumb?name=a.png&w=32", nil)
+		req.Header.Set("Accept", accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/jpeg" {
+			t.Fatalf("Accept %q: status = %d, content-type = %q, want 200 image/jpeg",
+				accept, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+}
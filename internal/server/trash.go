@@ -0,0 +1,290 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// 封面回收站参数。
+const (
+	trashDirName       = ".trash" // 位于可写封面目录下，以点开头，不会出现在封面列表中
+	trashTimeLayout    = "20060102-150405.000"
+	trashSweepInterval = time.Hour
+)
+
+// trashItem 是回收站中的一个封面。ID 是回收站内的相对路径，Filename 是删除前的相对路径。
+type trashItem struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	DeletedAt time.Time `json:"deletedAt"`
+	Size      int64     `json:"size"`
+}
+
+// trashStore 把删除的封面移入 covers/.trash/，文件名加删除时间前缀，子目录结构保持不变，
+// 例如 bgm/a.jpg 移为 .trash/bgm/20240101-120000.000_a.jpg。旁路元数据（a.jpg.json）随封面一起移动、还原和清除。
+// 超过保留天数的文件定期清除。
+type trashStore struct {
+	dir     string
+	maxAge  time.Duration // 0 表示不自动清除
+	mu      sync.Mutex
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// newTrashStore 创建回收站并启动定期清理；days 为 0 时不自动清除。
+func newTrashStore(dir string, days int) *trashStore {
+	t := &trashStore{
+		dir:     dir,
+		maxAge:  time.Duration(days) * 24 * time.Hour,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// move 把 coversDir 下的 rel（系统分隔符）移入回收站，返回回收站条目 ID。
+func (t *trashStore) move(coversDir, rel string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dir, base := filepath.Split(rel)
+	id := filepath.Join(dir, time.Now().Format(trashTimeLayout)+"_"+base)
+	dst := filepath.Join(t.dir, id)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	if err := renameCover(filepath.Join(coversDir, rel), dst); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(id), nil
+}
+
+// renameCover 把封面连同旁路元数据一起改名。先移动元数据，封面改名失败时把元数据移回，避免两者分离。
+func renameCover(src, dst string) error {
+	metaMoved := true
+	if err := os.Rename(api.CoverMetaPath(src), api.CoverMetaPath(dst)); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		metaMoved = false
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if metaMoved {
+			_ = os.Rename(api.CoverMetaPath(dst), api.CoverMetaPath(src))
+		}
+		return err
+	}
+	return nil
+}
+
+// isCoverMetaName 判断文件名是否是封面的旁路元数据（<图片名>.json）。
+func isCoverMetaName(name string) bool {
+	img, ok := strings.CutSuffix(name, ".json")
+	return ok && isImageName(img)
+}
+
+// removeCover 删除封面及其旁路元数据；封面不存在时返回满足 errors.Is(err, os.ErrNotExist) 的错误。
+func removeCover(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	if err := os.Remove(api.CoverMetaPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("删除封面元数据失败", "path", api.CoverMetaPath(path), "error", err)
+	}
+	return nil
+}
+
+// parseTrashName 从回收站文件名中拆出删除时间与原文件名。
+func parseTrashName(name string) (time.Time, string, bool) {
+	stamp, base, ok := strings.Cut(name, "_")
+	if !ok || base == "" {
+		return time.Time{}, "", false
+	}
+	at, err := time.ParseInLocation(trashTimeLayout, stamp, time.Local)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return at, base, true
+}
+
+// listLocked 按删除时间倒序列出回收站内容，调用方需持有 t.mu。
+func (t *trashStore) listLocked() []trashItem {
+	items := []trashItem{}
+	_ = filepath.WalkDir(t.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || isCoverMetaName(d.Name()) {
+			return nil // 旁路元数据随封面处理，不单独列出
+		}
+		at, base, ok := parseTrashName(d.Name())
+		if !ok {
+			return nil // 非本程序移入的文件不列出也不清理
+		}
+		id, err := filepath.Rel(t.dir, path)
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		items = append(items, trashItem{
+			ID:        filepath.ToSlash(id),
+			Filename:  filepath.ToSlash(filepath.Join(filepath.Dir(id), base)),
+			DeletedAt: at,
+			Size:      info.Size(),
+		})
+		return nil
+	})
+	slices.SortFunc(items, func(a, b trashItem) int { return b.DeletedAt.Compare(a.DeletedAt) })
+	return items
+}
+
+// list 返回回收站内容。
+func (t *trashStore) list() []trashItem {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.listLocked()
+}
+
+// restore 把回收站条目移回 coversDir 下的原位置，返回还原后的相对路径（/ 分隔）。
+func (t *trashStore) restore(coversDir, id string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(id))
+	if clean != filepath.FromSlash(id) || !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("无效的回收站条目: %s", id)
+	}
+	dir, name := filepath.Split(clean)
+	_, base, ok := parseTrashName(name)
+	if !ok {
+		return "", fmt.Errorf("无效的回收站条目: %s", id)
+	}
+	rel := filepath.Join(dir, base)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	dst := filepath.Join(coversDir, rel)
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("同名封面已存在: %s", filepath.ToSlash(rel))
+	}
+	if err := api.MkdirCovers(filepath.Dir(dst)); err != nil {
+		return "", err
+	}
+	if err := renameCover(filepath.Join(t.dir, clean), dst); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// sweep 删除超过保留期限的文件。
+func (t *trashStore) sweep() {
+	if t.maxAge <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cutoff := time.Now().Add(-t.maxAge)
+	for _, item := range t.listLocked() {
+		if item.DeletedAt.After(cutoff) {
+			continue
+		}
+		path := filepath.Join(t.dir, filepath.FromSlash(item.ID))
+		if err := removeCover(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("清除回收站文件失败", "path", path, "error", err)
+		}
+	}
+}
+
+// run 定期清除过期文件，直到 close。
+func (t *trashStore) run() {
+	defer close(t.stopped)
+	t.sweep()
+	ticker := time.NewTicker(trashSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.sweep()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// close 停止定期清理。
+func (t *trashStore) close() {
+	select {
+	case <-t.done:
+	default:
+		close(t.done)
+	}
+	<-t.stopped
+}
+
+// handleTrash 列出回收站中的封面（GET /api/covers/trash），按删除时间倒序。
+func (h *handler) handleTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"items":         h.trash.list(),
+		"retentionDays": h.cfg.TrashDays,
+	})
+}
+
+// handleTrashRestore 把回收站中的封面还原到原位置（POST /api/covers/trash/restore），支持单个或批量。
+// 原位置已有同名封面的条目跳过并在 errors 中说明。
+func (h *handler) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID  string   `json:"id"`
+		IDs []string `json:"ids"`
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	ids := req.IDs
+	if req.ID != "" {
+		ids = append(ids, req.ID)
+	}
+	if len(ids) == 0 {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "缺少回收站条目 id"})
+		return
+	}
+
+	restored := []string{}
+	errs := map[string]string{}
+	for _, id := range ids {
+		name, err := h.trash.restore(h.coversDir, id)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				err = errors.New("回收站中没有该条目")
+			}
+			errs[id] = err.Error()
+			continue
+		}
+		h.coverAdded(name)
+		restored = append(restored, name)
+	}
+
+	resp := map[string]any{"ok": len(restored) > 0, "restored": restored}
+	if len(errs) > 0 {
+		resp["errors"] = errs
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
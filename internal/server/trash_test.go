@@ -0,0 +1,101 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// writeTestCover 在 dir 下写入封面及其旁路元数据。
+func writeTestCover(t *testing.T, dir, rel string) string {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("img"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(api.CoverMetaPath(path), []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestTrashMovesSidecar(t *testing.T) {
+	covers := t.TempDir()
+	trash := &trashStore{dir: filepath.Join(covers, trashDirName)}
+	src := writeTestCover(t, covers, "bgm/a.jpg")
+
+	id, err := trash.move(covers, filepath.Join("bgm", "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	trashed := filepath.Join(trash.dir, filepath.FromSlash(id))
+	if exists(src) || exists(api.CoverMetaPath(src)) {
+		t.Fatal("cover or sidecar left in covers/ after move")
+	}
+	if !exists(trashed) || !exists(api.CoverMetaPath(trashed)) {
+		t.Fatal("cover or sidecar missing from trash after move")
+	}
+	if items := trash.list(); len(items) != 1 || items[0].Filename != "bgm/a.jpg" {
+		t.Fatalf("list() = %+v, want one item bgm/a.jpg", items)
+	}
+
+	rel, err := trash.restore(covers, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel != "bgm/a.jpg" || !exists(src) || !exists(api.CoverMetaPath(src)) {
+		t.Fatalf("restore() = %q, cover or sidecar not restored", rel)
+	}
+	if exists(api.CoverMetaPath(trashed)) {
+		t.Fatal("sidecar left in trash after restore")
+	}
+}
+
+func TestTrashSweepRemovesSidecar(t *testing.T) {
+	covers := t.TempDir()
+	trash := &trashStore{dir: filepath.Join(covers, trashDirName), maxAge: time.Hour}
+	writeTestCover(t, covers, "a.jpg")
+	id, err := trash.move(covers, "a.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 把删除时间改到保留期之前
+	old := time.Now().Add(-2*time.Hour).Format(trashTimeLayout) + "_a.jpg"
+	trashed := filepath.Join(trash.dir, filepath.FromSlash(id))
+	if err := renameCover(trashed, filepath.Join(trash.dir, old)); err != nil {
+		t.Fatal(err)
+	}
+
+	trash.sweep()
+	entries, err := os.ReadDir(trash.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("trash not empty after sweep: %v", entries)
+	}
+}
+
+func TestRemoveCoverRemovesSidecar(t *testing.T) {
+	path := writeTestCover(t, t.TempDir(), "a.png")
+	if err := removeCover(path); err != nil {
+		t.Fatal(err)
+	}
+	if exists(path) || exists(api.CoverMetaPath(path)) {
+		t.Fatal("cover or sidecar left after removeCover")
+	}
+	if err := removeCover(path); !os.IsNotExist(err) {
+		t.Fatalf("removeCover(missing) err = %v, want not exist", err)
+	}
+}
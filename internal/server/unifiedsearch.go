@@ -0,0 +1,165 @@
+package server
+
+import (
+	"cmp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/api"
+)
+
+// 聚合搜索参数。
+const (
+	unifiedSearchDeadline = 10 * time.Second // 整体截止时间，届时仍未返回的数据源记为超时
+	unifiedSearchLimit    = 20               // 每个数据源默认返回的结果数
+)
+
+// errUnifiedSearchTimeout 是数据源未在截止时间内返回时的错误信息。
+const errUnifiedSearchTimeout = "搜索超时"
+
+// unifiedSearchSource 是单个数据源的搜索结果；失败或超时时 Results 为空，Error 说明原因。
+type unifiedSearchSource struct {
+	Results  []api.Card `json:"results"`
+	Error    string     `json:"error,omitempty"`
+	TimedOut bool       `json:"timedOut,omitempty"`
+	Skipped  bool       `json:"skipped,omitempty"` // 数据源不可用，未搜索；Note 说明原因
+	Note     string     `json:"note,omitempty"`
+}
+
+// unifiedSearchResult 是单个数据源搜索完成后交回的结果。
+type unifiedSearchResult struct {
+	source string
+	cards  []api.Card
+	err    error
+}
+
+// handleUnifiedSearch 用同一关键词并发搜索所有数据源，按数据源分组返回卡片（POST /api/search-unified）。
+// 单个数据源失败不影响其他数据源；超过整体截止时间仍未返回的数据源记为超时，不再等待。
+// 不可用（被关闭或缺少配置）的数据源不搜索，只在结果中标记 skipped 并附说明，不算作失败。
+func (h *handler) handleUnifiedSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Keyword string `json:"keyword"`
+		Type    int    `json:"type"`  // Bangumi 条目类型，默认动画
+		Limit   int    `json:"limit"` // 每个数据源的结果数上限
+	}
+	if err := readJSON(r, &req); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "解析请求失败"})
+		return
+	}
+	keyword := strings.TrimSpace(req.Keyword)
+	if keyword == "" {
+		h.writeJSON(w, http.StatusBadRequest, map[string]string{"error": "关键词不能为空"})
+		return
+	}
+	if req.Type == 0 {
+		req.Type = cmp.Or(h.prefs.int(prefSearchType), 2)
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = unifiedSearchLimit
+	}
+
+	searches := map[string]func() ([]api.Card, error){
+		api.SourceBangumi: func() ([]api.Card, error) {
+			results, err := h.bgm.Search(keyword, req.Type)
+			if err != nil {
+				return nil, err
+			}
+			cards := make([]api.Card, 0, min(len(results), req.Limit))
+			for _, it := range results[:min(len(results), req.Limit)] {
+				cards = append(cards, api.Card{
+					ID:        strconv.Itoa(it.ID),
+					Name:      it.Name,
+					NameCN:    it.NameCN,
+					Cover:     it.Cover,
+					TypeLabel: api.TypeLabels[req.Type],
+					Source:    api.SourceBangumi,
+				})
+			}
+			return cards, nil
+		},
+		api.SourceVNDB: func() ([]api.Card, error) {
+			resp, err := h.vndb.SearchVN(keyword, 1, req.Limit)
+			if err != nil {
+				return nil, err
+			}
+			cards := make([]api.Card, 0, len(resp.Results))
+			for _, vn := range resp.Results {
+				cards = append(cards, api.Card{
+					ID:     vn.ID,
+					Name:   vn.Title,
+					NameCN: vn.Alttitle,
+					Cover:  vn.Image.BestURL(),
+					Score:  vn.Rating / 10,
+					Source: api.SourceVNDB,
+				})
+			}
+			return cards, nil
+		},
+		api.SourceAniDB: func() ([]api.Card, error) {
+			return h.anidb.SearchAnime(keyword, req.Limit)
+		},
+	}
+
+	sources := make(map[string]unifiedSearchSource, len(searches))
+	for source := range searches {
+		if reason := h.sourceUnavailable(source); reason != "" {
+			sources[source] = unifiedSearchSource{Results: []api.Card{}, Skipped: true, Note: reason}
+			delete(searches, source)
+		}
+	}
+
+	// 通道带足缓冲，截止后仍在进行的搜索完成时不会阻塞
+	done := make(chan unifiedSearchResult, len(searches))
+	for source, search := range searches {
+		go func() {
+			cards, err := search()
+			done <- unifiedSearchResult{source: source, cards: cards, err: err}
+		}()
+	}
+
+	pending := len(searches)
+	deadline := time.NewTimer(unifiedSearchDeadline)
+	defer deadline.Stop()
+wait:
+	for pending > 0 {
+		select {
+		case res := <-done:
+			src := unifiedSearchSource{Results: res.cards}
+			if res.err != nil {
+				src.Error = res.err.Error()
+			}
+			if src.Results == nil {
+				src.Results = []api.Card{}
+			}
+			sources[res.source] = src
+			pending--
+		case <-deadline.C:
+			break wait
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	partial := false
+	for source := range searches {
+		if _, ok := sources[source]; !ok {
+			sources[source] = unifiedSearchSource{Results: []api.Card{}, Error: errUnifiedSearchTimeout, TimedOut: true}
+		}
+		if sources[source].Error != "" {
+			partial = true
+		}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"keyword":        keyword,
+		"sources":        sources,
+		"partialFailure": partial,
+	})
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// uploadCover 以 multipart 表单上传 path 指向的文件，返回响应。
+func uploadCover(t *testing.T, h http.Handler, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload-cover", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestUploadAfterCoversDirRemoved(t *testing.T) {
+	h, _ := newTestHandler(t, nil)
+	src := writeTestPNG(t, t.TempDir(), "a.png", 8, 8)
+
+	if w := uploadCover(t, h, src); w.Code != http.StatusOK {
+		t.Fatalf("first upload status = %d, body = %s", w.Code, w.Body)
+	}
+	// 运行中删除整个 covers 目录
+	if err := os.RemoveAll(h.coversDir); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(t, h, http.MethodGet, "/covers/a.png", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("GET removed cover status = %d, want 404", w.Code)
+	}
+
+	w := uploadCover(t, h, src)
+	if w.Code != http.StatusOK {
+		t.Fatalf("upload after removal status = %d, body = %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Filename string `json:"filename"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(h.coversDir, resp.Filename)); err != nil {
+		t.Fatalf("uploaded file missing: %v", err)
+	}
+	if w := do(t, h, http.MethodGet, "/covers/"+resp.Filename, ""); w.Code != http.StatusOK {
+		t.Fatalf("GET recreated cover status = %d, want 200", w.Code)
+	}
+	files, err := h.listCovers(false)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("listCovers = %v, %v; want one file", files, err)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+
+	"github.com/Aytrw/otaku-chart-maker/internal/imaging"
+)
+
+// 小封面放大参数。
+const (
+	upscaleDefaultMin  = 400 // 短边低于此值的封面被放大到此值
+	upscaleMinLimit    = 64
+	upscaleMaxLimit    = 1200
+	upscaleMaxFactor   = 3.0 // 放大倍数上限
+	upscaleJPEGQuality = 92
+)
+
+// upscaleResult 是放大的结果；Upscaled 为 false 时文件未改动，Final 与 Original 相同。
+type upscaleResult struct {
+	Filename string
+	Size     int
+	Original coverDims
+	Final    coverDims
+	Upscaled bool
+}
+
+// upscaleCover 在可写封面目录下的封面短边小于 minSide 时将其放大并替换原文件。
+// JPEG 与 PNG 按原格式重新编码，其他格式（WebP、GIF 等）改存为 PNG。
+func (h *handler) upscaleCover(rel string, minSide int) (upscaleResult, error) {
+	img, err := imaging.Load(filepath.Join(h.coversDir, filepath.FromSlash(rel)))
+	if err != nil {
+		return upscaleResult{}, err
+	}
+	b := img.Bounds()
+	res := upscaleResult{Filename: rel, Original: coverDims{Width: b.Dx(), Height: b.Dy()}}
+	res.Final = res.Original
+
+	out, ok := imaging.Upscale(img, minSide, upscaleMaxFactor)
+	if !ok {
+		return res, nil
+	}
+
+	var buf bytes.Buffer
+	ext := strings.ToLower(filepath.Ext(rel))
+	switch ext {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: upscaleJPEGQuality})
+	default:
+		ext = ".png"
+		err = png.Encode(&buf, out)
+	}
+	if err != nil {
+		return upscaleResult{}, err
+	}
+	if res.Filename, err = h.replaceCover(rel, buf.Bytes(), ext); err != nil {
+		return upscaleResult{}, err
+	}
+	res.Size = buf.Len()
+	res.Final = coverDims{Width: out.Bounds().Dx(), Height: out.Bounds().Dy()}
+	res.Upscaled = true
+	return res, nil
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"syscall"
+	"time"
 
+	"github.com/Aytrw/otaku-chart-maker/internal/config"
 	"github.com/Aytrw/otaku-chart-maker/internal/server"
 )
 
@@ -24,16 +32,31 @@ var frontendFS embed.FS
 
 // main 完成运行目录初始化、HTTP 服务启动和浏览器拉起。
 func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("解析参数失败: %v", err)
+	}
+	setupLogger(cfg.LogFormat)
+
 	// 确定数据目录：exe 目录下有 covers/ 就用 exe 目录，否则回退 cwd（兼容 go run）。
 	baseDir := resolveBaseDir()
 
-	// 如果 baseDir 下有 frontend/index.html，直接从磁盘读取，方便实时修改前端。
-	frontend, devMode, err := loadFrontendFS(baseDir)
+	// 如果 baseDir 下有 frontend/index.html，直接从磁盘读取，方便实时修改前端；-frontend-dir/-embedded 可覆盖。
+	frontend, frontendDir, err := loadFrontendFS(baseDir, cfg)
 	if err != nil {
 		log.Fatalf("加载前端文件失败: %v", err)
 	}
+	devMode := frontendDir != ""
+	if devMode {
+		slog.Info("使用磁盘上的前端文件", "dir", frontendDir)
+	} else {
+		slog.Info("使用嵌入的前端文件")
+	}
 
-	h, coverCount, err := server.NewHandler(baseDir, frontend)
+	h, coverCount, err := server.NewHandler(baseDir, frontend, cfg)
 	if err != nil {
 		log.Fatalf("初始化服务器失败: %v", err)
 	}
@@ -43,14 +66,44 @@ func main() {
 	if devMode {
 		modeLabel = "Development (disk)"
 	}
-	printStartupBanner(modeLabel, url, coverCount)
+	if cfg.LogFormat == config.LogFormatJSON {
+		slog.Info("server started", "mode", modeLabel, "url", url, "covers", coverCount)
+	} else {
+		printStartupBanner(modeLabel, url, coverCount)
+	}
 
 	// 浏览器打开是辅助行为，不阻塞服务启动。
 	go openBrowser(url)
 
-	if err := http.ListenAndServe(fmt.Sprintf(":%d", port), h); err != nil {
-		log.Fatalf("服务器启动失败: %v", err)
+	srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: h}
+	srv.RegisterOnShutdown(h.CloseStreams)
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("服务器启动失败: %v", err)
+		}
+	}()
+
+	// 收到退出信号后停止接收新请求，并落盘排队中的状态
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("关闭服务器失败: %v", err)
+	}
+	if err := h.Close(); err != nil {
+		log.Printf("保存状态失败: %v", err)
+	}
+}
+
+// setupLogger 按日志格式设置默认 logger；json 模式下 log 包输出也会转为结构化日志。
+func setupLogger(format string) {
+	if format != config.LogFormatJSON {
+		return
 	}
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
 }
 
 // resolveBaseDir 确定数据根目录：exe 目录下有 covers/ 就用 exe 目录，否则回退 cwd（兼容 go run）。
@@ -89,19 +142,30 @@ func openBrowser(url string) {
 	_ = cmd.Start()
 }
 
-// loadFrontendFS 自动检测磁盘上的 frontend/ 目录，有则从磁盘读取（方便开发），否则用 embed。
-func loadFrontendFS(baseDir string) (fs.FS, bool, error) {
-	frontendDir := filepath.Join(baseDir, "frontend")
-	diskFS := os.DirFS(frontendDir)
-	if _, err := fs.Stat(diskFS, "index.html"); err == nil {
-		return diskFS, true, nil
+// loadFrontendFS 选择前端文件来源，返回文件系统与所用的磁盘目录（嵌入模式为空）。
+// -embedded 强制使用 embed；-frontend-dir 强制从该目录读取，缺少 index.html 时报错；
+// 都未指定时自动检测 baseDir 下的 frontend/ 目录，有则从磁盘读取（方便开发），否则用 embed。
+func loadFrontendFS(baseDir string, cfg *config.Config) (fs.FS, string, error) {
+	if !cfg.Embedded {
+		dir, forced := cfg.FrontendDir, cfg.FrontendDir != ""
+		if !forced {
+			dir = filepath.Join(baseDir, "frontend")
+		}
+		diskFS := os.DirFS(dir)
+		_, err := fs.Stat(diskFS, "index.html")
+		if err == nil {
+			return diskFS, dir, nil
+		}
+		if forced {
+			return nil, "", fmt.Errorf("-frontend-dir 中找不到 index.html: %w", err)
+		}
 	}
 
 	embeddedFS, err := fs.Sub(frontendFS, "frontend")
 	if err != nil {
-		return nil, false, err
+		return nil, "", err
 	}
-	return embeddedFS, false, nil
+	return embeddedFS, "", nil
 }
 
 // printStartupBanner 输出统一启动信息。